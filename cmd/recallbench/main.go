@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/sanjeevkumarraob/semantic-search-service/pkg/vectorstore"
+)
+
+// recallbench compares the HNSW index of the in-memory vector store against
+// exact brute-force search on random vectors, reporting recall and latency.
+func main() {
+	items := flag.Int("items", 10000, "number of vectors to index")
+	dim := flag.Int("dim", 384, "vector dimensionality")
+	queries := flag.Int("queries", 100, "number of queries")
+	k := flag.Int("k", 10, "number of neighbours per query")
+	m := flag.Int("m", 16, "HNSW links per node")
+	efConstruction := flag.Int("ef-construction", 200, "HNSW candidate list size while inserting")
+	efSearch := flag.Int("ef-search", 64, "HNSW candidate list size while searching")
+	seed := flag.Int64("seed", 42, "random seed")
+	flag.Parse()
+
+	logger := log.New(os.Stdout, "RECALLBENCH: ", log.Ldate|log.Ltime)
+	ctx := context.Background()
+	r := rand.New(rand.NewSource(*seed))
+
+	vectors := make([][]float32, *items)
+	for i := range vectors {
+		vectors[i] = randomUnitVector(r, *dim)
+	}
+	queryVectors := make([][]float32, *queries)
+	for i := range queryVectors {
+		queryVectors[i] = randomUnitVector(r, *dim)
+	}
+
+	flat, err := vectorstore.NewMemoryStore(&vectorstore.Config{
+		VectorSize: *dim,
+		Index:      vectorstore.IndexConfig{Type: vectorstore.IndexFlat},
+	})
+	if err != nil {
+		logger.Fatalf("Failed to create store: %v", err)
+	}
+	defer flat.Close()
+
+	hnsw, err := vectorstore.NewMemoryStore(&vectorstore.Config{
+		VectorSize: *dim,
+		Index: vectorstore.IndexConfig{
+			Type:           vectorstore.IndexHNSW,
+			M:              *m,
+			EfConstruction: *efConstruction,
+			EfSearch:       *efSearch,
+		},
+	})
+	if err != nil {
+		logger.Fatalf("Failed to create store: %v", err)
+	}
+	defer hnsw.Close()
+
+	for _, store := range []*vectorstore.MemoryStore{flat, hnsw} {
+		start := time.Now()
+		for i, vector := range vectors {
+			item := &vectorstore.Item{ID: fmt.Sprintf("item-%d", i), Vector: vector}
+			if err := store.Store(ctx, item); err != nil {
+				logger.Fatalf("Failed to store vector: %v", err)
+			}
+		}
+		logger.Printf("Indexed %d vectors in %s", *items, time.Since(start))
+	}
+
+	flatLatency := measureLatency(ctx, logger, flat, queryVectors, *k)
+	hnswLatency := measureLatency(ctx, logger, hnsw, queryVectors, *k)
+
+	recall, err := hnsw.EvaluateRecall(ctx, "", queryVectors, *k)
+	if err != nil {
+		logger.Fatalf("Failed to evaluate recall: %v", err)
+	}
+
+	logger.Printf("Exact search: %s per query", flatLatency)
+	logger.Printf("HNSW search:  %s per query", hnswLatency)
+	logger.Printf("HNSW recall@%d: %.4f", *k, recall)
+}
+
+// measureLatency returns the mean search latency over the queries
+func measureLatency(ctx context.Context, logger *log.Logger, store *vectorstore.MemoryStore, queries [][]float32, k int) time.Duration {
+	start := time.Now()
+	for _, query := range queries {
+		if _, err := store.Search(ctx, &vectorstore.SearchParams{Vector: query, Limit: k}); err != nil {
+			logger.Fatalf("Search failed: %v", err)
+		}
+	}
+
+	return time.Since(start) / time.Duration(len(queries))
+}
+
+// randomUnitVector draws a normalized Gaussian vector
+func randomUnitVector(r *rand.Rand, dim int) []float32 {
+	vector := make([]float32, dim)
+	var sum float64
+	for i := range vector {
+		v := r.NormFloat64()
+		vector[i] = float32(v)
+		sum += v * v
+	}
+
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+
+	return vector
+}
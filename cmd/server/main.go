@@ -4,16 +4,16 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/gin-gonic/gin"
-	"github.com/gorilla/sessions"
 	"github.com/sanjeevkumarraob/semantic-search-service/internal/api"
 	"github.com/sanjeevkumarraob/semantic-search-service/internal/atlassian"
 	"github.com/sanjeevkumarraob/semantic-search-service/internal/auth"
 	"github.com/sanjeevkumarraob/semantic-search-service/internal/document"
 	"github.com/sanjeevkumarraob/semantic-search-service/internal/search"
-	"github.com/sanjeevkumarraob/semantic-search-service/internal/session"
+	"github.com/sanjeevkumarraob/semantic-search-service/pkg/vectorstore"
 )
 
 func main() {
@@ -53,73 +53,24 @@ func main() {
 	docProcessor := document.NewProcessor(logger)
 
 	// Initialize search engine
-	searchEngine := search.NewEngine(logger)
-
-	// Create a secure key for sessions
-	key := []byte(os.Getenv("SESSION_SECRET"))
-	if len(key) == 0 {
-		key = []byte("your-secret-key") // Fallback for development
-	}
-
-	// Initialize session store
-	store := sessions.NewCookieStore(key)
-	store.Options = &sessions.Options{
-		Path:     "/",
-		MaxAge:   3600,
-		HttpOnly: true,
-		Secure:   true, // Must be true when using SameSiteNone
-		SameSite: http.SameSiteNoneMode,
+	searchEngine, err := search.NewEngine(loadSearchConfig(logger), logger)
+	if err != nil {
+		logger.Fatalf("Failed to initialize search engine: %v", err)
 	}
+	defer searchEngine.Cleanup()
 
-	// Initialize session manager
-	sessionManager := session.NewSessionManager(logger, store)
-
-	// Initialize handler
-	handler := api.NewHandler(
+	// Admin endpoints are served to the accounts listed by ID or email
+	admins := strings.Split(os.Getenv("ADMIN_ACCOUNTS"), ",")
+	router := api.NewRouter(
 		atlassianAuth,
 		confluenceClient,
 		jiraClient,
 		docProcessor,
 		searchEngine,
 		logger,
-		sessionManager,
+		admins,
 	)
 
-	// Configure server
-	router := gin.Default()
-
-	// Configure routes
-	router.GET("/", handler.HealthCheck)
-	router.GET("/auth/login", handler.AtlassianLoginURL)
-	router.GET("/auth/callback", handler.AtlassianCallback)
-
-	// API routes that require authentication
-	apiGroup := router.Group("/api")
-	apiGroup.Use(api.AuthMiddleware(atlassianAuth, store))
-	apiGroup.POST("/search", handler.Search)
-
-	// Add Confluence endpoints
-	apiGroup.GET("/confluence/spaces", handler.ListConfluenceSpaces)
-	apiGroup.GET("/confluence/pages/:spaceKey", handler.ListConfluencePages)
-	apiGroup.POST("/confluence/process/:pageId", handler.ProcessConfluencePage)
-
-	// Add middleware to check for localhost in each request
-	router.Use(func(c *gin.Context) {
-		// Check if we're in a development environment
-		isLocalhost := c.Request.Host == "localhost:8080" || c.Request.Host == "127.0.0.1:8080"
-
-		// Update store options for this request
-		store.Options = &sessions.Options{
-			Path:     "/",
-			MaxAge:   3600,
-			HttpOnly: true,
-			Secure:   !isLocalhost, // Only false for localhost
-			SameSite: http.SameSiteNoneMode,
-		}
-
-		c.Next()
-	})
-
 	// Start server
 	server := &http.Server{
 		Addr:         ":8080",
@@ -133,3 +84,134 @@ func main() {
 		logger.Fatalf("Server failed to start: %v", err)
 	}
 }
+
+// loadSearchConfig builds the search engine configuration from the environment
+func loadSearchConfig(logger *log.Logger) *search.Config {
+	backend := vectorstore.Backend(os.Getenv("VECTOR_STORE_BACKEND"))
+	if backend == "" {
+		backend = vectorstore.BackendMemory
+	}
+	logger.Printf("Using %s vector store backend", backend)
+
+	// EMBEDDING_NEXT_* configures the model to migrate to
+	var next *search.EmbedderConfig
+	if os.Getenv("EMBEDDING_NEXT_PROVIDER") != "" {
+		config := envEmbedder(logger, "EMBEDDING_NEXT_")
+		next = &config
+	}
+
+	return &search.Config{
+		TTL:            envDuration(logger, "VECTOR_TTL"),
+		Retention:      envRetention(logger),
+		EmbedBatchSize: envInt(logger, "EMBED_BATCH_SIZE"),
+		EmbedWorkers:   envInt(logger, "EMBED_WORKERS"),
+		Embedder:       envEmbedder(logger, "EMBEDDING_"),
+		NextEmbedder:   next,
+		VectorStore: vectorstore.Config{
+			Backend:    backend,
+			Address:    os.Getenv("QDRANT_ADDRESS"),
+			APIKey:     os.Getenv("QDRANT_API_KEY"),
+			Collection: os.Getenv("QDRANT_COLLECTION"),
+			Index: vectorstore.IndexConfig{
+				Type:           vectorstore.IndexType(os.Getenv("VECTOR_INDEX")),
+				M:              envInt(logger, "HNSW_M"),
+				EfConstruction: envInt(logger, "HNSW_EF_CONSTRUCTION"),
+				EfSearch:       envInt(logger, "HNSW_EF_SEARCH"),
+			},
+			DataDir:          os.Getenv("VECTOR_STORE_DATA_DIR"),
+			SnapshotInterval: envDuration(logger, "VECTOR_STORE_SNAPSHOT_INTERVAL"),
+			SyncWrites:       os.Getenv("VECTOR_STORE_SYNC_WRITES") == "true",
+			Shards:           envInt(logger, "VECTOR_STORE_SHARDS"),
+			MemoryBudget:     int64(envInt(logger, "VECTOR_STORE_MEMORY_BUDGET_MB")) << 20,
+			ChangeFeedSize:   envInt(logger, "VECTOR_STORE_CHANGE_FEED_SIZE"),
+			Quantization: vectorstore.QuantizationConfig{
+				Enabled:         os.Getenv("VECTOR_QUANTIZATION") == "int8",
+				CalibrationSize: envInt(logger, "VECTOR_QUANTIZATION_CALIBRATION_SIZE"),
+				RescoreFactor:   envInt(logger, "VECTOR_QUANTIZATION_RESCORE_FACTOR"),
+			},
+			Reduction: vectorstore.ReductionConfig{
+				Method:     vectorstore.ReductionMethod(os.Getenv("VECTOR_REDUCTION")),
+				Dimensions: envInt(logger, "VECTOR_REDUCTION_DIMENSIONS"),
+				SampleSize: envInt(logger, "VECTOR_REDUCTION_SAMPLE_SIZE"),
+			},
+		},
+	}
+}
+
+// envEmbedder reads an embedder configuration from the environment
+// variables starting with prefix, such as EMBEDDING_PROVIDER
+func envEmbedder(logger *log.Logger, prefix string) search.EmbedderConfig {
+	return search.EmbedderConfig{
+		Provider:         search.EmbeddingProvider(os.Getenv(prefix + "PROVIDER")),
+		URL:              os.Getenv(prefix + "URL"),
+		Model:            os.Getenv(prefix + "MODEL"),
+		ModelVersion:     envInt(logger, prefix+"MODEL_VERSION"),
+		APIKey:           os.Getenv(prefix + "API_KEY"),
+		Dimensions:       envInt(logger, prefix+"DIMENSIONS"),
+		BatchSize:        envInt(logger, prefix+"REQUEST_BATCH_SIZE"),
+		Timeout:          envDuration(logger, prefix+"TIMEOUT"),
+		MaxRetries:       envInt(logger, prefix+"MAX_RETRIES"),
+		ModelPath:        os.Getenv(prefix + "MODEL_PATH"),
+		Threads:          envInt(logger, prefix+"THREADS"),
+		CacheSize:        envInt(logger, prefix+"CACHE_SIZE"),
+		CacheDir:         os.Getenv(prefix + "CACHE_DIR"),
+		QueryTemplate:    os.Getenv(prefix + "QUERY_TEMPLATE"),
+		DocumentTemplate: os.Getenv(prefix + "DOCUMENT_TEMPLATE"),
+		MaxTokens:        envInt(logger, prefix+"MAX_TOKENS"),
+	}
+}
+
+// envRetention reads the retention of each document source from the
+// RETENTION_<SOURCE> environment variables, such as
+// RETENTION_CONFLUENCE=sliding:168h or RETENTION_UPLOAD=forever
+func envRetention(logger *log.Logger) map[string]vectorstore.Retention {
+	retention := make(map[string]vectorstore.Retention)
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		source, found := strings.CutPrefix(name, "RETENTION_")
+		if !found || source == "" {
+			continue
+		}
+
+		r, err := vectorstore.ParseRetention(value)
+		if err != nil {
+			logger.Printf("WARNING: Ignoring invalid %s=%q: %v", name, value, err)
+			continue
+		}
+		retention[strings.ToLower(source)] = r
+	}
+
+	return retention
+}
+
+// envInt reads an integer environment variable, returning 0 when unset or invalid
+func envInt(logger *log.Logger, name string) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Printf("WARNING: Ignoring invalid %s=%q: %v", name, value, err)
+		return 0
+	}
+
+	return n
+}
+
+// envDuration reads a duration environment variable, returning 0 when unset or invalid
+func envDuration(logger *log.Logger, name string) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Printf("WARNING: Ignoring invalid %s=%q: %v", name, value, err)
+		return 0
+	}
+
+	return d
+}
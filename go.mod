@@ -8,9 +8,11 @@ require (
 	github.com/go-skynet/go-bert.cpp v0.0.0-20231028093757-710044b12454
 	github.com/golang-jwt/jwt/v5 v5.2.2
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/sessions v1.2.2
 	github.com/ledongthuc/pdf v0.0.0-20240201131950-da5b75280b06
 	github.com/otiai10/gosseract/v2 v2.4.1
 	github.com/unidoc/unioffice v1.39.0
+	go.etcd.io/bbolt v1.4.3
 	golang.org/x/net v0.38.0
 )
 
@@ -27,7 +29,6 @@ require (
 	github.com/goccy/go-json v0.10.5 // indirect
 	github.com/gorilla/context v1.1.2 // indirect
 	github.com/gorilla/securecookie v1.1.2 // indirect
-	github.com/gorilla/sessions v1.2.2 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.10 // indirect
 	github.com/kr/text v0.2.0 // indirect
@@ -1,9 +1,12 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,6 +16,7 @@ import (
 	"github.com/sanjeevkumarraob/semantic-search-service/internal/document"
 	"github.com/sanjeevkumarraob/semantic-search-service/internal/search"
 	"github.com/sanjeevkumarraob/semantic-search-service/internal/session"
+	"github.com/sanjeevkumarraob/semantic-search-service/pkg/vectorstore"
 )
 
 // Handler handles API requests
@@ -55,6 +59,231 @@ func (h *Handler) HealthCheck(c *gin.Context) {
 	})
 }
 
+// ListCollections lists the vector store collections
+func (h *Handler) ListCollections(c *gin.Context) {
+	infos, err := h.searchEngine.ListCollections(c.Request.Context())
+	if err != nil {
+		h.logger.Printf("List collections failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list collections"})
+		return
+	}
+
+	collections := make([]gin.H, len(infos))
+	for i, info := range infos {
+		collections[i] = gin.H{
+			"name":         info.Name,
+			"vector_size":  info.VectorSize,
+			"distance":     info.Distance,
+			"ttl":          info.TTL.String(),
+			"sliding":      info.Sliding,
+			"items":        info.Items,
+			"documents":    info.Documents,
+			"vector_bytes": info.VectorBytes,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collections": collections,
+		"count":       len(collections),
+	})
+}
+
+// CreateCollection creates a vector store collection.
+// A missing ttl selects the service default; "0s" keeps items forever.
+// With sliding set, reading an item restarts its TTL.
+func (h *Handler) CreateCollection(c *gin.Context) {
+	var req struct {
+		Name     string  `json:"name" binding:"required"`
+		Distance string  `json:"distance"`
+		TTL      *string `json:"ttl"`
+		Sliding  bool    `json:"sliding"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	ttl := h.searchEngine.DefaultTTL()
+	if req.TTL != nil {
+		parsed, err := time.ParseDuration(*req.TTL)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ttl"})
+			return
+		}
+		ttl = parsed
+	}
+
+	err := h.searchEngine.CreateCollection(c.Request.Context(), &vectorstore.CollectionConfig{
+		Name:     req.Name,
+		Distance: vectorstore.Distance(req.Distance),
+		TTL:      ttl,
+		Sliding:  req.Sliding,
+	})
+	if err != nil {
+		h.logger.Printf("Create collection %s failed: %v", req.Name, err)
+		c.JSON(collectionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"name":    req.Name,
+		"ttl":     ttl.String(),
+		"sliding": req.Sliding,
+	})
+}
+
+// DropCollection removes a collection and everything indexed in it
+func (h *Handler) DropCollection(c *gin.Context) {
+	name := c.Param("name")
+
+	if err := h.searchEngine.DropCollection(c.Request.Context(), name); err != nil {
+		h.logger.Printf("Drop collection %s failed: %v", name, err)
+		c.JSON(collectionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":    name,
+		"dropped": true,
+	})
+}
+
+// Stats reports the size of the vector store and the embedding cache hits
+func (h *Handler) Stats(c *gin.Context) {
+	stats, err := h.searchEngine.Stats(c.Request.Context())
+	if err != nil {
+		h.logger.Printf("Get stats failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// Export streams every item of a collection, with its vector, as JSON Lines
+func (h *Handler) Export(c *gin.Context) {
+	collection := c.Query("collection")
+
+	filename := collection
+	if filename == "" {
+		filename = "export"
+	}
+	// A large collection takes longer to stream than the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Printf("Failed to lift the write deadline of an export: %v", err)
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".jsonl"))
+
+	count, err := h.searchEngine.Export(c.Request.Context(), collection, c.Writer)
+	if err != nil {
+		h.logger.Printf("Export of collection %q failed after %d items: %v", collection, count, err)
+		// Once streaming has started the status can no longer change
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "")
+			c.Header("Content-Disposition", "")
+			c.JSON(collectionErrorStatus(err), gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	h.logger.Printf("Exported %d items of collection %q", count, collection)
+}
+
+// Import stores the items of a JSON Lines export in a collection
+func (h *Handler) Import(c *gin.Context) {
+	collection := c.Query("collection")
+
+	// A large export takes longer to upload than the server's read timeout
+	if err := http.NewResponseController(c.Writer).SetReadDeadline(time.Time{}); err != nil {
+		h.logger.Printf("Failed to lift the read deadline of an import: %v", err)
+	}
+
+	count, err := h.searchEngine.Import(c.Request.Context(), collection, c.Request.Body)
+	if err != nil {
+		h.logger.Printf("Import into collection %q failed after %d items: %v", collection, count, err)
+		c.JSON(collectionErrorStatus(err), gin.H{
+			"error":    err.Error(),
+			"imported": count,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collection": collection,
+		"imported":   count,
+	})
+}
+
+// Changes streams the changes of the vector store as JSON Lines, one
+// change per line, until the client disconnects. The after query
+// parameter resumes from the sequence of the last change received.
+func (h *Handler) Changes(c *gin.Context) {
+	var after uint64
+	if value := c.Query("after"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "after must be a sequence number"})
+			return
+		}
+		after = parsed
+	}
+
+	ctx := c.Request.Context()
+	stream, err := h.searchEngine.Changes(ctx, after)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, vectorstore.ErrChangesLost):
+			status = http.StatusGone
+		case errors.Is(err, vectorstore.ErrChangeFeedDisabled):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Printf("Failed to lift the write deadline of a change stream: %v", err)
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	encoder := json.NewEncoder(c.Writer)
+	for {
+		change, err := stream.Next(ctx)
+		if err != nil {
+			// A lost or closed stream ends the response; the client resumes
+			// from its last sequence and learns why from the status
+			if ctx.Err() == nil {
+				h.logger.Printf("Change stream ended: %v", err)
+			}
+			return
+		}
+		if err := encoder.Encode(change); err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// Migration reports the progress of the migration to the next embedding
+// model
+func (h *Handler) Migration(c *gin.Context) {
+	status := h.searchEngine.Migration()
+	if status == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No embedding migration is configured"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
 // AtlassianLoginURL generates the login URL for Atlassian OAuth
 func (h *Handler) AtlassianLoginURL(c *gin.Context) {
 	// Log incoming headers and cookies for debugging
@@ -219,7 +448,16 @@ func (h *Handler) UploadDocument(c *gin.Context) {
 	permissions := []string{atlassianUser.AccountID, result.DocumentID}
 
 	// Index document for search
-	err = h.searchEngine.IndexDocument(c.Request.Context(), result, permissions)
+	collection := c.Query("collection")
+	err = h.searchEngine.IndexDocument(c.Request.Context(), collection, result, permissions)
+	if errors.Is(err, vectorstore.ErrCollectionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+	if errors.Is(err, vectorstore.ErrRecordTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Document is too large to store"})
+		return
+	}
 	if err != nil {
 		h.logger.Printf("Document indexing failed: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to index document"})
@@ -227,6 +465,7 @@ func (h *Handler) UploadDocument(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
+		"collection":  collection,
 		"document_id": result.DocumentID,
 		"title":       result.Title,
 		"chunks":      len(result.Content),
@@ -234,6 +473,267 @@ func (h *Handler) UploadDocument(c *gin.Context) {
 	})
 }
 
+// UpdateDocument re-processes an uploaded document and replaces its indexed chunks
+func (h *Handler) UpdateDocument(c *gin.Context) {
+	// Get user from context
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	atlassianUser, ok := user.(*auth.UserInfo)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user type"})
+		return
+	}
+
+	// Get document ID from path
+	documentID := c.Param("id")
+	if documentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Document ID is required"})
+		return
+	}
+
+	// Only users with access to an existing document may replace it
+	collection := c.Query("collection")
+	info, err := h.searchEngine.PeekDocument(c.Request.Context(), collection, documentID)
+	if errors.Is(err, vectorstore.ErrCollectionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+	if err != nil && !errors.Is(err, search.ErrDocumentNotFound) {
+		h.logger.Printf("Get document %s failed: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get document"})
+		return
+	}
+	if info != nil && !hasPermission(info.Permissions, atlassianUser.AccountID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access to document denied"})
+		return
+	}
+
+	// Get file from request
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+	defer file.Close()
+
+	// Process document
+	result, err := h.docProcessor.ProcessFile(c.Request.Context(), file, header)
+	if err != nil {
+		h.logger.Printf("Document processing failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process document"})
+		return
+	}
+	result.DocumentID = documentID
+
+	permissions := []string{atlassianUser.AccountID, result.DocumentID}
+
+	// Replace all chunks of the document
+	err = h.searchEngine.ReindexDocument(c.Request.Context(), collection, result, permissions)
+	if errors.Is(err, vectorstore.ErrRecordTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Document is too large to store"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("Document re-indexing failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to index document"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collection":  collection,
+		"document_id": result.DocumentID,
+		"title":       result.Title,
+		"chunks":      len(result.Content),
+		"metadata":    result.Metadata,
+	})
+}
+
+// DeleteDocument removes a document and all of its chunks from the index
+func (h *Handler) DeleteDocument(c *gin.Context) {
+	// Get user from context
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	atlassianUser, ok := user.(*auth.UserInfo)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user type"})
+		return
+	}
+
+	// Get document ID from path
+	documentID := c.Param("id")
+	if documentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Document ID is required"})
+		return
+	}
+
+	collection := c.Query("collection")
+	info, err := h.searchEngine.PeekDocument(c.Request.Context(), collection, documentID)
+	if errors.Is(err, vectorstore.ErrCollectionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+	if errors.Is(err, search.ErrDocumentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("Get document %s failed: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get document"})
+		return
+	}
+
+	if !hasPermission(info.Permissions, atlassianUser.AccountID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access to document denied"})
+		return
+	}
+
+	if err := h.searchEngine.DeleteDocument(c.Request.Context(), collection, documentID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete document"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collection":  collection,
+		"document_id": documentID,
+		"deleted":     true,
+	})
+}
+
+// GetDocumentRetention reports the retention and expiry of a document
+func (h *Handler) GetDocumentRetention(c *gin.Context) {
+	info, ok := h.userDocument(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, retentionResponse(info))
+}
+
+// UpdateDocumentRetention changes the retention of a document. Fields left
+// out of the request keep their current value; changing the policy or ttl
+// without an expires_at restarts the expiry from now.
+func (h *Handler) UpdateDocumentRetention(c *gin.Context) {
+	var req struct {
+		Policy    *string    `json:"policy"`
+		TTL       *string    `json:"ttl"`
+		Pinned    *bool      `json:"pinned"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	update := &search.RetentionUpdate{
+		Pinned:    req.Pinned,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if req.Policy != nil {
+		policy := vectorstore.RetentionPolicy(*req.Policy)
+		update.Policy = &policy
+	}
+	if req.TTL != nil {
+		ttl, err := time.ParseDuration(*req.TTL)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ttl"})
+			return
+		}
+		update.TTL = &ttl
+	}
+
+	info, ok := h.userDocument(c)
+	if !ok {
+		return
+	}
+
+	info, err := h.searchEngine.UpdateRetention(c.Request.Context(), info.Collection, info.DocumentID, update)
+	switch {
+	case errors.Is(err, vectorstore.ErrInvalidRetention):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, vectorstore.ErrNotFound), errors.Is(err, search.ErrDocumentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update retention"})
+		return
+	}
+
+	c.JSON(http.StatusOK, retentionResponse(info))
+}
+
+// userDocument looks up the document named by the request path and
+// collection query for the authenticated user. It writes the error
+// response and returns false if the document cannot be found or the user
+// has no access to it.
+func (h *Handler) userDocument(c *gin.Context) (*search.DocumentInfo, bool) {
+	// Get user from context
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	atlassianUser, ok := user.(*auth.UserInfo)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user type"})
+		return nil, false
+	}
+
+	documentID := c.Param("id")
+	if documentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Document ID is required"})
+		return nil, false
+	}
+
+	info, err := h.searchEngine.PeekDocument(c.Request.Context(), c.Query("collection"), documentID)
+	if errors.Is(err, vectorstore.ErrCollectionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return nil, false
+	}
+	if errors.Is(err, search.ErrDocumentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Printf("Get document %s failed: %v", documentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get document"})
+		return nil, false
+	}
+
+	if !hasPermission(info.Permissions, atlassianUser.AccountID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access to document denied"})
+		return nil, false
+	}
+
+	return info, true
+}
+
+// retentionResponse describes the retention of a document
+func retentionResponse(info *search.DocumentInfo) gin.H {
+	response := gin.H{
+		"collection":  info.Collection,
+		"document_id": info.DocumentID,
+		"policy":      info.Retention.Policy,
+		"ttl":         info.Retention.TTL.String(),
+		"pinned":      info.Retention.Pinned,
+		"expires_at":  nil,
+	}
+	if !info.ExpiresAt.IsZero() {
+		response["expires_at"] = info.ExpiresAt.Format(time.RFC3339)
+	}
+	return response
+}
+
 // Search handles semantic search requests
 func (h *Handler) Search(c *gin.Context) {
 	// Get user from context
@@ -251,8 +751,17 @@ func (h *Handler) Search(c *gin.Context) {
 
 	// Parse search request
 	var req struct {
-		Query string `json:"query" binding:"required"`
-		Limit int    `json:"limit"`
+		Query       string              `json:"query" binding:"required"`
+		Collections []string            `json:"collections"`
+		Limit       int                 `json:"limit"`
+		Offset      int                 `json:"offset"`
+		Cursor      string              `json:"cursor"`
+		MinScore    *float64            `json:"min_score"`
+		Filter      *vectorstore.Filter `json:"filter"`
+		MMR         *struct {
+			Lambda         *float64 `json:"lambda"`
+			MaxPerDocument int      `json:"max_per_document"`
+		} `json:"mmr"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -260,19 +769,61 @@ func (h *Handler) Search(c *gin.Context) {
 		return
 	}
 
+	if req.Offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Offset must not be negative"})
+		return
+	}
+
+	if req.Filter != nil {
+		if err := req.Filter.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var mmr *vectorstore.MMR
+	if req.MMR != nil {
+		mmr = &vectorstore.MMR{Lambda: vectorstore.DefaultMMRLambda, MaxPerDocument: req.MMR.MaxPerDocument}
+		if req.MMR.Lambda != nil {
+			mmr.Lambda = *req.MMR.Lambda
+		}
+		if err := mmr.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// Get user permissions
 	// In a real implementation, you would fetch actual permissions from Atlassian
 	// For POC, we'll use a simple approach
 	permissions := []string{atlassianUser.AccountID}
 
 	// Perform search
-	results, err := h.searchEngine.Search(c.Request.Context(), &search.SearchRequest{
+	page, err := h.searchEngine.Search(c.Request.Context(), &search.SearchRequest{
 		Query:       req.Query,
+		Collections: req.Collections,
 		UserID:      atlassianUser.AccountID,
 		Permissions: permissions,
 		Limit:       req.Limit,
+		Filter:      req.Filter,
+		Cursor:      req.Cursor,
+		Offset:      req.Offset,
+		MinScore:    req.MinScore,
+		MMR:         mmr,
 	})
 
+	if errors.Is(err, vectorstore.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if errors.Is(err, vectorstore.ErrInvalidMMR) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, vectorstore.ErrCollectionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		h.logger.Printf("Search failed: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
@@ -280,9 +831,10 @@ func (h *Handler) Search(c *gin.Context) {
 	}
 
 	// Format results
-	formattedResults := make([]gin.H, len(results))
-	for i, result := range results {
+	formattedResults := make([]gin.H, len(page.Results))
+	for i, result := range page.Results {
 		formattedResults[i] = gin.H{
+			"collection":  result.Collection,
 			"document_id": result.DocumentID,
 			"title":       result.Title,
 			"content":     result.ChunkContent,
@@ -292,8 +844,9 @@ func (h *Handler) Search(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"results": formattedResults,
-		"count":   len(results),
+		"results":     formattedResults,
+		"count":       len(page.Results),
+		"next_cursor": page.NextCursor,
 	})
 }
 
@@ -424,8 +977,13 @@ func (h *Handler) ProcessConfluencePage(c *gin.Context) {
 	// Add user ID to permissions
 	permissions = append(permissions, atlassianUser.AccountID)
 
-	// Index document for search
-	err = h.searchEngine.IndexDocument(c.Request.Context(), result, permissions)
+	// Index page for search, replacing chunks from earlier processing
+	collection := c.Query("collection")
+	err = h.searchEngine.ReindexDocument(c.Request.Context(), collection, result, permissions)
+	if errors.Is(err, vectorstore.ErrCollectionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
 	if err != nil {
 		h.logger.Printf("Page indexing failed: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to index page"})
@@ -551,3 +1109,32 @@ func (h *Handler) TestTokenExchange(c *gin.Context) {
 func (h *Handler) SessionManager() *session.SessionManager {
 	return h.sessionManager
 }
+
+// hasPermission reports whether a permission list grants access to principal
+func hasPermission(permissions []string, principal string) bool {
+	for _, permission := range permissions {
+		if permission == principal {
+			return true
+		}
+	}
+	return false
+}
+
+// collectionErrorStatus maps vector store collection errors onto HTTP status codes
+func collectionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, vectorstore.ErrCollectionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, vectorstore.ErrCollectionExists):
+		return http.StatusConflict
+	case errors.Is(err, vectorstore.ErrInvalidCollection),
+		errors.Is(err, vectorstore.ErrInvalidRecord),
+		errors.Is(err, vectorstore.ErrInvalidRetention),
+		errors.Is(err, vectorstore.ErrVectorSize):
+		return http.StatusBadRequest
+	case errors.Is(err, vectorstore.ErrRecordTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
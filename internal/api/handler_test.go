@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sanjeevkumarraob/semantic-search-service/internal/auth"
+	"github.com/sanjeevkumarraob/semantic-search-service/internal/document"
+	"github.com/sanjeevkumarraob/semantic-search-service/internal/search"
+	"github.com/sanjeevkumarraob/semantic-search-service/pkg/vectorstore"
+)
+
+func TestDeleteDocument(t *testing.T) {
+	handler, engine := newTestHandler(t)
+	doc := &document.ProcessorResult{DocumentID: "doc-1", Title: "title", Content: []string{"first chunk", "second chunk"}}
+	if err := engine.IndexDocument(context.Background(), "", doc, []string{"acc-1"}); err != nil {
+		t.Fatalf("IndexDocument failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		path   string
+		want   int
+	}{
+		{"other user", "acc-2", http.MethodDelete, "/documents/doc-1", http.StatusForbidden},
+		{"missing collection", "acc-1", http.MethodDelete, "/documents/doc-1?collection=missing", http.StatusNotFound},
+		{"owner", "acc-1", http.MethodDelete, "/documents/doc-1", http.StatusOK},
+		{"deleted document", "acc-1", http.MethodDelete, "/documents/doc-1", http.StatusNotFound},
+		{"retention of a deleted document", "acc-1", http.MethodGet, "/documents/doc-1/retention", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		if got := serve(handler, tt.user, tt.method, tt.path); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAccessCheckKeepsSlidingExpiry(t *testing.T) {
+	ctx := context.Background()
+	handler, engine := newTestHandler(t)
+	doc := &document.ProcessorResult{DocumentID: "doc-1", Title: "title", Content: []string{"only chunk"}}
+	if err := engine.IndexDocument(ctx, "", doc, []string{"acc-1"}); err != nil {
+		t.Fatalf("IndexDocument failed: %v", err)
+	}
+	policy, ttl := vectorstore.RetentionSliding, time.Second
+	before, err := engine.UpdateRetention(ctx, "", "doc-1", &search.RetentionUpdate{Policy: &policy, TTL: &ttl})
+	if err != nil {
+		t.Fatalf("UpdateRetention failed: %v", err)
+	}
+	// Sliding items are refreshed once a hundredth of their TTL has passed
+	time.Sleep(20 * time.Millisecond)
+
+	// Neither a denied delete nor reading the retention counts as a use
+	if got := serve(handler, "acc-2", http.MethodDelete, "/documents/doc-1"); got != http.StatusForbidden {
+		t.Fatalf("delete by another user: status = %d, want %d", got, http.StatusForbidden)
+	}
+	if got := serve(handler, "acc-1", http.MethodGet, "/documents/doc-1/retention"); got != http.StatusOK {
+		t.Fatalf("get retention: status = %d, want %d", got, http.StatusOK)
+	}
+	after, err := engine.PeekDocument(ctx, "", "doc-1")
+	if err != nil {
+		t.Fatalf("PeekDocument failed: %v", err)
+	}
+	if !after.ExpiresAt.Equal(before.ExpiresAt) {
+		t.Errorf("access checks moved the expiry from %v to %v", before.ExpiresAt, after.ExpiresAt)
+	}
+
+	// Getting the document does
+	got, err := engine.GetDocument(ctx, "", "doc-1")
+	if err != nil {
+		t.Fatalf("GetDocument failed: %v", err)
+	}
+	if !got.ExpiresAt.After(before.ExpiresAt) {
+		t.Errorf("GetDocument kept the expiry at %v, want it refreshed", got.ExpiresAt)
+	}
+}
+
+// newTestHandler creates a handler over a search engine with an in-memory
+// store; its Atlassian clients are not set
+func newTestHandler(t *testing.T) (*Handler, *search.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	logger := log.New(io.Discard, "", 0)
+	engine, err := search.NewEngine(&search.Config{}, logger)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	t.Cleanup(engine.Cleanup)
+
+	return NewHandler(nil, nil, nil, nil, engine, logger, nil), engine
+}
+
+// serve routes a request from the given account to the document handlers
+// and returns the response status
+func serve(handler *Handler, accountID, method, path string) int {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user", &auth.UserInfo{AccountID: accountID})
+	})
+	router.DELETE("/documents/:id", handler.DeleteDocument)
+	router.GET("/documents/:id/retention", handler.GetDocumentRetention)
+	router.PUT("/documents/:id/retention", handler.UpdateDocumentRetention)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+	return recorder.Code
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -163,6 +164,37 @@ func AuthMiddleware(atlassianAuth *auth.AtlassianAuth, store *sessions.CookieSto
 	}
 }
 
+// AdminMiddleware lets through only the users whose Atlassian account ID
+// or email is listed in admins, compared case-insensitively. With no admins
+// every request is forbidden. It must run after AuthMiddleware.
+func AdminMiddleware(admins []string) gin.HandlerFunc {
+	allowed := make(map[string]struct{}, len(admins))
+	for _, admin := range admins {
+		if admin = strings.TrimSpace(admin); admin != "" {
+			allowed[strings.ToLower(admin)] = struct{}{}
+		}
+	}
+
+	return func(c *gin.Context) {
+		value, _ := c.Get("user")
+		user, ok := value.(*auth.UserInfo)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		_, isAccount := allowed[strings.ToLower(user.AccountID)]
+		_, isEmail := allowed[strings.ToLower(user.Email)]
+		if !isAccount && !isEmail {
+			log.Printf("AdminMiddleware - Denied %s %s to account %s", c.Request.Method, c.Request.URL.Path, user.AccountID)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // RateLimitMiddleware implements basic rate limiting
 func RateLimitMiddleware() gin.HandlerFunc {
 	// In a real implementation, you would use something like Redis
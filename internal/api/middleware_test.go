@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sanjeevkumarraob/semantic-search-service/internal/auth"
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		admins []string
+		user   *auth.UserInfo
+		want   int
+	}{
+		{"listed account", []string{"acc-1"}, &auth.UserInfo{AccountID: "acc-1"}, http.StatusOK},
+		{"listed email", []string{" Admin@Example.com "}, &auth.UserInfo{AccountID: "acc-2", Email: "admin@example.com"}, http.StatusOK},
+		{"other user", []string{"acc-1"}, &auth.UserInfo{AccountID: "acc-2", Email: "user@example.com"}, http.StatusForbidden},
+		{"no admins", nil, &auth.UserInfo{AccountID: "acc-1"}, http.StatusForbidden},
+		{"empty email", []string{"", "acc-1"}, &auth.UserInfo{AccountID: "acc-2"}, http.StatusForbidden},
+		{"no user", []string{"acc-1"}, nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.user != nil {
+					c.Set("user", tt.user)
+				}
+			})
+			router.GET("/admin", AdminMiddleware(tt.admins), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin", nil))
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
@@ -17,7 +17,8 @@ import (
 	"github.com/sanjeevkumarraob/semantic-search-service/internal/session"
 )
 
-// NewRouter sets up the API router
+// NewRouter sets up the API router. Admin endpoints are served only to the
+// Atlassian accounts, by ID or email, listed in admins.
 func NewRouter(
 	atlassianAuth *auth.AtlassianAuth,
 	confluenceClient *atlassian.ConfluenceClient,
@@ -25,6 +26,7 @@ func NewRouter(
 	docProcessor *document.Processor,
 	searchEngine *search.Engine,
 	logger *log.Logger,
+	admins []string,
 ) *gin.Engine {
 	// Create gin router
 	router := gin.New()
@@ -121,12 +123,23 @@ func NewRouter(
 	// Auth required routes
 	authorized := router.Group("/api")
 	authorized.Use(AuthMiddleware(atlassianAuth, store))
+	requireAdmin := AdminMiddleware(admins)
 	{
 		// Document endpoints
 		authorized.POST("/documents/upload", handler.UploadDocument)
+		authorized.PUT("/documents/:id", handler.UpdateDocument)
+		authorized.DELETE("/documents/:id", handler.DeleteDocument)
+		authorized.GET("/documents/:id/retention", handler.GetDocumentRetention)
+		authorized.PUT("/documents/:id/retention", handler.UpdateDocumentRetention)
 
 		// Search endpoints
 		authorized.POST("/search", handler.Search)
+		authorized.GET("/stats", handler.Stats)
+
+		// Collection endpoints
+		authorized.GET("/collections", handler.ListCollections)
+		authorized.POST("/collections", requireAdmin, handler.CreateCollection)
+		authorized.DELETE("/collections/:name", requireAdmin, handler.DropCollection)
 
 		// Confluence endpoints
 		authorized.GET("/confluence/spaces", handler.ListConfluenceSpaces)
@@ -138,5 +151,15 @@ func NewRouter(
 		authorized.POST("/jira/ticket", handler.CreateJiraTicket)
 	}
 
+	// Admin routes
+	admin := authorized.Group("/admin")
+	admin.Use(requireAdmin)
+	{
+		admin.GET("/export", handler.Export)
+		admin.POST("/import", handler.Import)
+		admin.GET("/changes", handler.Changes)
+		admin.GET("/migration", handler.Migration)
+	}
+
 	return router
 }
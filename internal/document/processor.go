@@ -6,6 +6,7 @@ import (
 	"log"
 	"mime/multipart"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -99,8 +100,9 @@ func (p *Processor) ProcessFile(ctx context.Context, file multipart.File, header
 		Title:      filepath.Base(header.Filename),
 		Content:    content,
 		Metadata: map[string]string{
+			"source":      "upload",
 			"filename":    header.Filename,
-			"size":        string(header.Size),
+			"size":        strconv.FormatInt(header.Size, 10),
 			"contentType": string(contentType),
 		},
 	}
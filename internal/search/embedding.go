@@ -2,27 +2,189 @@ package search
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
 	"math/rand"
+	"time"
 )
 
-// Embedder generates vector embeddings from text
-type Embedder struct {
+// EmbeddingProvider selects an embedder implementation
+type EmbeddingProvider string
+
+const (
+	// ProviderFake makes seeded random vectors from the text. They carry no
+	// meaning and are only fit for tests.
+	ProviderFake EmbeddingProvider = "fake"
+	// ProviderHashing hashes words and character n-grams into the vector.
+	// It needs no model and ranks texts by the terms they share.
+	ProviderHashing EmbeddingProvider = "hashing"
+	// ProviderOpenAI calls an OpenAI-compatible /embeddings endpoint
+	ProviderOpenAI EmbeddingProvider = "openai"
+	// ProviderOllama calls the /api/embed endpoint of an Ollama server
+	ProviderOllama EmbeddingProvider = "ollama"
+	// ProviderBERT runs a local GGML sentence-transformer model on the CPU.
+	// It is only available in builds with the bert tag.
+	ProviderBERT EmbeddingProvider = "bert"
+)
+
+// defaultFakeVectorSize is the size of fake embeddings when none is configured
+const defaultFakeVectorSize = 384
+
+// Error definitions
+var (
+	ErrEmbeddingDimension = errors.New("embedding dimension mismatch")
+	ErrEmbeddingResponse  = errors.New("invalid embedding response")
+)
+
+// Embedder generates the vector embeddings of search queries and of the
+// documents they are matched against, which some models embed differently
+type Embedder interface {
+	// EmbedQuery generates the embedding of a search query
+	EmbedQuery(ctx context.Context, query string) ([]float32, error)
+	// EmbedDocuments generates the embeddings of document passages, in order
+	EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error)
+	// VectorSize returns the dimensionality of the embeddings
+	VectorSize() int
+	// ModelID identifies the model making the embeddings. Embeddings of
+	// different models are not comparable.
+	ModelID() string
+	// Close releases the resources held by the embedder
+	Close() error
+}
+
+// TextEmbedder generates vector embeddings of text as given. Each provider
+// implements it; NewEmbedder adds the templates of queries and documents.
+type TextEmbedder interface {
+	// Embed generates the embedding of one text
+	Embed(ctx context.Context, text string) ([]float32, error)
+	// EmbedBatch generates embeddings for several texts, in order
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+	// VectorSize returns the dimensionality of the embeddings
+	VectorSize() int
+	// ModelID identifies the model making the embeddings
+	ModelID() string
+	// Close releases the resources held by the embedder
+	Close() error
+}
+
+// EmbedderConfig selects and configures the embedding provider
+type EmbedderConfig struct {
+	// Provider defaults to the fake embedder
+	Provider EmbeddingProvider
+	// URL is the base URL of the provider's API, such as
+	// https://api.openai.com/v1 or http://localhost:11434
+	URL    string
+	Model  string
+	APIKey string
+	// ModelVersion tags the embeddings along with the model ID. Raise it
+	// when the model changes under the same name, or to re-embed vectors
+	// stored before they were tagged with their model, which searches
+	// leave out.
+	ModelVersion int
+	// Dimensions is the expected vector size. Zero learns it from the
+	// provider on startup; the fake and hashing embedders use 384.
+	Dimensions int
+	// BatchSize caps the texts sent in one request
+	BatchSize int
+	// Timeout bounds each request
+	Timeout time.Duration
+	// MaxRetries is the number of retries of a request that failed with a
+	// network error, a rate limit or a server error; zero retries 3 times
+	// and a negative number does not retry
+	MaxRetries int
+	// ModelPath is the GGML model file of the bert provider
+	ModelPath string
+	// Threads is the number of CPU threads of each bert inference; zero
+	// uses one per CPU. The model is loaded once for every Threads CPUs,
+	// so that as many inferences run at once.
+	Threads int
+	// CacheSize is the number of embeddings cached in memory. Zero caches
+	// 10000; a negative size disables the cache.
+	CacheSize int
+	// CacheDir keeps cached embeddings on disk across restarts; empty
+	// caches in memory only
+	CacheDir string
+	// QueryTemplate and DocumentTemplate wrap queries and document passages
+	// before they are embedded. {text} stands for the text; a template
+	// without it is a prefix. Empty templates use those known for the
+	// model, such as "query: " and "passage: " for E5, and "{text}" embeds
+	// the text as it is. Raise ModelVersion after changing them.
+	QueryTemplate    string
+	DocumentTemplate string
+	// MaxTokens truncates texts so that, wrapped in their template, they
+	// come to about this many tokens; zero uses the limit known for the
+	// model, if any
+	MaxTokens int
+}
+
+// NewEmbedder creates the embedder selected by config.Provider, behind a
+// cache unless it is disabled, and wraps queries and documents in the
+// templates of the model
+func NewEmbedder(config *EmbedderConfig) (Embedder, error) {
+	var embedder TextEmbedder
+	var err error
+	switch config.Provider {
+	case "", ProviderFake:
+		embedder = NewFakeEmbedder(config.Dimensions)
+	case ProviderHashing:
+		embedder = NewHashingEmbedder(config.Dimensions)
+	case ProviderOpenAI, ProviderOllama:
+		embedder, err = NewHTTPEmbedder(config)
+	case ProviderBERT:
+		embedder, err = NewBertEmbedder(config)
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q", config.Provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if config.CacheSize >= 0 {
+		cached, err := NewCachedEmbedder(embedder, config)
+		if err != nil {
+			embedder.Close()
+			return nil, err
+		}
+		embedder = cached
+	}
+
+	return NewPromptedEmbedder(embedder, config), nil
+}
+
+// checkEmbeddings verifies that a provider returned one embedding of the
+// expected size per text
+func checkEmbeddings(embeddings [][]float32, texts, vectorSize int) error {
+	if len(embeddings) != texts {
+		return fmt.Errorf("%w: got %d embeddings for %d texts", ErrEmbeddingResponse, len(embeddings), texts)
+	}
+	for i, embedding := range embeddings {
+		if len(embedding) != vectorSize {
+			return fmt.Errorf("%w: embedding %d has %d dimensions, want %d", ErrEmbeddingDimension, i, len(embedding), vectorSize)
+		}
+	}
+	return nil
+}
+
+// FakeEmbedder generates seeded random vectors from the text. Equal texts
+// get equal vectors, but similar texts do not get similar ones.
+type FakeEmbedder struct {
 	vectorSize int
 }
 
-// NewEmbedder creates a new embedder instance
-func NewEmbedder() *Embedder {
-	// For POC, we'll use a simple random embedding approach
-	// In production, you would use a proper embedding model
+// NewFakeEmbedder creates a fake embedder; a vector size of zero uses 384
+func NewFakeEmbedder(vectorSize int) *FakeEmbedder {
+	if vectorSize <= 0 {
+		vectorSize = defaultFakeVectorSize
+	}
 
-	return &Embedder{
-		vectorSize: 384, // Standard embedding size
+	return &FakeEmbedder{
+		vectorSize: vectorSize,
 	}
 }
 
 // Embed generates a vector embedding for the given text
-func (e *Embedder) Embed(ctx context.Context, text string) ([]float32, error) {
+func (e *FakeEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
 	// Check context cancellation
 	select {
 	case <-ctx.Done():
@@ -30,8 +192,6 @@ func (e *Embedder) Embed(ctx context.Context, text string) ([]float32, error) {
 	default:
 	}
 
-	// For POC, we'll generate random embeddings based on the text
-	// This is a placeholder for a real embedding model
 	embedding := make([]float32, e.vectorSize)
 
 	// Use the text as a seed for reproducibility
@@ -63,12 +223,32 @@ func (e *Embedder) Embed(ctx context.Context, text string) ([]float32, error) {
 	return embedding, nil
 }
 
+// EmbedBatch generates embeddings for several texts, in order
+func (e *FakeEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embedding, err := e.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = embedding
+	}
+
+	return embeddings, nil
+}
+
 // VectorSize returns the dimensionality of the embeddings
-func (e *Embedder) VectorSize() int {
+func (e *FakeEmbedder) VectorSize() int {
 	return e.vectorSize
 }
 
+// ModelID identifies the fake model by its vector size
+func (e *FakeEmbedder) ModelID() string {
+	return fmt.Sprintf("fake-%d", e.vectorSize)
+}
+
 // Close releases resources
-func (e *Embedder) Close() {
+func (e *FakeEmbedder) Close() error {
 	// No resources to release
+	return nil
 }
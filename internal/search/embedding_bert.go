@@ -0,0 +1,178 @@
+//go:build bert
+
+package search
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	bert "github.com/go-skynet/go-bert.cpp"
+)
+
+// BertEmbedder runs a GGML sentence-transformer model on the CPU through
+// go-bert.cpp. A model context runs one inference at a time, spread over
+// EmbedderConfig.Threads threads, so the model is loaded into one context
+// for each share of that many CPUs and inferences run concurrently, one
+// per context.
+type BertEmbedder struct {
+	// models holds the idle model contexts
+	models     chan *bert.Bert
+	contexts   int
+	modelID    string
+	threads    int
+	vectorSize int
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewBertEmbedder loads the model at EmbedderConfig.ModelPath into one
+// context per share of the CPUs
+func NewBertEmbedder(config *EmbedderConfig) (TextEmbedder, error) {
+	if config.ModelPath == "" {
+		return nil, fmt.Errorf("%s embedding provider requires a model path", ProviderBERT)
+	}
+
+	threads := config.Threads
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+	contexts := max(runtime.NumCPU()/threads, 1)
+
+	// go-bert.cpp fills a buffer of the embedding size it is given, so the
+	// model's own size is read from its header rather than from an output
+	vectorSize, err := bertModelDimensions(config.ModelPath)
+	if err != nil {
+		return nil, err
+	}
+	if config.Dimensions > 0 && config.Dimensions != vectorSize {
+		return nil, fmt.Errorf("%w: bert model %s has %d dimensions, configured %d",
+			ErrEmbeddingDimension, config.ModelPath, vectorSize, config.Dimensions)
+	}
+
+	e := &BertEmbedder{
+		models:     make(chan *bert.Bert, contexts),
+		contexts:   contexts,
+		modelID:    fmt.Sprintf("%s/%s", ProviderBERT, filepath.Base(config.ModelPath)),
+		threads:    threads,
+		vectorSize: vectorSize,
+		done:       make(chan struct{}),
+	}
+
+	for i := 0; i < contexts; i++ {
+		model, err := bert.New(config.ModelPath)
+		if err != nil {
+			e.contexts = i
+			e.Close()
+			return nil, fmt.Errorf("failed to load bert model %s: %w", config.ModelPath, err)
+		}
+		e.models <- model
+	}
+
+	return e, nil
+}
+
+// Embed generates the embedding of one text, waiting for an idle model
+// context
+func (e *BertEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	var model *bert.Bert
+	select {
+	case model = <-e.models:
+	case <-e.done:
+		return nil, fmt.Errorf("bert embedder is closed")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { e.models <- model }()
+
+	// Close may have started while the context was taken
+	select {
+	case <-e.done:
+		return nil, fmt.Errorf("bert embedder is closed")
+	default:
+	}
+
+	embedding, err := model.Embeddings(text, bert.SetThreads(e.threads), bert.SetEmbeddingSize(e.vectorSize))
+	if err != nil {
+		return nil, fmt.Errorf("bert inference failed: %w", err)
+	}
+
+	return padEmbedding(embedding, e.vectorSize)
+}
+
+// EmbedBatch generates embeddings for several texts, in order. The texts
+// are embedded concurrently, one per model context; the first failure
+// cancels the rest.
+func (e *BertEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	embeddings := make([][]float32, len(texts))
+	next := make(chan int)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for w := 0; w < min(e.contexts, len(texts)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				embedding, err := e.Embed(ctx, texts[i])
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				embeddings[i] = embedding
+			}
+		}()
+	}
+
+	for i := range texts {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return embeddings, nil
+}
+
+// VectorSize returns the dimensionality of the model's embeddings
+func (e *BertEmbedder) VectorSize() int {
+	return e.vectorSize
+}
+
+// ModelID identifies the model by its file name
+func (e *BertEmbedder) ModelID() string {
+	return e.modelID
+}
+
+// Close frees the model contexts once running inferences finish
+func (e *BertEmbedder) Close() error {
+	e.closeOnce.Do(func() {
+		close(e.done)
+		for range e.contexts {
+			(<-e.models).Free()
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package search
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// ggmlMagic starts every GGML model file
+const ggmlMagic = 0x67676d6c
+
+// bertModelDimensions reads the embedding size from the header of a GGML
+// bert model. The magic number is followed by the model's hyperparameters
+// as little-endian int32s: the vocabulary size, the maximum number of
+// tokens and then the embedding size.
+func bertModelDimensions(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open bert model %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var header struct {
+		Magic     uint32
+		Vocab     int32
+		MaxTokens int32
+		Embedding int32
+	}
+	if err := binary.Read(file, binary.LittleEndian, &header); err != nil {
+		return 0, fmt.Errorf("failed to read the header of bert model %s: %w", path, err)
+	}
+	if header.Magic != ggmlMagic {
+		return 0, fmt.Errorf("bert model %s is not a GGML model", path)
+	}
+	if header.Embedding <= 0 {
+		return 0, fmt.Errorf("bert model %s has a bad embedding size %d", path, header.Embedding)
+	}
+
+	return int(header.Embedding), nil
+}
+
+// padEmbedding restores the trailing zeros go-bert.cpp strips from an
+// embedding, so that valid embeddings ending in zero keep the model's size
+func padEmbedding(embedding []float32, vectorSize int) ([]float32, error) {
+	if len(embedding) > vectorSize {
+		return nil, fmt.Errorf("%w: got %d dimensions, want %d", ErrEmbeddingDimension, len(embedding), vectorSize)
+	}
+	if len(embedding) == vectorSize {
+		return embedding, nil
+	}
+
+	padded := make([]float32, vectorSize)
+	copy(padded, embedding)
+	return padded, nil
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBertModelDimensions(t *testing.T) {
+	dir := t.TempDir()
+	writeModel := func(name string, header ...interface{}) string {
+		path := filepath.Join(dir, name)
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("failed to create model: %v", err)
+		}
+		defer file.Close()
+		for _, value := range header {
+			if err := binary.Write(file, binary.LittleEndian, value); err != nil {
+				t.Fatalf("failed to write model: %v", err)
+			}
+		}
+		return path
+	}
+
+	// magic, n_vocab, n_max_tokens, n_embd, n_intermediate, n_head, n_layer, f16
+	model := writeModel("minilm.bin", uint32(ggmlMagic), int32(30522), int32(512), int32(384), int32(1536), int32(12), int32(6), int32(1))
+	dimensions, err := bertModelDimensions(model)
+	if err != nil {
+		t.Fatalf("bertModelDimensions failed: %v", err)
+	}
+	if dimensions != 384 {
+		t.Errorf("bertModelDimensions = %d, want 384", dimensions)
+	}
+
+	for name, path := range map[string]string{
+		"not ggml":  writeModel("model.onnx", uint32(0x12345678), int32(1), int32(1), int32(384)),
+		"truncated": writeModel("short.bin", uint32(ggmlMagic), int32(30522)),
+		"missing":   filepath.Join(dir, "missing.bin"),
+	} {
+		if _, err := bertModelDimensions(path); err == nil {
+			t.Errorf("bertModelDimensions of a %s model succeeded", name)
+		}
+	}
+}
+
+func TestPadEmbedding(t *testing.T) {
+	// go-bert.cpp strips the trailing zeros of an embedding
+	padded, err := padEmbedding([]float32{0.5, -0.5}, 4)
+	if err != nil {
+		t.Fatalf("padEmbedding failed: %v", err)
+	}
+	if !slices.Equal(padded, []float32{0.5, -0.5, 0, 0}) {
+		t.Errorf("padEmbedding = %v, want [0.5 -0.5 0 0]", padded)
+	}
+
+	full := []float32{1, 2, 3}
+	if got, err := padEmbedding(full, 3); err != nil || !slices.Equal(got, full) {
+		t.Errorf("padEmbedding of a full embedding = %v, %v", got, err)
+	}
+	if _, err := padEmbedding(full, 2); !errors.Is(err, ErrEmbeddingDimension) {
+		t.Errorf("padEmbedding of a long embedding returned %v, want ErrEmbeddingDimension", err)
+	}
+}
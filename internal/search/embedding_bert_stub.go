@@ -0,0 +1,17 @@
+//go:build !bert
+
+package search
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBertUnavailable is returned by binaries built without the bert tag,
+// which links go-bert.cpp and needs its C++ library
+var ErrBertUnavailable = errors.New("bert embeddings require a build with the bert tag")
+
+// NewBertEmbedder reports that this binary cannot run bert models
+func NewBertEmbedder(config *EmbedderConfig) (TextEmbedder, error) {
+	return nil, fmt.Errorf("%s embedding provider: %w", ProviderBERT, ErrBertUnavailable)
+}
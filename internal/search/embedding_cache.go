@@ -0,0 +1,345 @@
+package search
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sanjeevkumarraob/semantic-search-service/pkg/vectorstore"
+	bolt "go.etcd.io/bbolt"
+)
+
+// defaultEmbeddingCacheSize is the number of embeddings kept in memory
+// when EmbedderConfig.CacheSize is zero
+const defaultEmbeddingCacheSize = 10000
+
+// embeddingCacheFile is the database of the on-disk tier
+const embeddingCacheFile = "embeddings.db"
+
+// Buckets of the on-disk tier
+var (
+	embeddingCacheBucket = []byte("embeddings")
+	embeddingMetaBucket  = []byte("meta")
+	embeddingModelKey    = []byte("model")
+)
+
+// EmbeddingCacheStats counts the lookups of the embedding cache
+type EmbeddingCacheStats struct {
+	Model      string `json:"model"`
+	Entries    int    `json:"entries"`
+	MemoryHits int64  `json:"memory_hits"`
+	DiskHits   int64  `json:"disk_hits"`
+	Misses     int64  `json:"misses"`
+}
+
+// embeddingKey addresses an embedding by the model and normalized text
+type embeddingKey [sha256.Size]byte
+
+// CachedEmbedder keeps the embeddings of an embedder, keyed by a hash of
+// its model ID and version and the normalized text, so that unchanged text
+// is not embedded again. Embeddings are kept in an in-memory LRU and, when
+// a cache directory is configured, in a database on disk that outlives
+// the process. The disk tier is cleared when the model changes.
+type CachedEmbedder struct {
+	embedder TextEmbedder
+	model    string // model ID and version
+	size     int
+	db       *bolt.DB // nil without a disk tier
+
+	lock    sync.Mutex
+	order   *list.List // of *embeddingEntry, most recently used first
+	entries map[embeddingKey]*list.Element
+
+	memoryHits atomic.Int64
+	diskHits   atomic.Int64
+	misses     atomic.Int64
+}
+
+// embeddingEntry is an embedding in the in-memory tier
+type embeddingEntry struct {
+	key       embeddingKey
+	embedding []float32
+}
+
+// NewCachedEmbedder puts a cache in front of an embedder, sized by
+// config.CacheSize and kept on disk in config.CacheDir
+func NewCachedEmbedder(embedder TextEmbedder, config *EmbedderConfig) (*CachedEmbedder, error) {
+	size := config.CacheSize
+	if size <= 0 {
+		size = defaultEmbeddingCacheSize
+	}
+
+	c := &CachedEmbedder{
+		embedder: embedder,
+		model:    vectorstore.EmbeddingModel{ID: embedder.ModelID(), Version: config.ModelVersion}.String(),
+		size:     size,
+		order:    list.New(),
+		entries:  make(map[embeddingKey]*list.Element),
+	}
+
+	if config.CacheDir != "" {
+		db, err := openEmbeddingCache(config.CacheDir, c.model)
+		if err != nil {
+			return nil, err
+		}
+		c.db = db
+	}
+
+	return c, nil
+}
+
+// openEmbeddingCache opens the on-disk tier, dropping its embeddings if
+// they were made by another model
+func openEmbeddingCache(dir, model string) (*bolt.DB, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create embedding cache directory: %w", err)
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, embeddingCacheFile), 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedding cache: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(embeddingMetaBucket)
+		if err != nil {
+			return err
+		}
+		if string(meta.Get(embeddingModelKey)) != model {
+			if err := tx.DeleteBucket(embeddingCacheBucket); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+			if err := meta.Put(embeddingModelKey, []byte(model)); err != nil {
+				return err
+			}
+		}
+		_, err = tx.CreateBucketIfNotExists(embeddingCacheBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize embedding cache: %w", err)
+	}
+
+	return db, nil
+}
+
+// Embed returns the cached embedding of a text, embedding it on a miss
+func (c *CachedEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := c.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+
+	return embeddings[0], nil
+}
+
+// EmbedBatch returns the embeddings of several texts, in order. The texts
+// missing from the cache are embedded in one batch.
+func (c *CachedEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	keys := make([]embeddingKey, len(texts))
+
+	var missing []int
+	for i, text := range texts {
+		keys[i] = c.key(text)
+		if embedding, ok := c.memoryGet(keys[i]); ok {
+			embeddings[i] = embedding
+			c.memoryHits.Add(1)
+			continue
+		}
+		missing = append(missing, i)
+	}
+
+	missing = c.diskGet(keys, missing, embeddings)
+	if len(missing) == 0 {
+		return embeddings, nil
+	}
+	c.misses.Add(int64(len(missing)))
+
+	// Texts repeated within the batch are embedded once
+	first := make(map[embeddingKey]int, len(missing))
+	var batch []string
+	for _, i := range missing {
+		if _, seen := first[keys[i]]; !seen {
+			first[keys[i]] = len(batch)
+			batch = append(batch, texts[i])
+		}
+	}
+
+	embedded, err := c.embedder.EmbedBatch(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range missing {
+		embeddings[i] = embedded[first[keys[i]]]
+	}
+	for key, j := range first {
+		c.memoryPut(key, embedded[j])
+	}
+	c.diskPut(first, embedded)
+
+	return embeddings, nil
+}
+
+// VectorSize returns the dimensionality of the embeddings
+func (c *CachedEmbedder) VectorSize() int {
+	return c.embedder.VectorSize()
+}
+
+// ModelID identifies the model of the cached embedder
+func (c *CachedEmbedder) ModelID() string {
+	return c.embedder.ModelID()
+}
+
+// Stats reports the hits and misses of the cache
+func (c *CachedEmbedder) Stats() *EmbeddingCacheStats {
+	c.lock.Lock()
+	entries := c.order.Len()
+	c.lock.Unlock()
+
+	return &EmbeddingCacheStats{
+		Model:      c.model,
+		Entries:    entries,
+		MemoryHits: c.memoryHits.Load(),
+		DiskHits:   c.diskHits.Load(),
+		Misses:     c.misses.Load(),
+	}
+}
+
+// Close closes the on-disk tier and the embedder
+func (c *CachedEmbedder) Close() error {
+	var err error
+	if c.db != nil {
+		err = c.db.Close()
+	}
+	if closeErr := c.embedder.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// key hashes the model ID and the text with its whitespace collapsed
+func (c *CachedEmbedder) key(text string) embeddingKey {
+	h := sha256.New()
+	h.Write([]byte(c.model))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(strings.Fields(text), " ")))
+
+	var key embeddingKey
+	h.Sum(key[:0])
+	return key
+}
+
+// memoryGet looks an embedding up in the in-memory tier
+func (c *CachedEmbedder) memoryGet(key embeddingKey) ([]float32, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*embeddingEntry).embedding, true
+}
+
+// memoryPut adds an embedding to the in-memory tier, evicting the least
+// recently used one when full
+func (c *CachedEmbedder) memoryPut(key embeddingKey, embedding []float32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if e, exists := c.entries[key]; exists {
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&embeddingEntry{key: key, embedding: embedding})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*embeddingEntry).key)
+	}
+}
+
+// diskGet fills the embeddings of the missing texts found on disk,
+// promoting them to memory, and returns the texts still missing
+func (c *CachedEmbedder) diskGet(keys []embeddingKey, missing []int, embeddings [][]float32) []int {
+	if c.db == nil || len(missing) == 0 {
+		return missing
+	}
+
+	var still []int
+	err := c.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(embeddingCacheBucket)
+		for _, i := range missing {
+			data := bucket.Get(keys[i][:])
+			if len(data) != 4*c.embedder.VectorSize() {
+				still = append(still, i)
+				continue
+			}
+			embeddings[i] = decodeEmbedding(data)
+		}
+		return nil
+	})
+	if err != nil {
+		// A failing disk tier only costs the embedding
+		return missing
+	}
+
+	for _, i := range missing {
+		if embeddings[i] != nil {
+			c.diskHits.Add(1)
+			c.memoryPut(keys[i], embeddings[i])
+		}
+	}
+
+	return still
+}
+
+// diskPut stores new embeddings on disk. Failures are ignored; the
+// embeddings are made again on the next miss.
+func (c *CachedEmbedder) diskPut(first map[embeddingKey]int, embedded [][]float32) {
+	if c.db == nil {
+		return
+	}
+
+	_ = c.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(embeddingCacheBucket)
+		for key, j := range first {
+			if err := bucket.Put(key[:], encodeEmbedding(embedded[j])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// encodeEmbedding writes an embedding as little-endian float32s
+func encodeEmbedding(embedding []float32) []byte {
+	data := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	return data
+}
+
+// decodeEmbedding reads an embedding written by encodeEmbedding
+func decodeEmbedding(data []byte) []float32 {
+	embedding := make([]float32, len(data)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return embedding
+}
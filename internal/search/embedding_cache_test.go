@@ -0,0 +1,83 @@
+package search
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// renamedEmbedder reports another model ID for a recording embedder
+type renamedEmbedder struct {
+	*recordingEmbedder
+	model string
+}
+
+func (e *renamedEmbedder) ModelID() string { return e.model }
+
+func TestCachedEmbedder(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	open := func(embedder TextEmbedder, version int) *CachedEmbedder {
+		t.Helper()
+		cache, err := NewCachedEmbedder(embedder, &EmbedderConfig{CacheDir: dir, CacheSize: 2, ModelVersion: version})
+		if err != nil {
+			t.Fatalf("NewCachedEmbedder failed: %v", err)
+		}
+		return cache
+	}
+	embed := func(cache *CachedEmbedder, texts ...string) {
+		t.Helper()
+		if _, err := cache.EmbedBatch(ctx, texts); err != nil {
+			t.Fatalf("EmbedBatch failed: %v", err)
+		}
+	}
+
+	recorder := &recordingEmbedder{}
+	cache := open(recorder, 1)
+
+	// Texts differing only in whitespace are embedded once
+	embed(cache, "alpha", "beta", " alpha\n")
+	if !slices.Equal(recorder.texts, []string{"alpha", "beta"}) {
+		t.Errorf("the embedder was asked for %q, want alpha and beta", recorder.texts)
+	}
+
+	// A repeated text is a memory hit
+	embed(cache, "alpha")
+	if stats := cache.Stats(); stats.MemoryHits != 1 || stats.DiskHits != 0 || len(recorder.texts) != 2 {
+		t.Errorf("repeating alpha gave %+v and %d embeddings", stats, len(recorder.texts))
+	}
+
+	// beta leaves memory for gamma but is promoted back from disk
+	embed(cache, "gamma")
+	embed(cache, "beta")
+	if stats := cache.Stats(); stats.DiskHits != 1 || stats.Entries != 2 || len(recorder.texts) != 3 {
+		t.Errorf("reading beta back gave %+v and %d embeddings", stats, len(recorder.texts))
+	}
+	embed(cache, "beta")
+	if stats := cache.Stats(); stats.MemoryHits != 2 || stats.DiskHits != 1 {
+		t.Errorf("beta was not promoted to memory: %+v", stats)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The disk tier outlives the process
+	recorder = &recordingEmbedder{}
+	cache = open(recorder, 1)
+	embed(cache, "alpha", "beta", "gamma")
+	if stats := cache.Stats(); stats.DiskHits != 3 || len(recorder.texts) != 0 {
+		t.Errorf("reopening the cache gave %+v and embedded %q", stats, recorder.texts)
+	}
+	cache.Close()
+
+	// A new model version or model ID invalidates the cache
+	for _, embedder := range []TextEmbedder{recorder, &renamedEmbedder{recorder, "renamed"}} {
+		recorder.texts = nil
+		cache = open(embedder, 2)
+		embed(cache, "alpha")
+		if stats := cache.Stats(); stats.Misses != 1 || stats.DiskHits != 0 || len(recorder.texts) != 1 {
+			t.Errorf("model %s reused embeddings of another model: %+v", stats.Model, stats)
+		}
+		cache.Close()
+	}
+}
@@ -0,0 +1,169 @@
+package search
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// defaultHashingVectorSize is the size of hashed embeddings when none is
+// configured
+const defaultHashingVectorSize = 384
+
+// hashingCharN is the length of the character n-grams of the hashing embedder
+const hashingCharN = 3
+
+// hashingKind is the kind of a feature of the hashing embedder
+type hashingKind byte
+
+// Kinds of features. Words count the most; word pairs reward matching
+// phrases and character n-grams match inflections and typos.
+const (
+	hashingWord hashingKind = iota
+	hashingBigram
+	hashingChar
+)
+
+// hashingWeights weights the features by kind
+var hashingWeights = [...]float64{
+	hashingWord:   1.0,
+	hashingBigram: 0.5,
+	hashingChar:   0.25,
+}
+
+// HashingEmbedder embeds text without a model by hashing its words, word
+// pairs and character trigrams into the vector's dimensions. Feature
+// counts are weighted by 1+log(tf) and the vector is L2-normalized, so the
+// cosine similarity of two texts grows with the terms they share. It is
+// deterministic and fit for tests and offline use, but knows no synonyms.
+type HashingEmbedder struct {
+	vectorSize int
+}
+
+// NewHashingEmbedder creates a hashing embedder; a vector size of zero
+// uses 384
+func NewHashingEmbedder(vectorSize int) *HashingEmbedder {
+	if vectorSize <= 0 {
+		vectorSize = defaultHashingVectorSize
+	}
+
+	return &HashingEmbedder{
+		vectorSize: vectorSize,
+	}
+}
+
+// Embed generates the embedding of one text. Text without words embeds to
+// the zero vector.
+func (e *HashingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	// Count each feature by its kind
+	counts := make(map[hashingFeature]int)
+	words := hashingTokens(text)
+	for i, word := range words {
+		counts[hashingFeature{hashingWord, word}]++
+		if i > 0 {
+			counts[hashingFeature{hashingBigram, words[i-1] + " " + word}]++
+		}
+
+		padded := []rune("#" + word + "#")
+		for j := 0; j+hashingCharN <= len(padded); j++ {
+			counts[hashingFeature{hashingChar, string(padded[j : j+hashingCharN])}]++
+		}
+	}
+
+	// Summing in a fixed order keeps colliding features from rounding
+	// differently from one call to the next
+	features := slices.SortedFunc(maps.Keys(counts), func(a, b hashingFeature) int {
+		return cmp.Or(cmp.Compare(a.kind, b.kind), strings.Compare(a.text, b.text))
+	})
+	sums := make([]float64, e.vectorSize)
+	for _, feature := range features {
+		index, sign := e.hash(feature)
+		sums[index] += sign * hashingWeights[feature.kind] * (1 + math.Log(float64(counts[feature])))
+	}
+
+	var sum float64
+	for _, v := range sums {
+		sum += v * v
+	}
+	norm := math.Sqrt(sum)
+
+	embedding := make([]float32, e.vectorSize)
+	for i, v := range sums {
+		if norm > 0 {
+			v /= norm
+		}
+		embedding[i] = float32(v)
+	}
+
+	return embedding, nil
+}
+
+// EmbedBatch generates embeddings for several texts, in order
+func (e *HashingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embedding, err := e.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = embedding
+	}
+
+	return embeddings, nil
+}
+
+// VectorSize returns the dimensionality of the embeddings
+func (e *HashingEmbedder) VectorSize() int {
+	return e.vectorSize
+}
+
+// ModelID identifies the hashing scheme by its vector size
+func (e *HashingEmbedder) ModelID() string {
+	return fmt.Sprintf("hashing-%d", e.vectorSize)
+}
+
+// Close releases resources
+func (e *HashingEmbedder) Close() error {
+	return nil
+}
+
+// hashingFeature is a word, word pair or character n-gram
+type hashingFeature struct {
+	kind hashingKind
+	text string
+}
+
+// hash picks the dimension of a feature and the sign it adds with. Signed
+// hashing makes colliding features cancel out rather than pile up.
+func (e *HashingEmbedder) hash(feature hashingFeature) (int, float64) {
+	h := fnv.New64a()
+	// The kind keeps equal strings of different kinds apart
+	h.Write([]byte{byte(feature.kind)})
+	h.Write([]byte(feature.text))
+	sum := h.Sum64()
+
+	sign := 1.0
+	if sum>>63 == 1 {
+		sign = -1
+	}
+	return int(sum % uint64(e.vectorSize)), sign
+}
+
+// hashingTokens splits text into lowercase words of letters and digits
+func hashingTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
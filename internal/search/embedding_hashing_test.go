@@ -0,0 +1,74 @@
+package search
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHashingEmbedderIsDeterministic(t *testing.T) {
+	// Enough distinct features to collide in many of the 384 buckets
+	var words []string
+	for i := 0; i < 100; i++ {
+		words = append(words, strings.Repeat(string(rune('a'+i%26)), 1+i/26)+"x")
+	}
+	text := strings.Join(words, " ")
+
+	embedder := NewHashingEmbedder(0)
+	first, err := embedder.Embed(context.Background(), text)
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		again, err := embedder.Embed(context.Background(), text)
+		if err != nil {
+			t.Fatalf("Embed failed: %v", err)
+		}
+		if !slices.Equal(first, again) {
+			t.Fatalf("embedding %d differs from the first", i)
+		}
+	}
+}
+
+func TestHashingEmbedderSimilarity(t *testing.T) {
+	embedder := NewHashingEmbedder(256)
+	ctx := context.Background()
+
+	embeddings, err := embedder.EmbedBatch(ctx, []string{
+		"the quick brown fox jumps over the lazy dog",
+		"a quick brown fox jumped over a lazy dog",
+		"quarterly revenue grew in the european market",
+		"",
+	})
+	if err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+
+	for i, embedding := range embeddings[:3] {
+		if len(embedding) != 256 {
+			t.Fatalf("embedding %d has %d dimensions, want 256", i, len(embedding))
+		}
+		if norm := math.Sqrt(dot(embedding, embedding)); math.Abs(norm-1) > 1e-5 {
+			t.Errorf("embedding %d has norm %f, want 1", i, norm)
+		}
+	}
+	if related, unrelated := dot(embeddings[0], embeddings[1]), dot(embeddings[0], embeddings[2]); related <= unrelated {
+		t.Errorf("related texts score %f, unrelated %f", related, unrelated)
+	}
+	if norm := dot(embeddings[3], embeddings[3]); norm != 0 {
+		t.Errorf("empty text embeds with squared norm %f, want 0", norm)
+	}
+	if id := embedder.ModelID(); id != "hashing-256" {
+		t.Errorf("ModelID() = %q, want hashing-256", id)
+	}
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
@@ -0,0 +1,272 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default settings of the HTTP embedding providers
+const (
+	defaultEmbeddingBatchSize  = 64
+	defaultEmbeddingTimeout    = 30 * time.Second
+	defaultEmbeddingMaxRetries = 3
+	embeddingRetryBaseDelay    = 500 * time.Millisecond
+	embeddingRetryMaxDelay     = 10 * time.Second
+)
+
+// embeddingStatusError is returned when a provider answers with a non-2xx status
+type embeddingStatusError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // zero unless the provider asked for one
+}
+
+func (e *embeddingStatusError) Error() string {
+	return fmt.Sprintf("embedding request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// HTTPEmbedder calls the embeddings endpoint of an OpenAI-compatible API
+// or of an Ollama server. Texts are sent in batches of at most
+// EmbedderConfig.BatchSize, and requests that fail with a network error,
+// a rate limit or a server error are retried with exponential backoff.
+type HTTPEmbedder struct {
+	provider   EmbeddingProvider
+	url        string
+	model      string
+	apiKey     string
+	vectorSize int
+	batchSize  int
+	maxRetries int
+	httpClient *http.Client
+}
+
+// NewHTTPEmbedder creates an embedder for an HTTP provider. Without
+// configured dimensions it embeds a probe text to learn the vector size.
+func NewHTTPEmbedder(config *EmbedderConfig) (*HTTPEmbedder, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("%s embedding provider requires a URL", config.Provider)
+	}
+	if config.Model == "" {
+		return nil, fmt.Errorf("%s embedding provider requires a model", config.Provider)
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultEmbeddingTimeout
+	}
+	batchSize := config.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultEmbeddingBatchSize
+	}
+	maxRetries := config.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultEmbeddingMaxRetries
+	}
+	maxRetries = max(maxRetries, 0)
+
+	e := &HTTPEmbedder{
+		provider:   config.Provider,
+		url:        strings.TrimRight(config.URL, "/"),
+		model:      config.Model,
+		apiKey:     config.APIKey,
+		vectorSize: config.Dimensions,
+		batchSize:  batchSize,
+		maxRetries: maxRetries,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+	}
+
+	if e.vectorSize <= 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		embeddings, err := e.requestWithRetries(ctx, []string{"dimension probe"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to probe the %s embedding model %s: %w", e.provider, e.model, err)
+		}
+		if len(embeddings) != 1 || len(embeddings[0]) == 0 {
+			return nil, fmt.Errorf("failed to probe the %s embedding model %s: %w: no embedding", e.provider, e.model, ErrEmbeddingResponse)
+		}
+		e.vectorSize = len(embeddings[0])
+	}
+
+	return e, nil
+}
+
+// Embed generates the embedding of one text
+func (e *HTTPEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := e.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+
+	return embeddings[0], nil
+}
+
+// EmbedBatch generates embeddings for several texts, in order
+func (e *HTTPEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += e.batchSize {
+		batch := texts[start:min(start+e.batchSize, len(texts))]
+
+		batchEmbeddings, err := e.requestWithRetries(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkEmbeddings(batchEmbeddings, len(batch), e.vectorSize); err != nil {
+			return nil, err
+		}
+
+		embeddings = append(embeddings, batchEmbeddings...)
+	}
+
+	return embeddings, nil
+}
+
+// VectorSize returns the dimensionality of the embeddings
+func (e *HTTPEmbedder) VectorSize() int {
+	return e.vectorSize
+}
+
+// ModelID identifies the provider and model
+func (e *HTTPEmbedder) ModelID() string {
+	return fmt.Sprintf("%s/%s", e.provider, e.model)
+}
+
+// Close releases idle connections
+func (e *HTTPEmbedder) Close() error {
+	e.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// requestWithRetries embeds a batch, retrying transient failures
+func (e *HTTPEmbedder) requestWithRetries(ctx context.Context, texts []string) ([][]float32, error) {
+	delay := embeddingRetryBaseDelay
+	for attempt := 0; ; attempt++ {
+		embeddings, err := e.request(ctx, texts)
+		if err == nil || attempt >= e.maxRetries || !isRetryable(ctx, err) {
+			return embeddings, err
+		}
+
+		wait := delay
+		var statusErr *embeddingStatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			wait = statusErr.RetryAfter
+		}
+		delay = min(delay*2, embeddingRetryMaxDelay)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// request sends one batch to the provider's embeddings endpoint
+func (e *HTTPEmbedder) request(ctx context.Context, texts []string) ([][]float32, error) {
+	path := "/embeddings"
+	if e.provider == ProviderOllama {
+		path = "/api/embed"
+	}
+
+	// Both APIs take the model and a list of inputs
+	body, err := json.Marshal(map[string]interface{}{
+		"model": e.model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode embedding request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &embeddingStatusError{
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(respBody)),
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	if e.provider == ProviderOllama {
+		var result struct {
+			Embeddings [][]float32 `json:"embeddings"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrEmbeddingResponse, err)
+		}
+		return result.Embeddings, nil
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEmbeddingResponse, err)
+	}
+
+	// The API may answer out of order; the index says where each belongs
+	embeddings := make([][]float32, len(result.Data))
+	for _, data := range result.Data {
+		if data.Index < 0 || data.Index >= len(embeddings) || embeddings[data.Index] != nil {
+			return nil, fmt.Errorf("%w: bad index %d", ErrEmbeddingResponse, data.Index)
+		}
+		embeddings[data.Index] = data.Embedding
+	}
+
+	return embeddings, nil
+}
+
+// isRetryable reports whether a failed request may succeed if sent again
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var statusErr *embeddingStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+
+	// Undecodable responses will not improve; transport failures might
+	return !errors.Is(err, ErrEmbeddingResponse)
+}
+
+// retryAfter parses a Retry-After header given in seconds, capped at the
+// longest backoff
+func retryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, embeddingRetryMaxDelay)
+}
@@ -0,0 +1,223 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// embeddingServer stands in for an OpenAI-compatible or Ollama embeddings
+// endpoint. Each text is embedded as its length followed by zeros, and
+// the first failures requests are answered with failStatus.
+type embeddingServer struct {
+	*httptest.Server
+	dimensions int
+	failures   int
+	failStatus int
+	// reverse answers OpenAI requests out of order
+	reverse bool
+
+	lock     sync.Mutex
+	requests int
+	batches  [][]string
+}
+
+func newEmbeddingServer(t *testing.T, dimensions int) *embeddingServer {
+	t.Helper()
+	s := &embeddingServer{dimensions: dimensions, failStatus: http.StatusInternalServerError}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *embeddingServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	s.requests++
+	fail := s.requests <= s.failures
+	s.lock.Unlock()
+
+	if fail {
+		http.Error(w, "try again", s.failStatus)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model == "" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	s.lock.Lock()
+	s.batches = append(s.batches, req.Input)
+	s.lock.Unlock()
+
+	embeddings := make([][]float32, len(req.Input))
+	for i, text := range req.Input {
+		embeddings[i] = make([]float32, s.dimensions)
+		embeddings[i][0] = float32(len(text))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/api/embed":
+		json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": embeddings})
+	case "/embeddings":
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		data := make([]map[string]interface{}, len(embeddings))
+		for i, embedding := range embeddings {
+			at := i
+			if s.reverse {
+				at = len(embeddings) - 1 - i
+			}
+			data[at] = map[string]interface{}{"index": i, "embedding": embedding}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *embeddingServer) requestCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests
+}
+
+func TestHTTPEmbedder(t *testing.T) {
+	ctx := context.Background()
+	texts := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+
+	for _, provider := range []EmbeddingProvider{ProviderOpenAI, ProviderOllama} {
+		t.Run(string(provider), func(t *testing.T) {
+			server := newEmbeddingServer(t, 8)
+			server.reverse = true
+			embedder, err := NewHTTPEmbedder(&EmbedderConfig{
+				Provider:  provider,
+				URL:       server.URL + "/",
+				Model:     "test-model",
+				APIKey:    "secret",
+				BatchSize: 2,
+			})
+			if err != nil {
+				t.Fatalf("NewHTTPEmbedder failed: %v", err)
+			}
+			defer embedder.Close()
+
+			// The vector size is probed without configured dimensions
+			if embedder.VectorSize() != 8 {
+				t.Errorf("VectorSize is %d, want 8", embedder.VectorSize())
+			}
+			if embedder.ModelID() != string(provider)+"/test-model" {
+				t.Errorf("ModelID is %q", embedder.ModelID())
+			}
+
+			embeddings, err := embedder.EmbedBatch(ctx, texts)
+			if err != nil {
+				t.Fatalf("EmbedBatch failed: %v", err)
+			}
+			if len(embeddings) != len(texts) {
+				t.Fatalf("EmbedBatch returned %d embeddings for %d texts", len(embeddings), len(texts))
+			}
+			for i, embedding := range embeddings {
+				if embedding[0] != float32(len(texts[i])) {
+					t.Errorf("embedding %d belongs to a text of length %v, want %d", i, embedding[0], len(texts[i]))
+				}
+			}
+
+			// One probe and three batches of at most two texts
+			server.lock.Lock()
+			defer server.lock.Unlock()
+			if len(server.batches) != 4 {
+				t.Fatalf("server got %d requests, want 4", len(server.batches))
+			}
+			for _, batch := range server.batches[1:] {
+				if len(batch) > 2 {
+					t.Errorf("batch of %d texts exceeds the batch size", len(batch))
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPEmbedderRetries(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		maxRetries int
+		failures   int
+		failStatus int
+		wantErr    bool
+		wantCalls  int
+	}{
+		{"server error retried", 1, 1, http.StatusInternalServerError, false, 2},
+		{"rate limit retried", 1, 1, http.StatusTooManyRequests, false, 2},
+		{"retries exhausted", 1, 2, http.StatusInternalServerError, true, 2},
+		{"client error not retried", 3, 1, http.StatusBadRequest, true, 1},
+		{"negative disables retries", -1, 1, http.StatusInternalServerError, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newEmbeddingServer(t, 4)
+			server.failures = tt.failures
+			server.failStatus = tt.failStatus
+			embedder, err := NewHTTPEmbedder(&EmbedderConfig{
+				Provider:   ProviderOllama,
+				URL:        server.URL,
+				Model:      "test-model",
+				Dimensions: 4,
+				MaxRetries: tt.maxRetries,
+			})
+			if err != nil {
+				t.Fatalf("NewHTTPEmbedder failed: %v", err)
+			}
+			defer embedder.Close()
+
+			_, err = embedder.Embed(ctx, "text")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Embed returned error %v, want error: %v", err, tt.wantErr)
+			}
+			if calls := server.requestCount(); calls != tt.wantCalls {
+				t.Errorf("server got %d requests, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestHTTPEmbedderDimensions(t *testing.T) {
+	server := newEmbeddingServer(t, 4)
+	embedder, err := NewHTTPEmbedder(&EmbedderConfig{
+		Provider:   ProviderOllama,
+		URL:        server.URL,
+		Model:      "test-model",
+		Dimensions: 8,
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPEmbedder failed: %v", err)
+	}
+	defer embedder.Close()
+
+	// Dimension mismatches are not retried
+	_, err = embedder.Embed(context.Background(), "text")
+	if !errors.Is(err, ErrEmbeddingDimension) {
+		t.Errorf("Embed returned %v, want ErrEmbeddingDimension", err)
+	}
+	if calls := server.requestCount(); calls != 1 {
+		t.Errorf("server got %d requests, want 1", calls)
+	}
+}
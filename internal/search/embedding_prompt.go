@@ -0,0 +1,205 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// textPlaceholder stands for the text in query and document templates
+const textPlaceholder = "{text}"
+
+// modelPrompt holds the templates and token limit of a family of models
+type modelPrompt struct {
+	// match is found in the lowercased model name or file name
+	match     string
+	query     string
+	document  string
+	maxTokens int
+}
+
+// modelPrompts lists the model families that embed queries and passages
+// differently, most specific first
+var modelPrompts = []modelPrompt{
+	{
+		match:     "e5-mistral",
+		query:     "Instruct: Given a web search query, retrieve relevant passages that answer the query\nQuery: ",
+		maxTokens: 4096,
+	},
+	{match: "e5-", query: "query: ", document: "passage: ", maxTokens: 512},
+	{match: "bge-m3", maxTokens: 8192},
+	{match: "bge-", query: "Represent this sentence for searching relevant passages: ", maxTokens: 512},
+	{match: "mxbai-embed", query: "Represent this sentence for searching relevant passages: ", maxTokens: 512},
+	{match: "nomic-embed", query: "search_query: ", document: "search_document: ", maxTokens: 8192},
+}
+
+// PromptedEmbedder embeds queries and documents with a TextEmbedder after
+// truncating them and wrapping each in its template
+type PromptedEmbedder struct {
+	embedder         TextEmbedder
+	queryTemplate    string
+	documentTemplate string
+	maxTokens        int
+}
+
+// NewPromptedEmbedder wraps an embedder in the templates and token limit
+// of config, falling back to those known for the model
+func NewPromptedEmbedder(embedder TextEmbedder, config *EmbedderConfig) *PromptedEmbedder {
+	e := &PromptedEmbedder{
+		embedder:         embedder,
+		queryTemplate:    config.QueryTemplate,
+		documentTemplate: config.DocumentTemplate,
+		maxTokens:        config.MaxTokens,
+	}
+
+	name := strings.ToLower(config.Model)
+	if config.ModelPath != "" {
+		name += " " + strings.ToLower(filepath.Base(config.ModelPath))
+	}
+	for _, prompt := range modelPrompts {
+		if !strings.Contains(name, prompt.match) {
+			continue
+		}
+		if e.queryTemplate == "" {
+			e.queryTemplate = prompt.query
+		}
+		if e.documentTemplate == "" {
+			e.documentTemplate = prompt.document
+		}
+		if e.maxTokens == 0 {
+			e.maxTokens = prompt.maxTokens
+		}
+		break
+	}
+
+	return e
+}
+
+// EmbedQuery generates the embedding of a search query
+func (e *PromptedEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	return e.embedder.Embed(ctx, e.prompt(e.queryTemplate, query))
+}
+
+// EmbedDocuments generates the embeddings of document passages, in order
+func (e *PromptedEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	prompts := make([]string, len(texts))
+	for i, text := range texts {
+		prompts[i] = e.prompt(e.documentTemplate, text)
+	}
+
+	return e.embedder.EmbedBatch(ctx, prompts)
+}
+
+// VectorSize returns the dimensionality of the embeddings
+func (e *PromptedEmbedder) VectorSize() int {
+	return e.embedder.VectorSize()
+}
+
+// ModelID identifies the model making the embeddings
+func (e *PromptedEmbedder) ModelID() string {
+	return e.embedder.ModelID()
+}
+
+// CacheStats reports the use of the embedding cache; nil without a cache
+func (e *PromptedEmbedder) CacheStats() *EmbeddingCacheStats {
+	if cached, ok := e.embedder.(*CachedEmbedder); ok {
+		return cached.Stats()
+	}
+	return nil
+}
+
+// Close releases the resources held by the embedder
+func (e *PromptedEmbedder) Close() error {
+	return e.embedder.Close()
+}
+
+// prompt truncates a text and wraps it in a template. The template's own
+// tokens count against the limit.
+func (e *PromptedEmbedder) prompt(template, text string) string {
+	if e.maxTokens > 0 {
+		limit := e.maxTokens - estimateTokens(strings.ReplaceAll(template, textPlaceholder, ""))
+		// Zero would keep the whole text
+		text = truncateTokens(text, max(limit, 1))
+	}
+	if template == "" {
+		return text
+	}
+	if strings.Contains(template, textPlaceholder) {
+		return strings.ReplaceAll(template, textPlaceholder, text)
+	}
+	return template + text
+}
+
+// truncateTokens cuts a text to about maxTokens tokens at a word boundary.
+// Without the model's tokenizer, each word counts as one token per four
+// characters, rounded up, which tends to cut a little early rather than
+// late. A limit of zero keeps the whole text.
+func truncateTokens(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return text
+	}
+
+	tokens := 0
+	for start := 0; ; {
+		word, end, runes := nextWord(text, start)
+		if runes == 0 {
+			break
+		}
+
+		cost := wordTokens(runes)
+		if tokens+cost > maxTokens {
+			if tokens == 0 {
+				// A single overlong word is cut by characters
+				return string([]rune(text[word:end])[:maxTokens*4])
+			}
+			return strings.TrimRightFunc(text[:word], unicode.IsSpace)
+		}
+		tokens += cost
+		start = end
+	}
+
+	return text
+}
+
+// estimateTokens counts the tokens of a text as truncateTokens does
+func estimateTokens(text string) int {
+	tokens := 0
+	for start := 0; ; {
+		_, end, runes := nextWord(text, start)
+		if runes == 0 {
+			return tokens
+		}
+		tokens += wordTokens(runes)
+		start = end
+	}
+}
+
+// wordTokens estimates the tokens of a word of the given length in runes
+func wordTokens(runes int) int {
+	return (runes + 3) / 4
+}
+
+// nextWord finds the first word at or after byte offset start. It returns
+// the word's byte offsets and its length in runes, which is zero when no
+// word is left.
+func nextWord(text string, start int) (int, int, int) {
+	for start < len(text) {
+		r, size := utf8.DecodeRuneInString(text[start:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		start += size
+	}
+	end, runes := start, 0
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		end += size
+		runes++
+	}
+	return start, end, runes
+}
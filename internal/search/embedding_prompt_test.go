@@ -0,0 +1,76 @@
+package search
+
+import (
+	"context"
+	"testing"
+)
+
+// recordingEmbedder records the texts it is asked to embed
+type recordingEmbedder struct {
+	texts []string
+}
+
+func (e *recordingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	e.texts = append(e.texts, text)
+	return []float32{1}, nil
+}
+
+func (e *recordingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i], _ = e.Embed(ctx, text)
+	}
+	return embeddings, nil
+}
+
+func (e *recordingEmbedder) VectorSize() int { return 1 }
+func (e *recordingEmbedder) ModelID() string { return "recording" }
+func (e *recordingEmbedder) Close() error    { return nil }
+
+func TestTruncateTokens(t *testing.T) {
+	tests := []struct {
+		text      string
+		maxTokens int
+		want      string
+	}{
+		{"one two three", 0, "one two three"},
+		{"one two three", 2, "one two"},
+		{"  one   two  ", 5, "  one   two  "},
+		// Words count one token per four characters, rounded up
+		{"tokenized words", 3, "tokenized"},
+		{"supercalifragilistic", 2, "supercal"},
+		{"", 3, ""},
+	}
+
+	for _, test := range tests {
+		if got := truncateTokens(test.text, test.maxTokens); got != test.want {
+			t.Errorf("truncateTokens(%q, %d) = %q, want %q", test.text, test.maxTokens, got, test.want)
+		}
+	}
+}
+
+func TestPromptCountsTemplateTokens(t *testing.T) {
+	ctx := context.Background()
+	recorder := &recordingEmbedder{}
+	embedder := NewPromptedEmbedder(recorder, &EmbedderConfig{Model: "nomic-embed-text-v1.5", MaxTokens: 6})
+
+	// The document template takes four of the six tokens
+	if _, err := embedder.EmbedDocuments(ctx, []string{"one two three four five"}); err != nil {
+		t.Fatalf("EmbedDocuments failed: %v", err)
+	}
+	if want := "search_document: one two"; recorder.texts[0] != want {
+		t.Errorf("document prompt is %q, want %q", recorder.texts[0], want)
+	}
+	if tokens := estimateTokens(recorder.texts[0]); tokens > 6 {
+		t.Errorf("document prompt has %d tokens, more than the limit of 6", tokens)
+	}
+
+	// A template over the limit still leaves a token of the text
+	embedder = NewPromptedEmbedder(recorder, &EmbedderConfig{QueryTemplate: "a rather long instruction {text}", MaxTokens: 2})
+	if _, err := embedder.EmbedQuery(ctx, "one two"); err != nil {
+		t.Fatalf("EmbedQuery failed: %v", err)
+	}
+	if want := "a rather long instruction one"; recorder.texts[1] != want {
+		t.Errorf("query prompt is %q, want %q", recorder.texts[1], want)
+	}
+}
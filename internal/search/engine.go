@@ -2,7 +2,11 @@ package search
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/sanjeevkumarraob/semantic-search-service/internal/document"
@@ -11,14 +15,34 @@ import (
 
 // SearchRequest represents a search query
 type SearchRequest struct {
-	Query       string
+	Query string
+	// Collections to search; empty searches the default collection
+	Collections []string
 	UserID      string
 	Permissions []string // List of content IDs the user has access to
 	Limit       int
+	Filter      *vectorstore.Filter // Optional metadata filter
+	// Cursor continues from the NextCursor of a previous page
+	Cursor string
+	// Offset skips results after the cursor
+	Offset int
+	// MinScore drops results scoring below it
+	MinScore *float64
+	// MMR re-ranks the results for diversity. Re-ranked results are paged
+	// by offset; cursors are not supported.
+	MMR *vectorstore.MMR
+}
+
+// SearchPage is one page of search results
+type SearchPage struct {
+	Results []SearchResult
+	// NextCursor fetches the following page; empty on the last page
+	NextCursor string
 }
 
 // SearchResult represents a search result
 type SearchResult struct {
+	Collection   string
 	DocumentID   string
 	Title        string
 	ChunkContent string
@@ -26,75 +50,425 @@ type SearchResult struct {
 	Metadata     map[string]string
 }
 
+// Stats describes the vector store and, when enabled, the embedding cache
+type Stats struct {
+	*vectorstore.Stats
+	// Model is the embedding model of searches and new documents
+	Model          vectorstore.EmbeddingModel `json:"model"`
+	EmbeddingCache *EmbeddingCacheStats       `json:"embedding_cache,omitempty"`
+}
+
+// Error definitions
+var (
+	ErrDocumentNotFound = errors.New("document not found")
+)
+
+// DocumentInfo summarizes an indexed document
+type DocumentInfo struct {
+	Collection  string
+	DocumentID  string
+	Title       string
+	Chunks      int
+	Metadata    map[string]string
+	Permissions []string
+	Retention   vectorstore.Retention
+	// ExpiresAt is the earliest expiry of the document's chunks; zero if
+	// they never expire
+	ExpiresAt time.Time
+}
+
+// RetentionUpdate changes the retention of a document. Nil fields keep
+// their current value.
+type RetentionUpdate struct {
+	Policy    *vectorstore.RetentionPolicy
+	TTL       *time.Duration
+	Pinned    *bool
+	ExpiresAt *time.Time
+}
+
+// Default embedding batch parameters
+const (
+	defaultEmbedBatchSize = 32
+	defaultEmbedWorkers   = 4
+)
+
+// mmrCandidateFactor is the number of candidates re-ranked by MMR for each
+// result up to the end of the page
+const mmrCandidateFactor = 4
+
+// Config contains configuration for the search engine
+type Config struct {
+	VectorStore vectorstore.Config
+	Embedder    EmbedderConfig
+	// NextEmbedder starts a migration to another embedding model. The
+	// stored chunks are re-embedded in the background and searches switch
+	// to the new model once every collection has been copied.
+	NextEmbedder *EmbedderConfig
+	// TTL applies to the default collection; zero keeps documents forever
+	TTL time.Duration
+	// Retention overrides the collection's retention for documents by the
+	// "source" in their metadata
+	Retention map[string]vectorstore.Retention
+	// EmbedBatchSize is the number of chunks sent to the embedder at once
+	EmbedBatchSize int
+	// EmbedWorkers bounds the number of batches embedded concurrently
+	EmbedWorkers int
+}
+
 // Engine handles search operations
 type Engine struct {
-	embedder    *Embedder
-	vectorStore *vectorstore.QdrantStore
-	ttl         time.Duration
-	logger      *log.Logger
+	// lock guards the embedder, model and collections, which change when a
+	// migration cuts over. Writes hold it throughout, so that each is
+	// embedded and stored under one model.
+	lock     sync.RWMutex
+	embedder Embedder
+	model    vectorstore.EmbeddingModel
+	// collections maps collection names onto the collections migrated to
+	// the model
+	collections       map[string]string
+	defaultCollection string
+	migration         *migration // nil unless migrating
+
+	vectorStore    vectorstore.VectorStore
+	ttl            time.Duration
+	retention      map[string]vectorstore.Retention
+	embedBatchSize int
+	embedWorkers   int
+	logger         *log.Logger
 }
 
 // NewEngine creates a new search engine
-func NewEngine(logger *log.Logger) *Engine {
+func NewEngine(config *Config, logger *log.Logger) (*Engine, error) {
 	// Initialize embedder
-	embedder := NewEmbedder()
+	embedder, err := NewEmbedder(&config.Embedder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize embedder: %w", err)
+	}
+	model := vectorstore.EmbeddingModel{ID: embedder.ModelID(), Version: config.Embedder.ModelVersion}
+	logger.Printf("Using %d-dimensional embeddings of %s", embedder.VectorSize(), model)
 
-	// Initialize vector store with in-memory configuration
-	vectorStore := vectorstore.NewQdrantStore(&vectorstore.Config{
-		InMemory:   true,
-		VectorSize: embedder.VectorSize(),
-		TTL:        30 * time.Minute,
-	})
+	// Initialize the configured vector store backend
+	storeConfig := config.VectorStore
+	storeConfig.VectorSize = embedder.VectorSize()
+	storeConfig.TTL = config.TTL
+	if storeConfig.OnEvict == nil {
+		storeConfig.OnEvict = func(eviction *vectorstore.Eviction) {
+			logger.Printf("Evicted document %s from collection %s to stay within the memory budget (%d chunks, %d bytes)",
+				eviction.DocumentID, eviction.Collection, eviction.Items, eviction.Bytes)
+		}
+	}
+
+	vectorStore, err := vectorstore.New(&storeConfig)
+	if err != nil {
+		embedder.Close()
+		return nil, fmt.Errorf("failed to initialize vector store: %w", err)
+	}
+
+	embedBatchSize := config.EmbedBatchSize
+	if embedBatchSize <= 0 {
+		embedBatchSize = defaultEmbedBatchSize
+	}
+	embedWorkers := config.EmbedWorkers
+	if embedWorkers <= 0 {
+		embedWorkers = defaultEmbedWorkers
+	}
+
+	defaultCollection := storeConfig.Collection
+	if defaultCollection == "" {
+		defaultCollection = vectorstore.DefaultCollection
+	}
+
+	e := &Engine{
+		embedder:          embedder,
+		model:             model,
+		defaultCollection: defaultCollection,
+		vectorStore:       vectorStore,
+		ttl:               config.TTL,
+		retention:         config.Retention,
+		embedBatchSize:    embedBatchSize,
+		embedWorkers:      embedWorkers,
+		logger:            logger,
+	}
+
+	if err := e.loadCollections(context.Background()); err != nil {
+		e.Cleanup()
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
 
-	return &Engine{
-		embedder:    embedder,
-		vectorStore: vectorStore,
-		ttl:         30 * time.Minute, // Default TTL for vectors
-		logger:      logger,
+	if config.NextEmbedder != nil {
+		if err := e.startMigration(config.NextEmbedder); err != nil {
+			e.Cleanup()
+			return nil, err
+		}
 	}
+
+	return e, nil
 }
 
-// IndexDocument processes and indexes document content
-func (e *Engine) IndexDocument(ctx context.Context, doc *document.ProcessorResult, userPermissions []string) error {
-	// Process each content chunk
-	for i, chunk := range doc.Content {
-		// Generate embedding for this chunk
-		embedding, err := e.embedder.Embed(ctx, chunk)
-		if err != nil {
-			e.logger.Printf("Error embedding chunk %d of document %s: %v", i, doc.DocumentID, err)
-			continue
+// IndexDocument processes and indexes document content into a collection.
+// An empty collection selects the default one. Either every chunk is
+// stored or, if any chunk fails, none is.
+func (e *Engine) IndexDocument(ctx context.Context, collection string, doc *document.ProcessorResult, userPermissions []string) error {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	collection = e.resolve(collection)
+	items, err := e.embedDocument(ctx, collection, doc, userPermissions)
+	if err != nil {
+		return err
+	}
+
+	// Store vectors with permissions as payload
+	if err := e.vectorStore.StoreBatch(ctx, collection, items); err != nil {
+		e.logger.Printf("Failed to store vectors of document %s: %v", doc.DocumentID, err)
+		return err
+	}
+
+	return nil
+}
+
+// ReindexDocument atomically replaces every chunk of a document with the
+// given content, removing chunks that no longer exist
+func (e *Engine) ReindexDocument(ctx context.Context, collection string, doc *document.ProcessorResult, userPermissions []string) error {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	collection = e.resolve(collection)
+	items, err := e.embedDocument(ctx, collection, doc, userPermissions)
+	if err != nil {
+		return err
+	}
+
+	if err := e.vectorStore.ReplaceDocument(ctx, collection, doc.DocumentID, items); err != nil {
+		e.logger.Printf("Failed to replace chunks of document %s: %v", doc.DocumentID, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetDocument returns a summary of an indexed document
+func (e *Engine) GetDocument(ctx context.Context, collection, documentID string) (*DocumentInfo, error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	chunks, err := e.vectorStore.GetDocument(ctx, e.resolve(collection), documentID)
+	if err != nil {
+		return nil, err
+	}
+	return e.summarize(documentID, chunks)
+}
+
+// PeekDocument returns a summary of an indexed document like GetDocument,
+// but without counting a hit or refreshing a sliding expiry. Access checks
+// use it, so that looking at a document does not keep it alive.
+func (e *Engine) PeekDocument(ctx context.Context, collection, documentID string) (*DocumentInfo, error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return e.peekDocument(ctx, collection, documentID)
+}
+
+// peekDocument summarizes a document without touching it. The caller must
+// hold the lock.
+func (e *Engine) peekDocument(ctx context.Context, collection, documentID string) (*DocumentInfo, error) {
+	chunks, err := readDocument(ctx, e.vectorStore, e.resolve(collection), documentID)
+	if err != nil {
+		return nil, err
+	}
+	return e.summarize(documentID, chunks)
+}
+
+// summarize builds the summary of a document from its chunks
+func (e *Engine) summarize(documentID string, chunks []*vectorstore.Item) (*DocumentInfo, error) {
+	if len(chunks) == 0 {
+		return nil, ErrDocumentNotFound
+	}
+
+	info := &DocumentInfo{
+		Collection:  e.collectionName(chunks[0].Collection),
+		DocumentID:  documentID,
+		Title:       chunks[0].Title,
+		Chunks:      len(chunks),
+		Metadata:    chunks[0].Metadata,
+		Permissions: chunks[0].Permissions,
+		Retention:   chunks[0].Retention,
+	}
+	for _, chunk := range chunks {
+		if !chunk.ExpiresAt.IsZero() && (info.ExpiresAt.IsZero() || chunk.ExpiresAt.Before(info.ExpiresAt)) {
+			info.ExpiresAt = chunk.ExpiresAt
 		}
+	}
 
-		// Create a unique ID for this chunk
-		chunkID := doc.DocumentID + "-" + string(i)
-
-		// Store vector with permissions as payload
-		err = e.vectorStore.Store(ctx, &vectorstore.Item{
-			ID:         chunkID,
-			Vector:     embedding,
-			DocumentID: doc.DocumentID,
-			Content:    chunk,
-			Title:      doc.Title,
-			Metadata:   doc.Metadata,
-			// Store permissions with the vector for filtering
-			Permissions: userPermissions,
-			// Set expiration time
-			ExpiresAt: time.Now().Add(e.ttl),
-		})
+	return info, nil
+}
 
-		if err != nil {
-			e.logger.Printf("Failed to store vector for chunk %d of document %s: %v", i, doc.DocumentID, err)
-			return err
+// UpdateRetention changes the retention of a document and returns its new
+// summary. Changing the policy or TTL without giving an expiry time starts
+// the new expiry from now, and a new policy without a TTL uses the
+// collection's TTL.
+func (e *Engine) UpdateRetention(ctx context.Context, collection, documentID string, update *RetentionUpdate) (*DocumentInfo, error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	info, err := e.peekDocument(ctx, collection, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	retention := info.Retention
+	expiresAt := info.ExpiresAt
+	if update.Policy != nil {
+		retention.Policy = *update.Policy
+		retention.TTL = 0
+		expiresAt = time.Time{}
+	}
+	if update.TTL != nil {
+		retention.TTL = *update.TTL
+		expiresAt = time.Time{}
+	}
+	if update.Pinned != nil {
+		retention.Pinned = *update.Pinned
+	}
+	if update.ExpiresAt != nil {
+		expiresAt = *update.ExpiresAt
+		// An expiry time on its own makes a document that never expired expire
+		if update.Policy == nil && (retention.Policy == vectorstore.RetentionForever || retention.Policy == vectorstore.RetentionDefault) {
+			retention.Policy = vectorstore.RetentionTTL
 		}
 	}
 
+	if err := e.vectorStore.SetDocumentRetention(ctx, e.resolve(info.Collection), documentID, retention, expiresAt); err != nil {
+		e.logger.Printf("Failed to set retention of document %s: %v", documentID, err)
+		return nil, err
+	}
+
+	return e.peekDocument(ctx, collection, documentID)
+}
+
+// DeleteDocument removes every chunk of a document from the index
+func (e *Engine) DeleteDocument(ctx context.Context, collection, documentID string) error {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	if err := e.vectorStore.DeleteDocument(ctx, e.resolve(collection), documentID); err != nil {
+		e.logger.Printf("Failed to delete document %s: %v", documentID, err)
+		return err
+	}
+
 	return nil
 }
 
-// Search performs semantic search
-func (e *Engine) Search(ctx context.Context, req *SearchRequest) ([]SearchResult, error) {
+// embedDocument embeds every chunk of a document and builds its items.
+// The caller must hold the lock.
+func (e *Engine) embedDocument(ctx context.Context, collection string, doc *document.ProcessorResult, permissions []string) ([]*vectorstore.Item, error) {
+	embeddings, err := e.embedChunks(ctx, e.embedder, doc.Content)
+	if err != nil {
+		e.logger.Printf("Error embedding document %s: %v", doc.DocumentID, err)
+		return nil, fmt.Errorf("failed to embed document %s: %w", doc.DocumentID, err)
+	}
+
+	items := make([]*vectorstore.Item, len(embeddings))
+	for i, embedding := range embeddings {
+		items[i] = e.newItem(collection, doc, i, embedding, permissions)
+	}
+
+	return items, nil
+}
+
+// embedChunks embeds chunks in batches on a bounded pool of workers. The
+// first failure cancels the remaining batches.
+func (e *Engine) embedChunks(ctx context.Context, embedder Embedder, chunks []string) ([][]float32, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	embeddings := make([][]float32, len(chunks))
+	batches := make(chan int)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for w := 0; w < min(e.embedWorkers, len(chunks)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range batches {
+				end := min(start+e.embedBatchSize, len(chunks))
+				batch, err := embedder.EmbedDocuments(ctx, chunks[start:end])
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("chunks %d-%d: %w", start, end-1, err)
+						cancel()
+					})
+					continue
+				}
+				copy(embeddings[start:end], batch)
+			}
+		}()
+	}
+
+	for start := 0; start < len(chunks); start += e.embedBatchSize {
+		select {
+		case batches <- start:
+		case <-ctx.Done():
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return embeddings, nil
+}
+
+// newItem builds the vector store item for one chunk of a document.
+// The item is kept according to the retention of its source, or of its
+// collection if the source has none.
+func (e *Engine) newItem(collection string, doc *document.ProcessorResult, index int, embedding []float32, permissions []string) *vectorstore.Item {
+	return &vectorstore.Item{
+		ID:         chunkID(doc.DocumentID, index),
+		Collection: collection,
+		Vector:     embedding,
+		DocumentID: doc.DocumentID,
+		Content:    doc.Content[index],
+		Title:      doc.Title,
+		Metadata:   doc.Metadata,
+		// Store permissions with the vector for filtering
+		Permissions: permissions,
+		Retention:   e.retention[doc.Metadata["source"]],
+		Model:       e.model,
+	}
+}
+
+// chunkID creates a unique ID for a chunk of a document
+func chunkID(documentID string, index int) string {
+	return fmt.Sprintf("%s-%d", documentID, index)
+}
+
+// Search performs semantic search and returns one page of results. Only
+// vectors of the engine's embedding model are searched.
+func (e *Engine) Search(ctx context.Context, req *SearchRequest) (*SearchPage, error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	var after *vectorstore.Cursor
+	if req.Cursor != "" {
+		cursor, err := vectorstore.DecodeCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = cursor
+	}
+
 	// Generate embedding for query
-	queryEmbedding, err := e.embedder.Embed(ctx, req.Query)
+	queryEmbedding, err := e.embedder.EmbedQuery(ctx, req.Query)
 	if err != nil {
 		return nil, err
 	}
@@ -104,21 +478,79 @@ func (e *Engine) Search(ctx context.Context, req *SearchRequest) ([]SearchResult
 		req.Limit = 10
 	}
 
-	// Search vectors, filtering by user permissions
+	if req.MMR != nil {
+		return e.searchMMR(ctx, req, queryEmbedding)
+	}
+
+	// Search vectors, filtering by user permissions. One extra result tells
+	// whether another page follows.
 	results, err := e.vectorStore.Search(ctx, &vectorstore.SearchParams{
+		Collections:      e.resolveAll(req.Collections),
 		Vector:           queryEmbedding,
-		Limit:            req.Limit,
+		Limit:            req.Limit + 1,
 		PermissionFilter: req.Permissions,
+		Filter:           req.Filter,
+		After:            after,
+		Offset:           req.Offset,
+		MinScore:         req.MinScore,
+		Model:            &e.model,
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert to search results
-	searchResults := make([]SearchResult, len(results))
+	page := &SearchPage{}
+	if len(results) > req.Limit {
+		results = results[:req.Limit]
+		page.NextCursor = vectorstore.CursorAfter(results[len(results)-1]).Encode()
+	}
+
+	page.Results = e.searchResults(results)
+
+	return page, nil
+}
+
+// searchMMR re-ranks the best candidates of a search by Maximal Marginal
+// Relevance. The candidates cover a few times the results up to the end of
+// the page, so that diverse results further down can move up. The caller
+// must hold the lock.
+func (e *Engine) searchMMR(ctx context.Context, req *SearchRequest, queryEmbedding []float32) (*SearchPage, error) {
+	if err := req.MMR.Validate(); err != nil {
+		return nil, err
+	}
+	if req.Cursor != "" {
+		return nil, fmt.Errorf("%w: re-ranked results are paged by offset, not cursor", vectorstore.ErrInvalidMMR)
+	}
+
+	end := max(req.Offset, 0) + req.Limit
+	candidates, err := e.vectorStore.Search(ctx, &vectorstore.SearchParams{
+		Collections:      e.resolveAll(req.Collections),
+		Vector:           queryEmbedding,
+		Limit:            end * mmrCandidateFactor,
+		PermissionFilter: req.Permissions,
+		Filter:           req.Filter,
+		MinScore:         req.MinScore,
+		WithVectors:      true,
+		Model:            &e.model,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := req.MMR.Rerank(queryEmbedding, candidates, end)
+	ranked = ranked[min(max(req.Offset, 0), len(ranked)):]
+
+	return &SearchPage{Results: e.searchResults(ranked)}, nil
+}
+
+// searchResults converts the results of the vector store. The caller must
+// hold the lock.
+func (e *Engine) searchResults(results []*vectorstore.SearchResult) []SearchResult {
+	converted := make([]SearchResult, len(results))
 	for i, result := range results {
-		searchResults[i] = SearchResult{
+		converted[i] = SearchResult{
+			Collection:   e.collectionName(result.Collection),
 			DocumentID:   result.DocumentID,
 			Title:        result.Title,
 			ChunkContent: result.Content,
@@ -126,11 +558,100 @@ func (e *Engine) Search(ctx context.Context, req *SearchRequest) ([]SearchResult
 			Metadata:     result.Metadata,
 		}
 	}
+	return converted
+}
+
+// CreateCollection adds a collection for the engine's embeddings.
+// The vector size is that of the embedder.
+func (e *Engine) CreateCollection(ctx context.Context, config *vectorstore.CollectionConfig) error {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	collectionConfig := *config
+	if collectionConfig.VectorSize == 0 {
+		collectionConfig.VectorSize = e.embedder.VectorSize()
+	}
+	if collectionConfig.VectorSize != e.embedder.VectorSize() {
+		return fmt.Errorf("%w: vector size %d does not match the embedder's %d",
+			vectorstore.ErrInvalidCollection, collectionConfig.VectorSize, e.embedder.VectorSize())
+	}
 
-	return searchResults, nil
+	return e.vectorStore.CreateCollection(ctx, &collectionConfig)
 }
 
-// Cleanup performs necessary cleanup operations
+// ListCollections describes every collection, including those made by
+// migrations to other embedding models
+func (e *Engine) ListCollections(ctx context.Context) ([]*vectorstore.CollectionInfo, error) {
+	return e.vectorStore.ListCollections(ctx)
+}
+
+// DropCollection removes a collection and every document indexed in it.
+// Dropping a collection made by a migration leaves its name to the
+// collection it was migrated from.
+func (e *Engine) DropCollection(ctx context.Context, name string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if err := e.vectorStore.DropCollection(ctx, name); err != nil {
+		return err
+	}
+
+	return e.loadCollections(ctx)
+}
+
+// DefaultTTL returns the TTL used when a collection is created without one
+func (e *Engine) DefaultTTL() time.Duration {
+	return e.ttl
+}
+
+// Export writes every item of a collection to w as JSON Lines
+func (e *Engine) Export(ctx context.Context, collection string, w io.Writer) (int, error) {
+	e.lock.RLock()
+	collection = e.resolve(collection)
+	e.lock.RUnlock()
+
+	return vectorstore.Export(ctx, e.vectorStore, collection, w)
+}
+
+// Import stores the items of a JSON Lines export in a collection
+func (e *Engine) Import(ctx context.Context, collection string, r io.Reader) (int, error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return vectorstore.Import(ctx, e.vectorStore, e.resolve(collection), r)
+}
+
+// Changes streams the changes of the vector store numbered above after;
+// an after of zero streams only new changes
+func (e *Engine) Changes(ctx context.Context, after uint64) (*vectorstore.ChangeStream, error) {
+	return e.vectorStore.Subscribe(ctx, after)
+}
+
+// Stats reports the size of the vector store and the use of the
+// embedding cache
+func (e *Engine) Stats(ctx context.Context) (*Stats, error) {
+	storeStats, err := e.vectorStore.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	stats := &Stats{Stats: storeStats, Model: e.model}
+	if prompted, ok := e.embedder.(*PromptedEmbedder); ok {
+		stats.EmbeddingCache = prompted.CacheStats()
+	}
+
+	return stats, nil
+}
+
+// Cleanup stops a running migration and closes the vector store and the
+// embedder
 func (e *Engine) Cleanup() {
+	if e.migration != nil {
+		e.migration.stop()
+	}
 	e.vectorStore.Close()
+	e.embedder.Close()
 }
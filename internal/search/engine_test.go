@@ -0,0 +1,207 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sanjeevkumarraob/semantic-search-service/internal/document"
+	"github.com/sanjeevkumarraob/semantic-search-service/pkg/vectorstore"
+)
+
+func TestSearchPages(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestEngine(t, &Config{})
+
+	for i := 0; i < 4; i++ {
+		if err := engine.IndexDocument(ctx, "", testDocument(i, "paged"), nil); err != nil {
+			t.Fatalf("IndexDocument failed: %v", err)
+		}
+	}
+
+	// Following the cursors visits every chunk once
+	seen := make(map[string]bool)
+	req := &SearchRequest{Query: "paged chunk", Limit: 5}
+	pages := 0
+	for {
+		page, err := engine.Search(ctx, req)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		pages++
+		for _, result := range page.Results {
+			if seen[result.ChunkContent] {
+				t.Errorf("chunk %q returned on two pages", result.ChunkContent)
+			}
+			seen[result.ChunkContent] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		if len(page.Results) != req.Limit {
+			t.Errorf("page %d has %d results but a next cursor", pages, len(page.Results))
+		}
+		req.Cursor = page.NextCursor
+	}
+	if len(seen) != 12 || pages != 3 {
+		t.Errorf("paging returned %d chunks on %d pages, want 12 on 3", len(seen), pages)
+	}
+
+	// A page that ends exactly at the last result has no next cursor
+	page, err := engine.Search(ctx, &SearchRequest{Query: "paged chunk", Limit: 12})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(page.Results) != 12 || page.NextCursor != "" {
+		t.Errorf("a page of every result has %d results and next cursor %q", len(page.Results), page.NextCursor)
+	}
+
+	if _, err := engine.Search(ctx, &SearchRequest{Query: "paged chunk", Cursor: "garbage!"}); !errors.Is(err, vectorstore.ErrInvalidCursor) {
+		t.Errorf("Search with a bad cursor returned %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestEngine(t, &Config{
+		TTL: time.Hour,
+		Retention: map[string]vectorstore.Retention{
+			"confluence": {Policy: vectorstore.RetentionSliding, TTL: 24 * time.Hour},
+			"upload":     {Policy: vectorstore.RetentionForever},
+		},
+	})
+
+	sources := map[string]vectorstore.Retention{
+		"confluence": {Policy: vectorstore.RetentionSliding, TTL: 24 * time.Hour},
+		"upload":     {Policy: vectorstore.RetentionForever},
+		"other":      {Policy: vectorstore.RetentionTTL, TTL: time.Hour},
+	}
+	i := 0
+	for source, want := range sources {
+		doc := testDocument(i, source)
+		doc.Metadata = map[string]string{"source": source}
+		i++
+		if err := engine.IndexDocument(ctx, "", doc, nil); err != nil {
+			t.Fatalf("IndexDocument failed: %v", err)
+		}
+
+		info, err := engine.GetDocument(ctx, "", doc.DocumentID)
+		if err != nil {
+			t.Fatalf("GetDocument failed: %v", err)
+		}
+		if info.Retention != want {
+			t.Errorf("document from %s has retention %+v, want %+v", source, info.Retention, want)
+		}
+		if (want.Policy == vectorstore.RetentionForever) != info.ExpiresAt.IsZero() {
+			t.Errorf("document from %s expires at %s under %s", source, info.ExpiresAt, info.Retention)
+		}
+	}
+
+	// Pinning keeps the policy; an expiry time alone makes it expire
+	pinned := true
+	info, err := engine.UpdateRetention(ctx, "", "doc-0", &RetentionUpdate{Pinned: &pinned})
+	if err != nil {
+		t.Fatalf("UpdateRetention failed: %v", err)
+	}
+	if !info.Retention.Pinned {
+		t.Errorf("document is not pinned after the update: %+v", info.Retention)
+	}
+
+	expiresAt := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	forever := vectorstore.RetentionForever
+	if _, err := engine.UpdateRetention(ctx, "", "doc-0", &RetentionUpdate{Policy: &forever}); err != nil {
+		t.Fatalf("UpdateRetention failed: %v", err)
+	}
+	info, err = engine.UpdateRetention(ctx, "", "doc-0", &RetentionUpdate{ExpiresAt: &expiresAt})
+	if err != nil {
+		t.Fatalf("UpdateRetention failed: %v", err)
+	}
+	if info.Retention.Policy != vectorstore.RetentionTTL || !info.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("document has retention %+v expiring at %s, want ttl expiring at %s", info.Retention, info.ExpiresAt, expiresAt)
+	}
+
+	if _, err := engine.UpdateRetention(ctx, "", "missing", &RetentionUpdate{Pinned: &pinned}); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("UpdateRetention of a missing document returned %v, want ErrDocumentNotFound", err)
+	}
+}
+
+func TestReindexDocument(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestEngine(t, &Config{})
+
+	for i := 0; i < 2; i++ {
+		if err := engine.IndexDocument(ctx, "", testDocument(i, "stale"), nil); err != nil {
+			t.Fatalf("IndexDocument failed: %v", err)
+		}
+	}
+
+	// The new version has fewer chunks than the old one
+	doc := testDocument(0, "fresh")
+	doc.Content = doc.Content[:2]
+	if err := engine.ReindexDocument(ctx, "", doc, nil); err != nil {
+		t.Fatalf("ReindexDocument failed: %v", err)
+	}
+
+	info, err := engine.GetDocument(ctx, "", "doc-0")
+	if err != nil {
+		t.Fatalf("GetDocument failed: %v", err)
+	}
+	if info.Chunks != 2 || info.Title != "fresh title" {
+		t.Errorf("reindexed document has %d chunks titled %q, want 2 titled %q", info.Chunks, info.Title, "fresh title")
+	}
+
+	page, err := engine.Search(ctx, &SearchRequest{Query: "stale chunk of document 0", Limit: 20})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(page.Results) != 5 {
+		t.Errorf("search found %d chunks, want 5", len(page.Results))
+	}
+	for _, result := range page.Results {
+		if result.DocumentID == "doc-0" && strings.HasPrefix(result.ChunkContent, "stale") {
+			t.Errorf("search returned the stale chunk %q", result.ChunkContent)
+		}
+	}
+
+	if err := engine.DeleteDocument(ctx, "", "doc-0"); err != nil {
+		t.Fatalf("DeleteDocument failed: %v", err)
+	}
+	if _, err := engine.GetDocument(ctx, "", "doc-0"); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("GetDocument of a deleted document returned %v, want ErrDocumentNotFound", err)
+	}
+	if _, err := engine.GetDocument(ctx, "", "doc-1"); err != nil {
+		t.Errorf("GetDocument of another document failed: %v", err)
+	}
+}
+
+// newTestEngine creates an engine over an in-memory store and cleans it up
+// when the test ends
+func newTestEngine(t *testing.T, config *Config) *Engine {
+	t.Helper()
+
+	engine, err := NewEngine(config, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	t.Cleanup(engine.Cleanup)
+
+	return engine
+}
+
+// testDocument makes a document of three chunks labelled with a version
+func testDocument(i int, version string) *document.ProcessorResult {
+	return &document.ProcessorResult{
+		DocumentID: fmt.Sprintf("doc-%d", i),
+		Title:      version + " title",
+		Content: []string{
+			fmt.Sprintf("%s chunk one of document %d", version, i),
+			fmt.Sprintf("%s chunk two of document %d", version, i),
+			fmt.Sprintf("%s chunk three of document %d", version, i),
+		},
+	}
+}
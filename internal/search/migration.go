@@ -0,0 +1,810 @@
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sanjeevkumarraob/semantic-search-service/pkg/vectorstore"
+)
+
+// MigrationState is the stage of an embedding migration
+type MigrationState string
+
+const (
+	// MigrationCopying re-embeds every document stored under the current model
+	MigrationCopying MigrationState = "copying"
+	// MigrationCatchingUp re-embeds the documents changed while copying
+	MigrationCatchingUp MigrationState = "catching_up"
+	// MigrationComplete has switched searches and writes to the new model
+	MigrationComplete MigrationState = "complete"
+	// MigrationFailed has stopped on an error that retrying cannot fix
+	MigrationFailed MigrationState = "failed"
+)
+
+// Timing of embedding migrations
+const (
+	// migrationIdle is how long the change feed must stay quiet before
+	// the migration cuts over
+	migrationIdle            = time.Second
+	migrationRetryBaseDelay  = 5 * time.Second
+	migrationRetryMaxDelay   = 5 * time.Minute
+	migratedCollectionSuffix = ".m"
+)
+
+// MigrationStatus reports the progress of a migration to another
+// embedding model
+type MigrationStatus struct {
+	State MigrationState             `json:"state"`
+	From  vectorstore.EmbeddingModel `json:"from"`
+	To    vectorstore.EmbeddingModel `json:"to"`
+	// Collections maps each collection onto the one it is migrated to
+	Collections map[string]string `json:"collections"`
+	// Documents counts the documents re-embedded, Skipped the ones found
+	// already migrated by an earlier attempt
+	Documents int `json:"documents"`
+	Chunks    int `json:"chunks"`
+	Skipped   int `json:"skipped"`
+	// Attempts counts the runs of the migration; failed runs are retried
+	// and resume where they stopped
+	Attempts    int        `json:"attempts"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// migration re-embeds the documents of every collection with another
+// model into a collection of their own, then cuts the engine over to it.
+// Writes made while copying are found through the change feed of the
+// vector store, which therefore must be enabled; with the Qdrant backend
+// it only covers the writes of this replica. The collections left behind
+// are emptied after the cutover.
+type migration struct {
+	engine   *Engine
+	embedder Embedder
+	model    vectorstore.EmbeddingModel
+
+	lock   sync.Mutex
+	status MigrationStatus
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startMigration starts migrating the engine to the embedder of config,
+// unless the engine already uses its model
+func (e *Engine) startMigration(config *EmbedderConfig) error {
+	embedder, err := NewEmbedder(config)
+	if err != nil {
+		return fmt.Errorf("failed to initialize the next embedder: %w", err)
+	}
+
+	model := vectorstore.EmbeddingModel{ID: embedder.ModelID(), Version: config.ModelVersion}
+	if model == e.model {
+		e.logger.Printf("Already using embedding model %s; nothing to migrate", model)
+		embedder.Close()
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.migration = &migration{
+		engine:   e,
+		embedder: embedder,
+		model:    model,
+		status: MigrationStatus{
+			State:     MigrationCopying,
+			From:      e.model,
+			To:        model,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	e.logger.Printf("Migrating from embedding model %s to %s", e.model, model)
+
+	go e.migration.run(ctx)
+
+	return nil
+}
+
+// Migration reports the progress of the migration to the next embedding
+// model; nil if none is configured
+func (e *Engine) Migration() *MigrationStatus {
+	if e.migration == nil {
+		return nil
+	}
+	return e.migration.snapshot()
+}
+
+// migratedCollection names the collection holding the embeddings of a
+// model for a collection
+func migratedCollection(name string, model vectorstore.EmbeddingModel) string {
+	sum := sha256.Sum256([]byte(model.String()))
+	return name + migratedCollectionSuffix + hex.EncodeToString(sum[:4])
+}
+
+// isMigratedCollection reports whether a collection holds the migrated
+// embeddings of another one of the given names
+func isMigratedCollection(name string, names map[string]bool) bool {
+	i := strings.LastIndex(name, migratedCollectionSuffix)
+	if i < 0 || len(name)-i != len(migratedCollectionSuffix)+8 {
+		return false
+	}
+	if _, err := hex.DecodeString(name[i+len(migratedCollectionSuffix):]); err != nil {
+		return false
+	}
+	return names[name[:i]]
+}
+
+// loadCollections maps each collection onto the one migrated to the
+// engine's model, if there is one. The caller must hold the lock or be
+// the only user of the engine.
+func (e *Engine) loadCollections(ctx context.Context) error {
+	infos, err := e.vectorStore.ListCollections(ctx)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		names[info.Name] = true
+	}
+
+	e.collections = make(map[string]string)
+	for name := range names {
+		if migrated := migratedCollection(name, e.model); names[migrated] {
+			e.collections[name] = migrated
+		}
+	}
+
+	return nil
+}
+
+// resolve returns the collection holding the embeddings of the engine's
+// model for a collection name; an empty name selects the default
+// collection. The caller must hold the lock.
+func (e *Engine) resolve(name string) string {
+	key := name
+	if key == "" {
+		key = e.defaultCollection
+	}
+	if migrated, exists := e.collections[key]; exists {
+		return migrated
+	}
+	return name
+}
+
+// resolveAll resolves several collection names. The caller must hold the
+// lock.
+func (e *Engine) resolveAll(names []string) []string {
+	if len(names) == 0 {
+		if migrated, exists := e.collections[e.defaultCollection]; exists {
+			return []string{migrated}
+		}
+		return nil
+	}
+
+	resolved := make([]string, len(names))
+	for i, name := range names {
+		resolved[i] = e.resolve(name)
+	}
+	return resolved
+}
+
+// collectionName maps a migrated collection back onto the name it is
+// searched by. The caller must hold the lock.
+func (e *Engine) collectionName(collection string) string {
+	for name, migrated := range e.collections {
+		if migrated == collection {
+			return name
+		}
+	}
+	return collection
+}
+
+// run migrates until the cutover, retrying failed attempts with
+// exponential backoff. Each attempt skips the documents already migrated.
+func (m *migration) run(ctx context.Context) {
+	defer close(m.done)
+
+	logger := m.engine.logger
+	delay := migrationRetryBaseDelay
+	for {
+		err := m.migrate(ctx)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+
+		if errors.Is(err, vectorstore.ErrChangeFeedDisabled) {
+			logger.Printf("Embedding migration to %s failed: %v", m.model, err)
+			m.update(func(status *MigrationStatus) {
+				status.State = MigrationFailed
+				status.Error = err.Error()
+			})
+			return
+		}
+
+		logger.Printf("Embedding migration to %s failed, retrying in %s: %v", m.model, delay, err)
+		m.update(func(status *MigrationStatus) {
+			status.Error = err.Error()
+		})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		delay = min(delay*2, migrationRetryMaxDelay)
+	}
+}
+
+// migrate makes one attempt: it copies every collection, catches up with
+// the changes made meanwhile and cuts over
+func (m *migration) migrate(ctx context.Context) error {
+	m.update(func(status *MigrationStatus) {
+		status.State = MigrationCopying
+		status.Attempts++
+	})
+
+	// Changes from here on are replayed after the copy
+	stream, err := m.engine.vectorStore.Subscribe(ctx, 0)
+	if err != nil {
+		return err
+	}
+	queue := newChangeQueue(ctx, stream, migratedCollection("", m.model))
+	defer queue.stop()
+
+	targets, err := m.prepare(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range slices.Sorted(maps.Keys(targets)) {
+		if err := m.copyCollection(ctx, source, targets[source]); err != nil {
+			return err
+		}
+	}
+
+	m.update(func(status *MigrationStatus) {
+		status.State = MigrationCatchingUp
+	})
+	if err := m.catchUp(ctx, queue, targets); err != nil {
+		return err
+	}
+
+	sources, err := m.cutOver(ctx, queue, targets)
+	if err != nil {
+		return err
+	}
+
+	m.clear(ctx, sources)
+
+	return nil
+}
+
+// prepare creates a collection for the new model's embeddings of each
+// collection and returns the collections to copy, mapped onto their
+// targets
+func (m *migration) prepare(ctx context.Context) (map[string]string, error) {
+	e := m.engine
+	store := e.vectorStore
+
+	infos, err := store.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]*vectorstore.CollectionInfo, len(infos))
+	names := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		existing[info.Name] = info
+		names[info.Name] = true
+	}
+
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	targets := make(map[string]string)
+	collections := make(map[string]string)
+	for name := range names {
+		if isMigratedCollection(name, names) {
+			continue
+		}
+
+		source := e.resolve(name)
+		if existing[source] == nil {
+			source = name
+		}
+		target := migratedCollection(name, m.model)
+		if _, exists := existing[target]; !exists {
+			config := existing[source].CollectionConfig
+			config.Name = target
+			config.VectorSize = m.embedder.VectorSize()
+			err := store.CreateCollection(ctx, &config)
+			if err != nil && !errors.Is(err, vectorstore.ErrCollectionExists) {
+				return nil, fmt.Errorf("failed to create collection %s: %w", target, err)
+			}
+		}
+
+		targets[source] = target
+		collections[name] = target
+	}
+
+	m.update(func(status *MigrationStatus) {
+		status.Collections = collections
+	})
+
+	return targets, nil
+}
+
+// copyCollection re-embeds every document of a collection into its target
+func (m *migration) copyCollection(ctx context.Context, source, target string) error {
+	scroller := vectorstore.NewScroller(m.engine.vectorStore, vectorstore.ScrollParams{Collection: source})
+
+	copied := make(map[string]bool)
+	for scroller.Next(ctx) {
+		documentID := scroller.Item().DocumentID
+		if copied[documentID] {
+			continue
+		}
+		copied[documentID] = true
+
+		if err := m.copyDocument(ctx, source, target, documentID); err != nil {
+			return err
+		}
+	}
+	if err := scroller.Err(); err != nil {
+		return fmt.Errorf("failed to read collection %s: %w", source, err)
+	}
+
+	return nil
+}
+
+// copyDocument makes the target's copy of a document match the source,
+// embedding its chunks with the new model unless the copy is up to date
+func (m *migration) copyDocument(ctx context.Context, source, target, documentID string) error {
+	store := m.engine.vectorStore
+
+	chunks, err := readDocument(ctx, store, source, documentID)
+	if err != nil {
+		return fmt.Errorf("failed to read document %s: %w", documentID, err)
+	}
+	if len(chunks) == 0 {
+		if err := store.DeleteDocument(ctx, target, documentID); err != nil && !errors.Is(err, vectorstore.ErrNotFound) {
+			return fmt.Errorf("failed to delete document %s from %s: %w", documentID, target, err)
+		}
+		return nil
+	}
+
+	copies, err := readDocument(ctx, store, target, documentID)
+	if err != nil {
+		return fmt.Errorf("failed to read document %s from %s: %w", documentID, target, err)
+	}
+	if m.isCopied(chunks, copies) {
+		m.update(func(status *MigrationStatus) {
+			status.Skipped++
+		})
+		return nil
+	}
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Content
+	}
+	embeddings, err := m.engine.embedChunks(ctx, m.embedder, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed document %s: %w", documentID, err)
+	}
+
+	items := make([]*vectorstore.Item, len(chunks))
+	for i, chunk := range chunks {
+		item := *chunk
+		item.Collection = target
+		item.Vector = embeddings[i]
+		item.Model = m.model
+		items[i] = &item
+	}
+	if err := store.ReplaceDocument(ctx, target, documentID, items); err != nil {
+		return fmt.Errorf("failed to store document %s in %s: %w", documentID, target, err)
+	}
+
+	m.update(func(status *MigrationStatus) {
+		status.Documents++
+		status.Chunks += len(items)
+	})
+
+	return nil
+}
+
+// readDocument returns the live chunks of a document. Unlike GetDocument,
+// it reads them without counting a hit or refreshing a sliding expiry, so
+// that the migration leaves the retention and eviction order alone.
+func readDocument(ctx context.Context, store vectorstore.VectorStore, collection, documentID string) ([]*vectorstore.Item, error) {
+	var chunks []*vectorstore.Item
+	scroller := vectorstore.NewScroller(store, vectorstore.ScrollParams{Collection: collection, DocumentID: documentID})
+	for scroller.Next(ctx) {
+		chunks = append(chunks, scroller.Item())
+	}
+	return chunks, scroller.Err()
+}
+
+// isCopied reports whether copies holds every chunk, embedded with the new
+// model and otherwise unchanged. Expiries are not compared: a copy expires
+// by the same retention, but counts its TTL from when it was written.
+func (m *migration) isCopied(chunks, copies []*vectorstore.Item) bool {
+	if len(chunks) != len(copies) {
+		return false
+	}
+
+	byID := make(map[string]*vectorstore.Item, len(copies))
+	for _, c := range copies {
+		byID[c.ID] = c
+	}
+	for _, chunk := range chunks {
+		c, exists := byID[chunk.ID]
+		if !exists || c.Model != m.model || c.Content != chunk.Content || c.Title != chunk.Title ||
+			!maps.Equal(c.Metadata, chunk.Metadata) || !slices.Equal(c.Permissions, chunk.Permissions) ||
+			c.Retention != chunk.Retention {
+			return false
+		}
+	}
+	return true
+}
+
+// catchUp applies the changes made while copying until none has been made
+// for a while
+func (m *migration) catchUp(ctx context.Context, queue *changeQueue, targets map[string]string) error {
+	for {
+		idleCtx, cancel := context.WithTimeout(ctx, migrationIdle)
+		change, err := queue.next(idleCtx)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				return nil
+			}
+			return err
+		}
+
+		if err := m.apply(ctx, change, targets); err != nil {
+			return err
+		}
+	}
+}
+
+// apply copies a changed document to its target. Collections created
+// while migrating are migrated too; those dropped lose their target.
+func (m *migration) apply(ctx context.Context, change *vectorstore.Change, targets map[string]string) error {
+	store := m.engine.vectorStore
+
+	switch change.Type {
+	case vectorstore.ChangeCreateCollection:
+		if slices.Contains(slices.Collect(maps.Values(targets)), change.Collection) {
+			return nil
+		}
+		// A new collection is empty, so later changes fill its target
+		target := migratedCollection(change.Collection, m.model)
+		infos, err := store.ListCollections(ctx)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			if info.Name != change.Collection {
+				continue
+			}
+			config := info.CollectionConfig
+			config.Name = target
+			config.VectorSize = m.embedder.VectorSize()
+			if err := store.CreateCollection(ctx, &config); err != nil && !errors.Is(err, vectorstore.ErrCollectionExists) {
+				return fmt.Errorf("failed to create collection %s: %w", target, err)
+			}
+			targets[change.Collection] = target
+			m.update(func(status *MigrationStatus) {
+				status.Collections[change.Collection] = target
+			})
+		}
+		return nil
+
+	case vectorstore.ChangeDropCollection:
+		target, exists := targets[change.Collection]
+		if !exists {
+			return nil
+		}
+		delete(targets, change.Collection)
+		if err := store.DropCollection(ctx, target); err != nil && !errors.Is(err, vectorstore.ErrCollectionNotFound) {
+			return fmt.Errorf("failed to drop collection %s: %w", target, err)
+		}
+		m.update(func(status *MigrationStatus) {
+			for name, migrated := range status.Collections {
+				if migrated == target {
+					delete(status.Collections, name)
+				}
+			}
+		})
+		return nil
+	}
+
+	target, exists := targets[change.Collection]
+	// Changes without a document expire whole collections, and the
+	// copies carry the same retention
+	if !exists || change.DocumentID == "" {
+		return nil
+	}
+
+	return m.copyDocument(ctx, change.Collection, target, change.DocumentID)
+}
+
+// cutOver applies the changes of the writes in flight, then switches the
+// engine to the new model and its collections. It returns the collections
+// that were left behind.
+func (m *migration) cutOver(ctx context.Context, queue *changeQueue, targets map[string]string) ([]string, error) {
+	e := m.engine
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	// No write can start now, and the finished ones have published their
+	// changes, so a cancelled context reads only those
+	if err := queue.drain(); err != nil {
+		return nil, err
+	}
+	drained, cancel := context.WithCancel(ctx)
+	cancel()
+	for {
+		change, err := queue.next(drained)
+		if errors.Is(err, context.Canceled) && ctx.Err() == nil {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := m.apply(ctx, change, targets); err != nil {
+			return nil, err
+		}
+	}
+
+	previous := e.embedder
+	e.embedder = m.embedder
+	e.model = m.model
+
+	status := m.snapshot()
+	e.collections = status.Collections
+	previous.Close()
+
+	now := time.Now()
+	m.update(func(status *MigrationStatus) {
+		status.State = MigrationComplete
+		status.Error = ""
+		status.CompletedAt = &now
+	})
+	e.logger.Printf("Cut over to embedding model %s after re-embedding %d documents; configure it as the current model before restarting",
+		m.model, status.Documents)
+
+	return slices.Collect(maps.Keys(targets)), nil
+}
+
+// documentKey identifies a document of a collection
+type documentKey struct {
+	collection string
+	documentID string
+}
+
+// changeQueue reads a change stream in the background from the start of a
+// migration, so that the feed cannot discard the changes made while
+// copying before they are caught up with. It leaves out the migration's
+// own writes to its targets, and holds one change per document until it
+// is taken, since applying a change copies the document as it is by then.
+type changeQueue struct {
+	stream *vectorstore.ChangeStream
+	// skip is the suffix of the target collections
+	skip string
+
+	lock    sync.Mutex
+	changes []*vectorstore.Change
+	pending map[documentKey]bool
+	err     error
+	notify  chan struct{}
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newChangeQueue starts queueing the changes of a stream, skipping those
+// of collections named with the suffix skip
+func newChangeQueue(ctx context.Context, stream *vectorstore.ChangeStream, skip string) *changeQueue {
+	ctx, cancel := context.WithCancel(ctx)
+	q := &changeQueue{
+		stream:  stream,
+		skip:    skip,
+		pending: make(map[documentKey]bool),
+		notify:  make(chan struct{}),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	go q.read(ctx)
+
+	return q
+}
+
+// read queues the changes of the stream until the context is cancelled or
+// the stream fails
+func (q *changeQueue) read(ctx context.Context) {
+	defer close(q.done)
+
+	for {
+		change, err := q.stream.Next(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				q.lock.Lock()
+				q.err = err
+				close(q.notify)
+				q.notify = make(chan struct{})
+				q.lock.Unlock()
+			}
+			return
+		}
+		q.push(change)
+	}
+}
+
+// push queues a change unless it is skipped or its document is already
+// queued
+func (q *changeQueue) push(change *vectorstore.Change) {
+	if strings.HasSuffix(change.Collection, q.skip) {
+		return
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	key := documentKey{collection: change.Collection, documentID: change.DocumentID}
+	switch {
+	case change.DocumentID == "":
+		// Document changes queued before a collection change must not
+		// absorb the ones made after it
+		clear(q.pending)
+	case q.pending[key]:
+		return
+	default:
+		q.pending[key] = true
+	}
+
+	q.changes = append(q.changes, change)
+	close(q.notify)
+	q.notify = make(chan struct{})
+}
+
+// next takes the oldest queued change, waiting for one. It returns the
+// error that stopped the stream once the queue is empty.
+func (q *changeQueue) next(ctx context.Context) (*vectorstore.Change, error) {
+	for {
+		q.lock.Lock()
+		if len(q.changes) > 0 {
+			change := q.changes[0]
+			q.changes = q.changes[1:]
+			delete(q.pending, documentKey{collection: change.Collection, documentID: change.DocumentID})
+			q.lock.Unlock()
+			return change, nil
+		}
+		if q.err != nil {
+			err := q.err
+			q.lock.Unlock()
+			return nil, err
+		}
+		notify := q.notify
+		q.lock.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// drain stops reading in the background and queues the changes published
+// so far
+func (q *changeQueue) drain() error {
+	q.stop()
+
+	drained, cancel := context.WithCancel(context.Background())
+	cancel()
+	for {
+		change, err := q.stream.Next(drained)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		q.push(change)
+	}
+}
+
+// stop stops reading in the background and waits for the reader to return
+func (q *changeQueue) stop() {
+	q.cancel()
+	<-q.done
+}
+
+// clear empties the collections left behind by the cutover, so that they
+// cannot be migrated again over newer writes. Collections made by earlier
+// migrations are dropped; failures are only logged.
+func (m *migration) clear(ctx context.Context, sources []string) {
+	e := m.engine
+	store := e.vectorStore
+
+	infos, err := store.ListCollections(ctx)
+	if err != nil {
+		e.logger.Printf("Failed to clear the collections of embedding model %s: %v", m.snapshot().From, err)
+		return
+	}
+	names := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		names[info.Name] = true
+	}
+
+	for _, source := range sources {
+		if isMigratedCollection(source, names) {
+			if err := store.DropCollection(ctx, source); err != nil {
+				e.logger.Printf("Failed to drop collection %s: %v", source, err)
+			}
+			continue
+		}
+
+		var documents []string
+		seen := make(map[string]bool)
+		scroller := vectorstore.NewScroller(store, vectorstore.ScrollParams{Collection: source})
+		for scroller.Next(ctx) {
+			if documentID := scroller.Item().DocumentID; !seen[documentID] {
+				seen[documentID] = true
+				documents = append(documents, documentID)
+			}
+		}
+		if err := scroller.Err(); err != nil {
+			e.logger.Printf("Failed to clear collection %s: %v", source, err)
+			continue
+		}
+
+		for _, documentID := range documents {
+			if err := store.DeleteDocument(ctx, source, documentID); err != nil {
+				e.logger.Printf("Failed to delete document %s from collection %s: %v", documentID, source, err)
+			}
+		}
+	}
+}
+
+// stop cancels the migration and waits for it to return
+func (m *migration) stop() {
+	m.cancel()
+	<-m.done
+	if m.snapshot().State != MigrationComplete {
+		m.embedder.Close()
+	}
+}
+
+// update changes the status under its lock
+func (m *migration) update(fn func(status *MigrationStatus)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	fn(&m.status)
+}
+
+// snapshot returns a copy of the status
+func (m *migration) snapshot() *MigrationStatus {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	status := m.status
+	status.Collections = maps.Clone(m.status.Collections)
+	return &status
+}
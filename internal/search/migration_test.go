@@ -0,0 +1,223 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sanjeevkumarraob/semantic-search-service/pkg/vectorstore"
+)
+
+func TestMigration(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestEngine(t, &Config{
+		Embedder: EmbedderConfig{Provider: ProviderFake, Dimensions: 16},
+		// A feed smaller than the migration's own writes must not make it
+		// lose the changes it catches up with
+		VectorStore: vectorstore.Config{ChangeFeedSize: 16},
+	})
+
+	for i := 0; i < 40; i++ {
+		if err := engine.IndexDocument(ctx, "", testDocument(i, "before"), nil); err != nil {
+			t.Fatalf("IndexDocument failed: %v", err)
+		}
+	}
+
+	next := &EmbedderConfig{Provider: ProviderHashing, Dimensions: 32}
+	if err := engine.startMigration(next); err != nil {
+		t.Fatalf("startMigration failed: %v", err)
+	}
+
+	// Documents written while copying are caught up with
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 20; i < 60; i++ {
+			if err := engine.ReindexDocument(ctx, "", testDocument(i, "during"), nil); err != nil {
+				t.Errorf("ReindexDocument failed: %v", err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	status := waitForMigration(t, engine)
+	if status.State != MigrationComplete {
+		t.Fatalf("migration ended in state %s: %s", status.State, status.Error)
+	}
+	if status.Attempts != 1 {
+		t.Errorf("migration took %d attempts, want 1 (last error: %s)", status.Attempts, status.Error)
+	}
+
+	for i := 0; i < 60; i++ {
+		info, err := engine.GetDocument(ctx, "", fmt.Sprintf("doc-%d", i))
+		if err != nil {
+			t.Fatalf("GetDocument of doc-%d failed: %v", i, err)
+		}
+		want := "before"
+		if i >= 20 {
+			want = "during"
+		}
+		if info.Title != want+" title" {
+			t.Errorf("doc-%d has title %q after the migration, want %q", i, info.Title, want+" title")
+		}
+	}
+
+	page, err := engine.Search(ctx, &SearchRequest{Query: "during chunk", Limit: 5})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(page.Results) == 0 {
+		t.Errorf("Search found nothing after the migration")
+	}
+
+	// The collection left behind is emptied
+	chunks, err := engine.vectorStore.GetDocument(ctx, vectorstore.DefaultCollection, "doc-0")
+	if err != nil {
+		t.Fatalf("GetDocument failed: %v", err)
+	}
+	if len(chunks) != 0 {
+		t.Errorf("%d chunks left in the migrated collection", len(chunks))
+	}
+}
+
+func TestMigrationSkipsCopiedDocuments(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestEngine(t, &Config{
+		Embedder: EmbedderConfig{Provider: ProviderFake, Dimensions: 16},
+	})
+	if err := engine.CreateCollection(ctx, &vectorstore.CollectionConfig{Name: "sliding", TTL: time.Second, Sliding: true}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := engine.IndexDocument(ctx, "sliding", testDocument(i, "before"), nil); err != nil {
+			t.Fatalf("IndexDocument failed: %v", err)
+		}
+	}
+
+	// Copy the documents as a failed attempt would have left them
+	m := &migration{
+		engine:   engine,
+		embedder: mustEmbedder(t, &EmbedderConfig{Provider: ProviderHashing, Dimensions: 32}),
+		status:   MigrationStatus{Collections: make(map[string]string)},
+	}
+	m.model = vectorstore.EmbeddingModel{ID: m.embedder.ModelID()}
+	t.Cleanup(func() { m.embedder.Close() })
+
+	targets, err := m.prepare(ctx)
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	if err := m.copyCollection(ctx, "sliding", targets["sliding"]); err != nil {
+		t.Fatalf("copyCollection failed: %v", err)
+	}
+
+	// Reading the sources slides their expiry past that of the copies
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		if _, err := engine.GetDocument(ctx, "sliding", fmt.Sprintf("doc-%d", i)); err != nil {
+			t.Fatalf("GetDocument failed: %v", err)
+		}
+	}
+
+	if err := m.copyCollection(ctx, "sliding", targets["sliding"]); err != nil {
+		t.Fatalf("copyCollection failed: %v", err)
+	}
+	if status := m.snapshot(); status.Documents != 5 || status.Skipped != 5 {
+		t.Errorf("copied %d and skipped %d documents, want 5 and 5", status.Documents, status.Skipped)
+	}
+}
+
+func TestChangeQueue(t *testing.T) {
+	ctx := context.Background()
+	store, err := vectorstore.New(&vectorstore.Config{VectorSize: 2, ChangeFeedSize: 4})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer store.Close()
+
+	model := vectorstore.EmbeddingModel{ID: "next"}
+	target := migratedCollection(vectorstore.DefaultCollection, model)
+	if err := store.CreateCollection(ctx, &vectorstore.CollectionConfig{Name: target, VectorSize: 2}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+
+	stream, err := store.Subscribe(ctx, 0)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	queue := newChangeQueue(ctx, stream, migratedCollection("", model))
+	defer queue.stop()
+
+	// More changes than the feed keeps, most of them to the target
+	item := func(collection, documentID string) *vectorstore.Item {
+		return &vectorstore.Item{ID: documentID + "-0", DocumentID: documentID, Collection: collection, Vector: []float32{1, 0}}
+	}
+	for i := 0; i < 10; i++ {
+		if err := store.Store(ctx, item(vectorstore.DefaultCollection, "a")); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+		if err := store.Store(ctx, item(target, "a")); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := store.Store(ctx, item(vectorstore.DefaultCollection, "b")); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	if err := queue.drain(); err != nil {
+		t.Fatalf("drain failed: %v", err)
+	}
+	drained, cancel := context.WithCancel(ctx)
+	cancel()
+
+	var documents []string
+	for {
+		change, err := queue.next(drained)
+		if errors.Is(err, context.Canceled) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next failed: %v", err)
+		}
+		if change.Collection != vectorstore.DefaultCollection {
+			t.Errorf("queued a change of collection %s", change.Collection)
+		}
+		documents = append(documents, change.DocumentID)
+	}
+	if len(documents) != 2 || documents[0] != "a" || documents[1] != "b" {
+		t.Errorf("queued changes of documents %v, want [a b]", documents)
+	}
+}
+
+// mustEmbedder creates an embedder or fails the test
+func mustEmbedder(t *testing.T, config *EmbedderConfig) Embedder {
+	t.Helper()
+
+	embedder, err := NewEmbedder(config)
+	if err != nil {
+		t.Fatalf("NewEmbedder failed: %v", err)
+	}
+	return embedder
+}
+
+// waitForMigration waits for the engine's migration to end
+func waitForMigration(t *testing.T, engine *Engine) *MigrationStatus {
+	t.Helper()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		status := engine.Migration()
+		if status.State == MigrationComplete || status.State == MigrationFailed {
+			return status
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("migration did not end: %+v", engine.Migration())
+	return nil
+}
@@ -0,0 +1,1131 @@
+package vectorstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Settings of the bolt backend
+const (
+	boltFileName  = "vectors.db"
+	boltLoadBatch = 256
+	// boltHeaderSize is the length of the expiry and flags stored ahead of
+	// each encoded item
+	boltHeaderSize = 9
+)
+
+// Flags in the header of a stored item
+const (
+	boltFlagPinned  byte = 1 << 0
+	boltFlagSliding byte = 1 << 1
+)
+
+// Top-level buckets of the bolt database. The items and documents buckets
+// hold one nested bucket per collection.
+var (
+	boltCollectionsBucket = []byte("collections")
+	boltItemsBucket       = []byte("items")
+	boltDocumentsBucket   = []byte("documents")
+)
+
+// BoltStore keeps items in an embedded bbolt database file under
+// Config.DataDir and scores searches against an in-memory index of their
+// vectors, which is rebuilt from the database on startup. The index keeps
+// everything searches filter on; item contents and exact vectors stay on
+// disk and are read back for results.
+//
+// Writes go to the database before the index and deletes to the index
+// before the database, so a search never finds an item that is not on disk.
+// A write the index refuses is undone on disk, and a collection that fails
+// to drop from the database is restored to the index.
+type BoltStore struct {
+	config      *Config
+	db          *bolt.DB
+	index       *MemoryStore
+	changes     *changeFeed // nil when disabled
+	configs     map[string]CollectionConfig
+	defaultName string
+	lock        sync.RWMutex // guards configs and closed
+	writeLock   sync.Mutex   // orders writes to the database and the index
+	closeChan   chan struct{}
+	closed      bool
+}
+
+// NewBoltStore opens or creates the database in Config.DataDir and loads
+// its items into the search index
+func NewBoltStore(config *Config) (*BoltStore, error) {
+	if config.DataDir == "" {
+		return nil, fmt.Errorf("bolt backend requires a data directory")
+	}
+
+	defaults := defaultCollectionConfig(config)
+	if err := defaults.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(config.DataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	db, err := bolt.Open(filepath.Join(config.DataDir, boltFileName), 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database: %w", err)
+	}
+
+	// The index is neither persistent nor bounded: the database is the
+	// record, and every stored item must stay searchable. Changes are
+	// published by the store rather than the index, which would report
+	// every item loaded on startup.
+	index, err := NewMemoryStore(&Config{
+		Collection:     config.Collection,
+		VectorSize:     config.VectorSize,
+		TTL:            config.TTL,
+		Index:          config.Index,
+		Quantization:   config.Quantization,
+		Reduction:      config.Reduction,
+		Shards:         config.Shards,
+		ChangeFeedSize: -1,
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	store := &BoltStore{
+		config:      config,
+		db:          db,
+		index:       index,
+		changes:     newChangeFeed(config),
+		configs:     make(map[string]CollectionConfig),
+		defaultName: defaults.Name,
+		closeChan:   make(chan struct{}),
+	}
+
+	if err := store.load(defaults); err != nil {
+		index.Close()
+		db.Close()
+		return nil, fmt.Errorf("failed to load bolt database: %w", err)
+	}
+
+	// Start cleanup goroutine for expired items
+	go store.cleanupRoutine()
+
+	return store, nil
+}
+
+// CreateCollection adds a new collection
+func (b *BoltStore) CreateCollection(ctx context.Context, config *CollectionConfig) error {
+	collectionConfig := *config
+	if err := collectionConfig.validate(); err != nil {
+		return err
+	}
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	if err := b.checkOpen(); err != nil {
+		return err
+	}
+
+	if err := b.index.CreateCollection(ctx, &collectionConfig); err != nil {
+		return err
+	}
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return putBoltCollection(tx, &collectionConfig)
+	})
+	if err != nil {
+		_ = b.index.DropCollection(ctx, collectionConfig.Name)
+		return fmt.Errorf("failed to store collection %s: %w", collectionConfig.Name, err)
+	}
+
+	b.lock.Lock()
+	b.configs[collectionConfig.Name] = collectionConfig
+	b.lock.Unlock()
+
+	b.changes.publish(Change{Type: ChangeCreateCollection, Collection: collectionConfig.Name})
+
+	return nil
+}
+
+// ListCollections describes every collection, sorted by name. The sizes
+// are those of the search index.
+func (b *BoltStore) ListCollections(ctx context.Context) ([]*CollectionInfo, error) {
+	if err := b.checkOpen(); err != nil {
+		return nil, err
+	}
+	return b.index.ListCollections(ctx)
+}
+
+// DropCollection removes a collection and everything stored in it.
+// The default collection cannot be dropped.
+func (b *BoltStore) DropCollection(ctx context.Context, name string) error {
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	if err := b.checkOpen(); err != nil {
+		return err
+	}
+
+	config, err := b.collectionConfig(name)
+	if err != nil {
+		return err
+	}
+	if err := b.index.DropCollection(ctx, name); err != nil {
+		return err
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltCollectionsBucket).Delete([]byte(name)); err != nil {
+			return err
+		}
+		for _, root := range [][]byte{boltItemsBucket, boltDocumentsBucket} {
+			if err := tx.Bucket(root).DeleteBucket([]byte(name)); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// The collection is still on disk, so it must stay searchable
+		if restoreErr := b.restoreCollection(ctx, config); restoreErr != nil {
+			return fmt.Errorf("failed to drop collection %s: %w (and failed to restore its index: %v)", name, err, restoreErr)
+		}
+		return fmt.Errorf("failed to drop collection %s: %w", name, err)
+	}
+
+	b.lock.Lock()
+	delete(b.configs, name)
+	b.lock.Unlock()
+
+	b.changes.publish(Change{Type: ChangeDropCollection, Collection: name})
+
+	return nil
+}
+
+// Store adds or updates a vector in the item's collection
+func (b *BoltStore) Store(ctx context.Context, item *Item) error {
+	return b.StoreBatch(ctx, item.Collection, []*Item{item})
+}
+
+// StoreBatch adds or updates several vectors in one collection in a single
+// database transaction
+func (b *BoltStore) StoreBatch(ctx context.Context, collection string, items []*Item) error {
+	config, err := b.collectionConfig(collection)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	prepared := make([]*Item, len(items))
+	for i, item := range items {
+		if err := config.check(item); err != nil {
+			return fmt.Errorf("item %s: %w", item.ID, err)
+		}
+		prepared[i] = config.prepare(item, now)
+	}
+
+	if len(prepared) == 0 {
+		return nil
+	}
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	if err := b.checkOpen(); err != nil {
+		return err
+	}
+
+	var previous map[string]*Item
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		previous, err = putBoltItems(tx, config.Name, prepared)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store items: %w", err)
+	}
+
+	if err := b.index.StoreBatch(ctx, config.Name, indexItems(prepared)); err != nil {
+		return b.undo(config.Name, previous, err)
+	}
+	if err := b.saveProjection(config.Name); err != nil {
+		return err
+	}
+
+	b.changes.publish(documentChanges(ChangeStore, config.Name, prepared)...)
+
+	return nil
+}
+
+// Get retrieves a vector by ID
+func (b *BoltStore) Get(ctx context.Context, collection, id string) (*Item, error) {
+	if err := b.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// The index decides whether the item is live and refreshes sliding items
+	indexed, err := b.index.Get(ctx, collection, id)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := b.hydrate([]*Item{indexed})
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("item with ID %s: %w", id, ErrNotFound)
+	}
+
+	return items[0], nil
+}
+
+// Delete removes a vector from the store
+func (b *BoltStore) Delete(ctx context.Context, collection, id string) error {
+	config, err := b.collectionConfig(collection)
+	if err != nil {
+		return err
+	}
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	if err := b.checkOpen(); err != nil {
+		return err
+	}
+
+	if err := b.index.Delete(ctx, config.Name, id); err != nil {
+		return err
+	}
+
+	var deleted *Item
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		deleted, err = deleteBoltItem(tx, config.Name, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete item %s: %w", id, err)
+	}
+
+	if deleted != nil {
+		b.changes.publish(Change{Type: ChangeDelete, Collection: config.Name, DocumentID: deleted.DocumentID, IDs: []string{id}})
+	}
+
+	return nil
+}
+
+// GetDocument returns the live chunks stored for a document
+func (b *BoltStore) GetDocument(ctx context.Context, collection, documentID string) ([]*Item, error) {
+	if err := b.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	indexed, err := b.index.GetDocument(ctx, collection, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.hydrate(indexed)
+}
+
+// DeleteDocument removes every chunk of a document
+func (b *BoltStore) DeleteDocument(ctx context.Context, collection, documentID string) error {
+	config, err := b.collectionConfig(collection)
+	if err != nil {
+		return err
+	}
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	if err := b.checkOpen(); err != nil {
+		return err
+	}
+
+	if err := b.index.DeleteDocument(ctx, config.Name, documentID); err != nil {
+		return err
+	}
+
+	var ids []string
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		ids, err = deleteBoltDocument(tx, config.Name, documentID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete document %s: %w", documentID, err)
+	}
+
+	if len(ids) > 0 {
+		b.changes.publish(Change{Type: ChangeDelete, Collection: config.Name, DocumentID: documentID, IDs: ids})
+	}
+
+	return nil
+}
+
+// ReplaceDocument atomically replaces all chunks of a document with items
+func (b *BoltStore) ReplaceDocument(ctx context.Context, collection, documentID string, items []*Item) error {
+	if err := checkDocumentItems(documentID, items); err != nil {
+		return err
+	}
+
+	config, err := b.collectionConfig(collection)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	prepared := make([]*Item, len(items))
+	for i, item := range items {
+		if err := config.check(item); err != nil {
+			return err
+		}
+		prepared[i] = config.prepare(item, now)
+	}
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	if err := b.checkOpen(); err != nil {
+		return err
+	}
+
+	// Chunks dropped by the new version are left on disk until the index
+	// has let go of them
+	var previous map[string]*Item
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		previous, err = putBoltItems(tx, config.Name, prepared)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store document %s: %w", documentID, err)
+	}
+
+	if err := b.index.ReplaceDocument(ctx, config.Name, documentID, indexItems(prepared)); err != nil {
+		return b.undo(config.Name, previous, err)
+	}
+	if err := b.saveProjection(config.Name); err != nil {
+		return err
+	}
+
+	keep := make(map[string]struct{}, len(prepared))
+	for _, item := range prepared {
+		keep[item.ID] = struct{}{}
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range boltDocumentItems(tx, config.Name, documentID) {
+			if _, kept := keep[id]; !kept {
+				if _, err := deleteBoltItem(tx, config.Name, id); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove stale chunks of document %s: %w", documentID, err)
+	}
+
+	change := Change{Type: ChangeReplace, Collection: config.Name, DocumentID: documentID}
+	for _, item := range prepared {
+		change.IDs = append(change.IDs, item.ID)
+	}
+	b.changes.publish(change)
+
+	return nil
+}
+
+// SetDocumentRetention changes the retention and expiry of every chunk of
+// a document
+func (b *BoltStore) SetDocumentRetention(ctx context.Context, collection, documentID string, retention Retention, expiresAt time.Time) error {
+	config, err := b.collectionConfig(collection)
+	if err != nil {
+		return err
+	}
+
+	retention, expiresAt, err = config.documentExpiry(retention, expiresAt, time.Now())
+	if err != nil {
+		return err
+	}
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	if err := b.checkOpen(); err != nil {
+		return err
+	}
+
+	var ids []string
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range boltDocumentItems(tx, config.Name, documentID) {
+			item, err := getBoltItem(tx, config.Name, id)
+			if err != nil || item == nil {
+				return err
+			}
+			if err := putBoltItem(tx, config.Name, item.withRetention(retention, expiresAt)); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set retention of document %s: %w", documentID, err)
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("document %s: %w", documentID, ErrNotFound)
+	}
+
+	if err := b.index.SetDocumentRetention(ctx, config.Name, documentID, retention, expiresAt); err != nil {
+		return err
+	}
+
+	b.changes.publish(Change{Type: ChangeRetention, Collection: config.Name, DocumentID: documentID, IDs: ids})
+
+	return nil
+}
+
+// Scroll returns a page of live items ordered by ID. The offset is the ID
+// of the last item of the previous page.
+func (b *BoltStore) Scroll(ctx context.Context, params *ScrollParams) (*ScrollPage, error) {
+	if err := b.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	page, err := b.index.Scroll(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := b.hydrate(page.Items)
+	if err != nil {
+		return nil, err
+	}
+	page.Items = items
+
+	return page, nil
+}
+
+// Search performs vector similarity search over one or more collections
+// using the in-memory index, then reads the contents of the results
+func (b *BoltStore) Search(ctx context.Context, params *SearchParams) ([]*SearchResult, error) {
+	if err := b.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// Exact vectors are read from disk along with the contents
+	indexParams := *params
+	indexParams.WithVectors = false
+	results, err := b.index.Search(ctx, &indexParams)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.db.View(func(tx *bolt.Tx) error {
+		for _, result := range results {
+			item, err := getBoltItem(tx, result.Collection, result.ID)
+			if err != nil {
+				return err
+			}
+			if item != nil {
+				result.Content = item.Content
+				if params.WithVectors {
+					result.Vector = item.Vector
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search results: %w", err)
+	}
+
+	return results, nil
+}
+
+// Stats reports the size of the search index
+func (b *BoltStore) Stats(ctx context.Context) (*Stats, error) {
+	if err := b.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	stats, err := b.index.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats.Backend = BackendBolt
+
+	return stats, nil
+}
+
+// Subscribe streams the changes numbered above after; an after of zero
+// streams only new changes
+func (b *BoltStore) Subscribe(ctx context.Context, after uint64) (*ChangeStream, error) {
+	if err := b.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	return b.changes.subscribe(after)
+}
+
+// Close saves the expiries of refreshed sliding items and closes the
+// database
+func (b *BoltStore) Close() error {
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.closeChan)
+	b.changes.close()
+	b.lock.Unlock()
+
+	err := b.reconcile(time.Now(), true)
+	if closeErr := b.index.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := b.db.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// cleanupRoutine periodically removes expired items
+func (b *BoltStore) cleanupRoutine() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.cleanupExpiredItems()
+		case <-b.closeChan:
+			return
+		}
+	}
+}
+
+// cleanupExpiredItems removes expired items from the database and the index
+func (b *BoltStore) cleanupExpiredItems() {
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	if b.checkOpen() != nil {
+		return
+	}
+
+	// Failures are retried on the next tick
+	_ = b.reconcile(time.Now(), false)
+}
+
+// reconcile brings the expiries on disk in line with the index. Items that
+// have expired on disk are deleted unless the index refreshed them, in
+// which case the refreshed expiry is written back; with all set, the
+// expiries of every refreshed sliding item are written back.
+// The caller must hold the write lock.
+func (b *BoltStore) reconcile(now time.Time, all bool) error {
+	ctx := context.Background()
+
+	for _, name := range b.collectionNames() {
+		var candidates []string
+		err := b.db.View(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(boltItemsBucket).Bucket([]byte(name))
+			if bucket == nil {
+				return nil
+			}
+			return bucket.ForEach(func(id, data []byte) error {
+				expiresAt, flags := boltHeader(data)
+				expired := flags&boltFlagPinned == 0 && expiresAt != 0 && now.UnixNano() > expiresAt
+				if expired || (all && flags&boltFlagSliding != 0) {
+					candidates = append(candidates, string(id))
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		var expired []*Item
+		for _, id := range candidates {
+			indexed := b.index.peek(name, id)
+			// The index may have dropped the item already
+			if indexed != nil && indexed.isExpired(now) {
+				if err := b.index.Delete(ctx, name, id); err != nil && !errors.Is(err, ErrNotFound) {
+					return err
+				}
+			}
+
+			err := b.db.Update(func(tx *bolt.Tx) error {
+				item, err := getBoltItem(tx, name, id)
+				if err != nil || item == nil {
+					return err
+				}
+				switch {
+				case indexed == nil || indexed.isExpired(now):
+					if item.isExpired(now) {
+						expired = append(expired, item)
+						_, err = deleteBoltItem(tx, name, id)
+					}
+					return err
+				case !indexed.ExpiresAt.Equal(item.ExpiresAt):
+					return putBoltItem(tx, name, item.withRetention(indexed.Retention, indexed.ExpiresAt))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		b.changes.publish(documentChanges(ChangeExpire, name, expired)...)
+	}
+
+	return nil
+}
+
+// load reads the collections and live items of the database into the
+// index. The default collection keeps its configured settings but for its
+// projection. Collections take their stored projections before their
+// items are loaded, so the items are reduced as they were before.
+func (b *BoltStore) load(defaults *CollectionConfig) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		for _, root := range [][]byte{boltCollectionsBucket, boltItemsBucket, boltDocumentsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(root); err != nil {
+				return err
+			}
+		}
+		if data := tx.Bucket(boltCollectionsBucket).Get([]byte(defaults.Name)); data != nil {
+			stored := &CollectionConfig{}
+			if err := gob.NewDecoder(bytes.NewReader(data)).Decode(stored); err != nil {
+				return fmt.Errorf("%w: collection: %v", ErrCorruptRecord, err)
+			}
+			defaults.Projection = stored.Projection
+		}
+		return putBoltCollection(tx, defaults)
+	})
+	if err != nil {
+		return err
+	}
+
+	var configs []*CollectionConfig
+	err = b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltCollectionsBucket).ForEach(func(_, data []byte) error {
+			config := &CollectionConfig{}
+			if err := gob.NewDecoder(bytes.NewReader(data)).Decode(config); err != nil {
+				return fmt.Errorf("%w: collection: %v", ErrCorruptRecord, err)
+			}
+			configs = append(configs, config)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	for _, config := range configs {
+		if config.Name != b.defaultName {
+			if err := b.index.CreateCollection(ctx, config); err != nil {
+				return err
+			}
+		}
+		b.configs[config.Name] = *config
+		b.index.adoptProjection(config.Name, config.Projection)
+
+		if err := b.loadItems(ctx, config.Name, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreCollection puts a collection dropped from the index back, along
+// with the items the database still holds. The caller must hold the write
+// lock.
+func (b *BoltStore) restoreCollection(ctx context.Context, config *CollectionConfig) error {
+	if err := b.index.CreateCollection(ctx, config); err != nil {
+		return err
+	}
+	b.index.adoptProjection(config.Name, config.Projection)
+
+	return b.loadItems(ctx, config.Name, time.Now())
+}
+
+// undo puts back the items a write replaced in the database, and removes
+// those it added, after the index refused the write. previous holds the
+// earlier version of each item, or nil if there was none. It returns err,
+// the index's reason for refusing. The caller must hold the write lock.
+func (b *BoltStore) undo(collection string, previous map[string]*Item, err error) error {
+	undoErr := b.db.Update(func(tx *bolt.Tx) error {
+		for id, item := range previous {
+			if item == nil {
+				if _, err := deleteBoltItem(tx, collection, id); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := putBoltItem(tx, collection, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if undoErr != nil {
+		return fmt.Errorf("%w (and failed to undo the write on disk: %v)", err, undoErr)
+	}
+	return err
+}
+
+// loadItems loads the live items of a collection into the index. The
+// caller must hold the write lock, or have the store to itself.
+func (b *BoltStore) loadItems(ctx context.Context, name string, now time.Time) error {
+	var batch []*Item
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltItemsBucket).Bucket([]byte(name))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, data []byte) error {
+			item, err := decodeBoltItem(data)
+			if err != nil {
+				return err
+			}
+			// Expired items are deleted by the next cleanup
+			if item.isExpired(now) {
+				return nil
+			}
+
+			batch = append(batch, indexItems([]*Item{item})...)
+			if len(batch) < boltLoadBatch {
+				return nil
+			}
+			err = b.index.StoreBatch(ctx, name, batch)
+			batch = batch[:0]
+			return err
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if err := b.index.StoreBatch(ctx, name, batch); err != nil {
+		return err
+	}
+	return b.saveProjection(name)
+}
+
+// saveProjection stores the projection the index has fitted on a
+// collection since the collection was stored. The caller must hold the
+// write lock, or have the store to itself.
+func (b *BoltStore) saveProjection(name string) error {
+	projection := b.index.projection(name)
+
+	b.lock.RLock()
+	config, exists := b.configs[name]
+	b.lock.RUnlock()
+
+	if !exists || projection == nil || config.Projection == projection {
+		return nil
+	}
+
+	config.Projection = projection
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return putBoltCollection(tx, &config)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store the projection of collection %s: %w", name, err)
+	}
+
+	b.lock.Lock()
+	b.configs[name] = config
+	b.lock.Unlock()
+
+	return nil
+}
+
+// hydrate replaces the contents and vectors of indexed items with those
+// on disk, dropping items that are no longer stored
+func (b *BoltStore) hydrate(indexed []*Item) ([]*Item, error) {
+	items := make([]*Item, 0, len(indexed))
+	err := b.db.View(func(tx *bolt.Tx) error {
+		for _, item := range indexed {
+			stored, err := getBoltItem(tx, item.Collection, item.ID)
+			if err != nil {
+				return err
+			}
+			if stored == nil {
+				continue
+			}
+
+			hydrated := *item
+			hydrated.Content = stored.Content
+			hydrated.Vector = stored.Vector
+			items = append(items, &hydrated)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read items: %w", err)
+	}
+
+	return items, nil
+}
+
+// checkOpen returns an error once the store has been closed
+func (b *BoltStore) checkOpen() error {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	if b.closed {
+		return ErrStoreClosed
+	}
+
+	return nil
+}
+
+// collectionConfig resolves a collection name; an empty name selects the default
+func (b *BoltStore) collectionConfig(name string) (*CollectionConfig, error) {
+	if name == "" {
+		name = b.defaultName
+	}
+
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	config, exists := b.configs[name]
+	if !exists {
+		return nil, fmt.Errorf("collection %s: %w", name, ErrCollectionNotFound)
+	}
+
+	return &config, nil
+}
+
+// collectionNames lists the collections, sorted by name
+func (b *BoltStore) collectionNames() []string {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	names := make([]string, 0, len(b.configs))
+	for name := range b.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// indexItems returns the copies of items kept in the index, without their
+// contents
+func indexItems(items []*Item) []*Item {
+	indexed := make([]*Item, len(items))
+	for i, item := range items {
+		stripped := *item
+		stripped.Content = ""
+		indexed[i] = &stripped
+	}
+	return indexed
+}
+
+// putBoltCollection stores a collection configuration and creates its buckets
+func putBoltCollection(tx *bolt.Tx, config *CollectionConfig) error {
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(config); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltCollectionsBucket).Put([]byte(config.Name), data.Bytes()); err != nil {
+		return err
+	}
+
+	for _, root := range [][]byte{boltItemsBucket, boltDocumentsBucket} {
+		if _, err := tx.Bucket(root).CreateBucketIfNotExists([]byte(config.Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putBoltItem stores an item and links it to its document, unlinking it
+// from the document it belonged to before
+func putBoltItem(tx *bolt.Tx, collection string, item *Item) error {
+	items := tx.Bucket(boltItemsBucket).Bucket([]byte(collection))
+	documents := tx.Bucket(boltDocumentsBucket).Bucket([]byte(collection))
+	if items == nil || documents == nil {
+		return fmt.Errorf("collection %s: %w", collection, ErrCollectionNotFound)
+	}
+
+	old, err := getBoltItem(tx, collection, item.ID)
+	if err != nil {
+		return err
+	}
+	if old != nil && old.DocumentID != item.DocumentID {
+		if err := documents.Delete(boltDocumentKey(old.DocumentID, old.ID)); err != nil {
+			return err
+		}
+	}
+
+	data, err := encodeBoltItem(item)
+	if err != nil {
+		return err
+	}
+	if err := items.Put([]byte(item.ID), data); err != nil {
+		return err
+	}
+
+	return documents.Put(boltDocumentKey(item.DocumentID, item.ID), nil)
+}
+
+// putBoltItems stores items and returns the version of each that was
+// stored before, or nil if there was none
+func putBoltItems(tx *bolt.Tx, collection string, items []*Item) (map[string]*Item, error) {
+	previous := make(map[string]*Item, len(items))
+	for _, item := range items {
+		if _, seen := previous[item.ID]; !seen {
+			old, err := getBoltItem(tx, collection, item.ID)
+			if err != nil {
+				return nil, err
+			}
+			previous[item.ID] = old
+		}
+		if err := putBoltItem(tx, collection, item); err != nil {
+			return nil, err
+		}
+	}
+	return previous, nil
+}
+
+// getBoltItem reads an item, returning nil if it is not stored
+func getBoltItem(tx *bolt.Tx, collection, id string) (*Item, error) {
+	items := tx.Bucket(boltItemsBucket).Bucket([]byte(collection))
+	if items == nil {
+		return nil, nil
+	}
+
+	data := items.Get([]byte(id))
+	if data == nil {
+		return nil, nil
+	}
+
+	item, err := decodeBoltItem(data)
+	if err != nil {
+		return nil, err
+	}
+	item.Collection = collection
+
+	return item, nil
+}
+
+// deleteBoltItem removes an item and its document link. It returns the
+// removed item, or nil if it was not stored.
+func deleteBoltItem(tx *bolt.Tx, collection, id string) (*Item, error) {
+	item, err := getBoltItem(tx, collection, id)
+	if err != nil || item == nil {
+		return nil, err
+	}
+
+	if err := tx.Bucket(boltItemsBucket).Bucket([]byte(collection)).Delete([]byte(id)); err != nil {
+		return nil, err
+	}
+	if err := tx.Bucket(boltDocumentsBucket).Bucket([]byte(collection)).Delete(boltDocumentKey(item.DocumentID, id)); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// deleteBoltDocument removes every chunk of a document and returns their IDs
+func deleteBoltDocument(tx *bolt.Tx, collection, documentID string) ([]string, error) {
+	ids := boltDocumentItems(tx, collection, documentID)
+	for _, id := range ids {
+		if _, err := deleteBoltItem(tx, collection, id); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// boltDocumentItems lists the IDs of a document's chunks
+func boltDocumentItems(tx *bolt.Tx, collection, documentID string) []string {
+	documents := tx.Bucket(boltDocumentsBucket).Bucket([]byte(collection))
+	if documents == nil {
+		return nil
+	}
+
+	prefix := boltDocumentKey(documentID, "")
+	var ids []string
+	c := documents.Cursor()
+	for key, _ := c.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = c.Next() {
+		ids = append(ids, string(key[len(prefix):]))
+	}
+
+	return ids
+}
+
+// boltDocumentKey links a chunk to its document. Keys of one document
+// share a prefix, so its chunks can be listed with a cursor.
+func boltDocumentKey(documentID, id string) []byte {
+	return []byte(documentID + "\x00" + id)
+}
+
+// encodeBoltItem gob-encodes an item behind a header holding its expiry in
+// Unix nanoseconds and its retention flags, so that cleanup can find
+// expired items without decoding them
+func encodeBoltItem(item *Item) ([]byte, error) {
+	var data bytes.Buffer
+	data.Write(make([]byte, boltHeaderSize))
+	if err := gob.NewEncoder(&data).Encode(item); err != nil {
+		return nil, fmt.Errorf("failed to encode item %s: %w", item.ID, err)
+	}
+
+	encoded := data.Bytes()
+	if !item.ExpiresAt.IsZero() {
+		binary.BigEndian.PutUint64(encoded, uint64(item.ExpiresAt.UnixNano()))
+	}
+	if item.Retention.Pinned {
+		encoded[8] |= boltFlagPinned
+	}
+	if item.Retention.Policy == RetentionSliding {
+		encoded[8] |= boltFlagSliding
+	}
+
+	return encoded, nil
+}
+
+// decodeBoltItem decodes an item written by encodeBoltItem
+func decodeBoltItem(data []byte) (*Item, error) {
+	if len(data) < boltHeaderSize {
+		return nil, fmt.Errorf("%w: short item", ErrCorruptRecord)
+	}
+
+	item := &Item{}
+	if err := gob.NewDecoder(bytes.NewReader(data[boltHeaderSize:])).Decode(item); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptRecord, err)
+	}
+
+	return item, nil
+}
+
+// boltHeader returns the expiry in Unix nanoseconds, zero for none, and
+// the flags of an encoded item
+func boltHeader(data []byte) (int64, byte) {
+	if len(data) < boltHeaderSize {
+		return 0, 0
+	}
+	return int64(binary.BigEndian.Uint64(data)), data[8]
+}
@@ -0,0 +1,99 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltStoreUndoesWritesTheIndexRefuses(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltStore(&Config{VectorSize: 2, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewBoltStore failed: %v", err)
+	}
+	defer store.Close()
+
+	original := &Item{ID: "a-0", DocumentID: "a", Vector: []float32{1, 0}, Content: "original"}
+	if err := store.Store(ctx, original); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	// An index that refuses every write
+	store.index.Close()
+
+	batch := []*Item{
+		{ID: "a-0", DocumentID: "a", Vector: []float32{0, 1}, Content: "updated"},
+		{ID: "b-0", DocumentID: "b", Vector: []float32{0, 1}, Content: "added"},
+	}
+	if err := store.StoreBatch(ctx, "", batch); !errors.Is(err, ErrStoreClosed) {
+		t.Fatalf("StoreBatch returned %v, want the index's ErrStoreClosed", err)
+	}
+	replacement := []*Item{{ID: "a-1", DocumentID: "a", Vector: []float32{0, 1}, Content: "replaced"}}
+	if err := store.ReplaceDocument(ctx, "", "a", replacement); !errors.Is(err, ErrStoreClosed) {
+		t.Fatalf("ReplaceDocument returned %v, want the index's ErrStoreClosed", err)
+	}
+
+	err = store.db.View(func(tx *bolt.Tx) error {
+		item, err := getBoltItem(tx, store.defaultName, "a-0")
+		if err != nil {
+			return err
+		}
+		if item == nil || item.Content != "original" {
+			t.Errorf("a-0 on disk is %+v, want the original", item)
+		}
+		for _, id := range []string{"a-1", "b-0"} {
+			if item, err := getBoltItem(tx, store.defaultName, id); err != nil || item != nil {
+				t.Errorf("%s is on disk after the index refused it (%v)", id, err)
+			}
+		}
+		if ids := boltDocumentItems(tx, store.defaultName, "a"); len(ids) != 1 || ids[0] != "a-0" {
+			t.Errorf("document a lists %v on disk, want [a-0]", ids)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read the database: %v", err)
+	}
+}
+
+func TestBoltStoreRestoresCollectionsThatFailToDrop(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewBoltStore(&Config{VectorSize: 2, DataDir: dir})
+	if err != nil {
+		t.Fatalf("NewBoltStore failed: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateCollection(ctx, &CollectionConfig{Name: "docs"}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	item := &Item{ID: "a-0", DocumentID: "a", Collection: "docs", Vector: []float32{1, 0}, Content: "kept"}
+	if err := store.Store(ctx, item); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	// A database that refuses every update
+	store.db.Close()
+	store.db, err = bolt.Open(filepath.Join(dir, boltFileName), 0o644, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("failed to reopen the database: %v", err)
+	}
+
+	if err := store.DropCollection(ctx, "docs"); err == nil {
+		t.Fatalf("DropCollection succeeded on a read-only database")
+	}
+
+	results, err := store.Search(ctx, &SearchParams{Collections: []string{"docs"}, Vector: []float32{1, 0}, Limit: 5})
+	if err != nil {
+		t.Fatalf("Search after the failed drop failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Content != "kept" {
+		t.Errorf("Search after the failed drop returned %d results, want the stored item", len(results))
+	}
+}
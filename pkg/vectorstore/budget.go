@@ -0,0 +1,270 @@
+package vectorstore
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Estimated fixed costs of a stored item, on top of its strings and vector
+const (
+	itemOverheadBytes  = 256 // item struct, map entries and slot bookkeeping
+	entryOverheadBytes = 32  // each metadata entry and permission
+)
+
+// Eviction describes a document evicted to keep the store within its
+// memory budget
+type Eviction struct {
+	Collection string
+	DocumentID string
+	Items      int
+	// Bytes is the estimated memory freed
+	Bytes int64
+	Time  time.Time
+}
+
+// MemoryStats reports the estimated memory use of the in-memory store
+type MemoryStats struct {
+	UsedBytes int64 `json:"used_bytes"`
+	// BudgetBytes is zero when the store has no budget
+	BudgetBytes      int64      `json:"budget_bytes"`
+	EvictedDocuments int64      `json:"evicted_documents"`
+	EvictedItems     int64      `json:"evicted_items"`
+	LastEviction     *time.Time `json:"last_eviction,omitempty"`
+}
+
+// documentKey names a document across collections
+type documentKey struct {
+	collection string
+	documentID string
+}
+
+// memoryUsage tracks the estimated memory taken by a store's items and,
+// when the store has a budget, orders its documents by when they were last
+// hit so that the least recently hit can be evicted first. It has its own
+// lock, so searches can record hits while holding only read locks.
+type memoryUsage struct {
+	bytes            atomic.Int64
+	evictedDocuments atomic.Int64
+	evictedItems     atomic.Int64
+
+	lock         sync.Mutex
+	order        *list.List // of documentKey, most recently hit first; nil without a budget
+	entries      map[documentKey]*list.Element
+	lastEviction time.Time
+}
+
+// newMemoryUsage creates the usage tracker of a store. Hits are only
+// recorded when the store has a budget.
+func newMemoryUsage(budget int64) *memoryUsage {
+	u := &memoryUsage{}
+	if budget > 0 {
+		u.order = list.New()
+		u.entries = make(map[documentKey]*list.Element)
+	}
+	return u
+}
+
+// hit marks a document as the most recently hit
+func (u *memoryUsage) hit(collection, documentID string) {
+	if u.order == nil {
+		return
+	}
+
+	key := documentKey{collection: collection, documentID: documentID}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if e, exists := u.entries[key]; exists {
+		u.order.MoveToFront(e)
+		return
+	}
+	u.entries[key] = u.order.PushFront(key)
+}
+
+// forget drops a document that no longer has any chunks
+func (u *memoryUsage) forget(collection, documentID string) {
+	if u.order == nil {
+		return
+	}
+
+	key := documentKey{collection: collection, documentID: documentID}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if e, exists := u.entries[key]; exists {
+		u.order.Remove(e)
+		delete(u.entries, key)
+	}
+}
+
+// forgetCollection drops every document of a collection
+func (u *memoryUsage) forgetCollection(collection string) {
+	if u.order == nil {
+		return
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	for key, e := range u.entries {
+		if key.collection == collection {
+			u.order.Remove(e)
+			delete(u.entries, key)
+		}
+	}
+}
+
+// oldest returns the least recently hit document
+func (u *memoryUsage) oldest() (documentKey, bool) {
+	if u.order == nil {
+		return documentKey{}, false
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	e := u.order.Back()
+	if e == nil {
+		return documentKey{}, false
+	}
+	return e.Value.(documentKey), true
+}
+
+// evicted counts an eviction
+func (u *memoryUsage) evicted(eviction *Eviction) {
+	u.evictedDocuments.Add(1)
+	u.evictedItems.Add(int64(eviction.Items))
+
+	u.lock.Lock()
+	u.lastEviction = eviction.Time
+	u.lock.Unlock()
+}
+
+// stats reports the memory use against a budget
+func (u *memoryUsage) stats(budget int64) *MemoryStats {
+	stats := &MemoryStats{
+		UsedBytes:        u.bytes.Load(),
+		BudgetBytes:      max(budget, 0),
+		EvictedDocuments: u.evictedDocuments.Load(),
+		EvictedItems:     u.evictedItems.Load(),
+	}
+
+	u.lock.Lock()
+	if !u.lastEviction.IsZero() {
+		last := u.lastEviction
+		stats.LastEviction = &last
+	}
+	u.lock.Unlock()
+
+	return stats
+}
+
+// itemBytes estimates the memory taken by a stored item, its quantized
+// vector and its index node. Float vectors in the vector file take none.
+// The caller must hold the collection lock or the shard's lock.
+func (c *memoryCollection) itemBytes(shard *memoryShard, item *Item) int64 {
+	n := itemOverheadBytes + len(item.ID) + len(item.DocumentID) + len(item.Content) + len(item.Title) + 4*len(item.Vector)
+	for key, value := range item.Metadata {
+		n += entryOverheadBytes + len(key) + len(value)
+	}
+	for _, permission := range item.Permissions {
+		n += entryOverheadBytes + len(permission)
+	}
+	if code, exists := shard.codes[item.ID]; exists {
+		// The codes, their norm and the offset of the float vector
+		n += len(code.codes) + 12
+	}
+	if c.index != nil {
+		// Links of layer 0, where every node lives, dominate the node size
+		n += 4 * c.index.maxM0
+	}
+	return int64(n)
+}
+
+// account adds a change in the estimated memory use of the collection.
+// The caller must hold the collection lock.
+func (c *memoryCollection) account(delta int64) {
+	c.bytes += delta
+	c.usage.bytes.Add(delta)
+}
+
+// evictOverBudget evicts the least recently hit documents until the store
+// fits within its memory budget. Evictions are logged like deletes, so a
+// restart does not bring evicted documents back.
+// The caller must hold the store lock but no collection lock.
+func (s *MemoryStore) evictOverBudget() {
+	budget := s.config.MemoryBudget
+	if budget <= 0 || s.usage.bytes.Load() <= budget {
+		return
+	}
+
+	s.evictLock.Lock()
+	defer s.evictLock.Unlock()
+
+	for s.usage.bytes.Load() > budget {
+		key, ok := s.usage.oldest()
+		if !ok {
+			return
+		}
+
+		eviction, err := s.evict(key)
+		if err != nil {
+			// The next write retries once the log accepts records again
+			return
+		}
+		if eviction == nil {
+			continue
+		}
+
+		s.usage.evicted(eviction)
+		if s.config.OnEvict != nil {
+			s.config.OnEvict(eviction)
+		}
+	}
+}
+
+// evict removes every chunk of a document. It returns nil if the document
+// is already gone.
+func (s *MemoryStore) evict(key documentKey) (*Eviction, error) {
+	c, exists := s.collections[key.collection]
+	if !exists {
+		s.usage.forget(key.collection, key.documentID)
+		return nil, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	items := len(c.documents[key.documentID])
+	if items == 0 {
+		s.usage.forget(key.collection, key.documentID)
+		return nil, nil
+	}
+
+	if s.persister != nil {
+		record := &walRecord{Op: walOpDeleteDocument, Collection: key.collection, ID: key.documentID}
+		if err := s.persister.append(record); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := c.documentItems(key.documentID)
+	before := c.bytes
+	c.lockShards()
+	c.removeDocument(key.documentID)
+	c.unlockShards()
+
+	s.changes.publish(Change{Type: ChangeEvict, Collection: key.collection, DocumentID: key.documentID, IDs: ids})
+
+	return &Eviction{
+		Collection: key.collection,
+		DocumentID: key.documentID,
+		Items:      items,
+		Bytes:      before - c.bytes,
+		Time:       time.Now(),
+	}, nil
+}
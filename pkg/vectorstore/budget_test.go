@@ -0,0 +1,102 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	ctx := context.Background()
+	document := func(i int) []*Item {
+		documentID := fmt.Sprintf("doc-%d", i)
+		vector := []float32{0, 1}
+		if i == 0 {
+			vector = []float32{1, 0}
+		}
+		return []*Item{
+			{ID: documentID + "-0", DocumentID: documentID, Vector: vector, Content: "chunk"},
+			{ID: documentID + "-1", DocumentID: documentID, Vector: vector, Content: "chunk"},
+		}
+	}
+
+	// The budget fits exactly three documents
+	unbounded := newTestStore(t, &Config{VectorSize: 2})
+	for i := 0; i < 3; i++ {
+		if err := unbounded.StoreBatch(ctx, "", document(i)); err != nil {
+			t.Fatalf("StoreBatch failed: %v", err)
+		}
+	}
+	stats, err := unbounded.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	budget := stats.Memory.UsedBytes
+
+	var evictions []*Eviction
+	dir := t.TempDir()
+	store, err := NewMemoryStore(&Config{
+		VectorSize:   2,
+		DataDir:      dir,
+		MemoryBudget: budget,
+		OnEvict:      func(eviction *Eviction) { evictions = append(evictions, eviction) },
+	})
+	if err != nil {
+		t.Fatalf("NewMemoryStore failed: %v", err)
+	}
+	defer store.Close()
+	for i := 0; i < 3; i++ {
+		if err := store.StoreBatch(ctx, "", document(i)); err != nil {
+			t.Fatalf("StoreBatch failed: %v", err)
+		}
+	}
+	if len(evictions) != 0 {
+		t.Fatalf("a store within its budget evicted %+v", evictions[0])
+	}
+
+	// Searches and reads hit documents, leaving doc-2 the least recently hit
+	if _, err := store.Search(ctx, &SearchParams{Vector: []float32{1, 0}, Limit: 1}); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "", "doc-1-0"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	if err := store.StoreBatch(ctx, "", document(3)); err != nil {
+		t.Fatalf("StoreBatch failed: %v", err)
+	}
+	if len(evictions) != 1 {
+		t.Fatalf("going over the budget evicted %d documents, want 1", len(evictions))
+	}
+	eviction := evictions[0]
+	if eviction.Collection != DefaultCollection || eviction.DocumentID != "doc-2" || eviction.Items != 2 || eviction.Bytes <= 0 || eviction.Time.IsZero() {
+		t.Errorf("OnEvict received %+v, want the two chunks of doc-2", eviction)
+	}
+
+	stats, err = store.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if memory := stats.Memory; memory.UsedBytes > budget || memory.BudgetBytes != budget || memory.EvictedDocuments != 1 || memory.EvictedItems != 2 || memory.LastEviction == nil {
+		t.Errorf("stats report memory %+v after one eviction within %d bytes", memory, budget)
+	}
+
+	// Evictions are logged, so they survive a restart
+	recovered, err := NewMemoryStore(&Config{VectorSize: 2, DataDir: copyDir(t, dir)})
+	if err != nil {
+		t.Fatalf("recovery failed: %v", err)
+	}
+	defer recovered.Close()
+	for _, s := range []VectorStore{store, recovered} {
+		for i := 0; i < 4; i++ {
+			_, err := s.Get(ctx, "", fmt.Sprintf("doc-%d-0", i))
+			if i == 2 && !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get of the evicted document returned %v, want ErrNotFound", err)
+			}
+			if i != 2 && err != nil {
+				t.Errorf("Get of doc-%d failed: %v", i, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultChangeFeedSize is the number of changes kept for resuming
+// subscribers when Config.ChangeFeedSize is zero
+const defaultChangeFeedSize = 10000
+
+// ChangeType describes what a change did
+type ChangeType string
+
+const (
+	// ChangeStore adds or updates items
+	ChangeStore ChangeType = "store"
+	// ChangeReplace replaces every chunk of a document with the listed items
+	ChangeReplace ChangeType = "replace"
+	// ChangeDelete removes items
+	ChangeDelete ChangeType = "delete"
+	// ChangeExpire removes items whose expiry has passed
+	ChangeExpire ChangeType = "expire"
+	// ChangeEvict removes a document to keep the store within its memory budget
+	ChangeEvict ChangeType = "evict"
+	// ChangeRetention changes the retention and expiry of a document
+	ChangeRetention ChangeType = "retention"
+	// ChangeCreateCollection adds a collection
+	ChangeCreateCollection ChangeType = "create_collection"
+	// ChangeDropCollection removes a collection and everything stored in it
+	ChangeDropCollection ChangeType = "drop_collection"
+)
+
+// Error definitions
+var (
+	ErrChangesLost        = errors.New("changes are no longer retained")
+	ErrChangeFeedDisabled = errors.New("change feed is disabled")
+)
+
+// Change is a mutation of a vector store. Changes are numbered in the
+// order they were applied.
+type Change struct {
+	Sequence   uint64     `json:"sequence"`
+	Type       ChangeType `json:"type"`
+	Collection string     `json:"collection"`
+	// DocumentID is empty for collection changes and for Qdrant expiries:
+	// Qdrant expires points with a filter, so an expiry covers the whole
+	// collection
+	DocumentID string `json:"document_id,omitempty"`
+	// IDs lists the changed items. A document change without IDs covers
+	// every chunk of the document.
+	IDs  []string  `json:"ids,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// changeFeed numbers the changes of a store and keeps the most recent ones
+// so that subscribers can resume from a sequence number. Sequences start
+// from the time the feed was created, so they keep increasing across
+// restarts and a sequence from an earlier run is reported as lost rather
+// than silently matching a different change.
+type changeFeed struct {
+	lock    sync.Mutex
+	size    int
+	changes []Change // the most recent changes, oldest first
+	next    uint64   // sequence of the next change
+	notify  chan struct{}
+	done    chan struct{}
+	closed  bool
+}
+
+// newChangeFeed creates the feed of a store; it returns nil when the feed
+// is disabled
+func newChangeFeed(config *Config) *changeFeed {
+	size := config.ChangeFeedSize
+	if size < 0 {
+		return nil
+	}
+	if size == 0 {
+		size = defaultChangeFeedSize
+	}
+
+	return &changeFeed{
+		size:   size,
+		next:   uint64(time.Now().UnixMicro()),
+		notify: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// publish numbers changes and wakes the streams waiting for them. Writers
+// publish while they still hold the locks that order their writes, so the
+// sequence matches the order the changes were applied in. A nil feed
+// drops the changes.
+func (f *changeFeed) publish(changes ...Change) {
+	if f == nil || len(changes) == 0 {
+		return
+	}
+
+	now := time.Now()
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return
+	}
+
+	for _, change := range changes {
+		change.Sequence = f.next
+		change.Time = now
+		f.next++
+		f.changes = append(f.changes, change)
+	}
+	if len(f.changes) > f.size {
+		f.changes = f.changes[len(f.changes)-f.size:]
+	}
+
+	close(f.notify)
+	f.notify = make(chan struct{})
+}
+
+// subscribe opens a stream of the changes numbered above after. An after
+// of zero streams only changes published from now on.
+func (f *changeFeed) subscribe(after uint64) (*ChangeStream, error) {
+	if f == nil {
+		return nil, ErrChangeFeedDisabled
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return nil, ErrStoreClosed
+	}
+
+	if after == 0 {
+		return &ChangeStream{feed: f, next: f.next}, nil
+	}
+	if after >= f.next || after+1 < f.oldest() {
+		return nil, ErrChangesLost
+	}
+
+	return &ChangeStream{feed: f, next: after + 1}, nil
+}
+
+// close ends every stream
+func (f *changeFeed) close() {
+	if f == nil {
+		return
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.closed {
+		f.closed = true
+		close(f.done)
+	}
+}
+
+// oldest returns the sequence of the oldest retained change.
+// The caller must hold the lock.
+func (f *changeFeed) oldest() uint64 {
+	return f.next - uint64(len(f.changes))
+}
+
+// ChangeStream reads the changes of a store in sequence order. A stream is
+// not safe for concurrent use.
+type ChangeStream struct {
+	feed *changeFeed
+	next uint64
+}
+
+// Next returns the next change, waiting for one to be published. It
+// returns ErrChangesLost once the stream has fallen so far behind that the
+// change was discarded, and ErrStoreClosed when the store closes; a
+// subscriber can resume from the sequence of the last change it handled.
+func (s *ChangeStream) Next(ctx context.Context) (*Change, error) {
+	for {
+		s.feed.lock.Lock()
+		if s.feed.closed {
+			s.feed.lock.Unlock()
+			return nil, ErrStoreClosed
+		}
+		if s.next < s.feed.oldest() {
+			s.feed.lock.Unlock()
+			return nil, ErrChangesLost
+		}
+		if s.next < s.feed.next {
+			change := s.feed.changes[s.next-s.feed.oldest()]
+			change.IDs = slices.Clone(change.IDs)
+			s.feed.lock.Unlock()
+			s.next++
+			return &change, nil
+		}
+		notify := s.feed.notify
+		s.feed.lock.Unlock()
+
+		select {
+		case <-notify:
+		case <-s.feed.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// documentChanges groups items by document into one change per document,
+// in the order the documents first appear
+func documentChanges(changeType ChangeType, collection string, items []*Item) []Change {
+	changes := make([]Change, 0, 1)
+	index := make(map[string]int)
+	for _, item := range items {
+		i, exists := index[item.DocumentID]
+		if !exists {
+			i = len(changes)
+			index[item.DocumentID] = i
+			changes = append(changes, Change{Type: changeType, Collection: collection, DocumentID: item.DocumentID})
+		}
+		changes[i].IDs = append(changes[i].IDs, item.ID)
+	}
+	return changes
+}
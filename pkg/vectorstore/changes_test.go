@@ -0,0 +1,143 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestChangeFeed(t *testing.T) {
+	for name, open := range testStores {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			store := open(t)
+
+			stream, err := store.Subscribe(ctx, 0)
+			if err != nil {
+				t.Fatalf("Subscribe failed: %v", err)
+			}
+
+			items := []*Item{
+				{ID: "a-0", DocumentID: "a", Vector: []float32{1, 0}},
+				{ID: "b-0", DocumentID: "b", Vector: []float32{0, 1}},
+				{ID: "a-1", DocumentID: "a", Vector: []float32{1, 1}},
+			}
+			if err := store.StoreBatch(ctx, "", items); err != nil {
+				t.Fatalf("StoreBatch failed: %v", err)
+			}
+			if err := store.Delete(ctx, "", "a-0"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if err := store.DeleteDocument(ctx, "", "b"); err != nil {
+				t.Fatalf("DeleteDocument failed: %v", err)
+			}
+
+			want := []Change{
+				{Type: ChangeStore, Collection: DefaultCollection, DocumentID: "a", IDs: []string{"a-0", "a-1"}},
+				{Type: ChangeStore, Collection: DefaultCollection, DocumentID: "b", IDs: []string{"b-0"}},
+				{Type: ChangeDelete, Collection: DefaultCollection, DocumentID: "a", IDs: []string{"a-0"}},
+				{Type: ChangeDelete, Collection: DefaultCollection, DocumentID: "b", IDs: []string{"b-0"}},
+			}
+			var changes []*Change
+			for i := range want {
+				change, err := stream.Next(ctx)
+				if err != nil {
+					t.Fatalf("Next failed: %v", err)
+				}
+				changes = append(changes, change)
+				if i > 0 && change.Sequence != changes[i-1].Sequence+1 {
+					t.Errorf("change %d has sequence %d after %d", i, change.Sequence, changes[i-1].Sequence)
+				}
+				// Qdrant deletes documents with a filter and cannot list their chunks
+				if name == "qdrant" && change.Type == ChangeDelete && change.DocumentID == "b" {
+					change.IDs = []string{"b-0"}
+				}
+				if change.Type != want[i].Type || change.Collection != want[i].Collection ||
+					change.DocumentID != want[i].DocumentID || !slices.Equal(change.IDs, want[i].IDs) {
+					t.Errorf("change %d is %+v, want %+v", i, change, want[i])
+				}
+			}
+
+			// A subscriber resumes after the last change it handled
+			resumed, err := store.Subscribe(ctx, changes[1].Sequence)
+			if err != nil {
+				t.Fatalf("Subscribe failed: %v", err)
+			}
+			change, err := resumed.Next(ctx)
+			if err != nil {
+				t.Fatalf("Next failed: %v", err)
+			}
+			if change.Sequence != changes[2].Sequence || change.Type != ChangeDelete {
+				t.Errorf("the resumed stream starts at %+v, want change %d", change, changes[2].Sequence)
+			}
+		})
+	}
+}
+
+func TestChangeFeedLosesOldChanges(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	store := newTestStore(t, &Config{VectorSize: 2, ChangeFeedSize: 2})
+
+	stream, err := store.Subscribe(ctx, 0)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if err := store.Store(ctx, &Item{ID: id, DocumentID: id, Vector: []float32{1, 0}}); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+	}
+
+	// The stream fell behind the two changes the feed keeps
+	if _, err := stream.Next(ctx); !errors.Is(err, ErrChangesLost) {
+		t.Fatalf("Next of a stream behind the feed returned %v, want ErrChangesLost", err)
+	}
+
+	latest, err := store.Subscribe(ctx, 0)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := store.Store(ctx, &Item{ID: "e", DocumentID: "e", Vector: []float32{1, 0}}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	last, err := latest.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+
+	// Only the two most recent changes can be resumed from
+	tests := []struct {
+		after uint64
+		err   error
+	}{
+		{last.Sequence - 3, ErrChangesLost},
+		{last.Sequence - 2, nil},
+		{last.Sequence, nil},
+		{last.Sequence + 1, ErrChangesLost},
+	}
+	for _, tt := range tests {
+		if _, err := store.Subscribe(ctx, tt.after); !errors.Is(err, tt.err) {
+			t.Errorf("Subscribe after %d of %d returned %v, want %v", tt.after, last.Sequence, err, tt.err)
+		}
+	}
+
+	// Closing the store ends the streams
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := latest.Next(ctx)
+		waiting <- err
+	}()
+	store.Close()
+	if err := <-waiting; !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Next on a closed store returned %v, want ErrStoreClosed", err)
+	}
+
+	disabled := newTestStore(t, &Config{VectorSize: 2, ChangeFeedSize: -1})
+	if _, err := disabled.Subscribe(ctx, 0); !errors.Is(err, ErrChangeFeedDisabled) {
+		t.Errorf("Subscribe with the feed disabled returned %v, want ErrChangeFeedDisabled", err)
+	}
+}
@@ -0,0 +1,106 @@
+package vectorstore
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// DefaultCollection is used when no collection is named
+const DefaultCollection = "documents"
+
+// CollectionConfig describes a named collection
+type CollectionConfig struct {
+	Name string
+	// VectorSize is the dimension of the collection's vectors; zero accepts any
+	VectorSize int
+	// Distance defaults to cosine
+	Distance Distance
+	// TTL is applied to items stored without an expiry; zero keeps them forever
+	TTL time.Duration
+	// Sliding refreshes the TTL of items whenever they are read
+	Sliding bool
+	// Projection is the PCA projection the store fitted on the
+	// collection's vectors, kept so that it is persisted with the
+	// collection. It cannot be supplied when creating a collection.
+	Projection *Projection
+}
+
+// CollectionStats reports the size of a collection
+type CollectionStats struct {
+	Items     int `json:"items"`
+	Documents int `json:"documents"`
+	// VectorBytes is the memory taken by vector components
+	VectorBytes int64 `json:"vector_bytes"`
+	// MemoryBytes estimates all memory taken by the items; in-memory backend only
+	MemoryBytes  int64              `json:"memory_bytes,omitempty"`
+	Quantization *QuantizationStats `json:"quantization,omitempty"`
+	Reduction    *ReductionStats    `json:"reduction,omitempty"`
+}
+
+// CollectionInfo describes a collection and its contents
+type CollectionInfo struct {
+	CollectionConfig
+	CollectionStats
+}
+
+// collectionNamePattern restricts names to ones that are safe in URLs and
+// as Qdrant collection names
+var collectionNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// validate checks the collection name, fills in the default distance and
+// drops any projection, which only the store fits
+func (c *CollectionConfig) validate() error {
+	if !collectionNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("%w: bad name %q", ErrInvalidCollection, c.Name)
+	}
+	if c.VectorSize < 0 {
+		return fmt.Errorf("%w: bad vector size %d for %s", ErrInvalidCollection, c.VectorSize, c.Name)
+	}
+	if c.TTL < 0 {
+		return fmt.Errorf("%w: bad TTL %s for %s", ErrInvalidCollection, c.TTL, c.Name)
+	}
+
+	distance, err := ParseDistance(string(c.Distance))
+	if err != nil {
+		return fmt.Errorf("%w: %v for %s", ErrInvalidCollection, err, c.Name)
+	}
+	c.Distance = distance
+	c.Projection = nil
+
+	return nil
+}
+
+// checkVector verifies that a vector fits the collection
+func (c *CollectionConfig) checkVector(vector []float32) error {
+	if c.VectorSize > 0 && len(vector) != c.VectorSize {
+		return fmt.Errorf("%w: collection %s expects %d dimensions, got %d", ErrVectorSize, c.Name, c.VectorSize, len(vector))
+	}
+	return nil
+}
+
+// check verifies that an item fits the collection
+func (c *CollectionConfig) check(item *Item) error {
+	if err := c.checkVector(item.Vector); err != nil {
+		return err
+	}
+	return item.Retention.validate()
+}
+
+// prepare returns the item as it is kept in the collection: tagged with the
+// collection name, with its retention resolved and, if it has no expiry,
+// an expiry set by its retention
+func (c *CollectionConfig) prepare(item *Item, now time.Time) *Item {
+	retention := c.retention(item.Retention)
+	if item.Collection == c.Name && item.Retention == retention && (!item.ExpiresAt.IsZero() || retention.expiry(now).IsZero()) {
+		return item
+	}
+
+	prepared := *item
+	prepared.Collection = c.Name
+	prepared.Retention = retention
+	if prepared.ExpiresAt.IsZero() {
+		prepared.ExpiresAt = retention.expiry(now)
+	}
+	return &prepared
+}
@@ -0,0 +1,102 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestCollections(t *testing.T) {
+	for name, open := range testStores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := open(t)
+
+			for _, collection := range []string{"alpha", "beta"} {
+				if err := store.CreateCollection(ctx, &CollectionConfig{Name: collection, VectorSize: 2}); err != nil {
+					t.Fatalf("CreateCollection failed: %v", err)
+				}
+			}
+			for _, config := range []*CollectionConfig{{Name: "alpha", VectorSize: 2}, {Name: DefaultCollection}} {
+				if err := store.CreateCollection(ctx, config); !errors.Is(err, ErrCollectionExists) {
+					t.Errorf("creating %s twice returned %v, want ErrCollectionExists", config.Name, err)
+				}
+			}
+			if err := store.CreateCollection(ctx, &CollectionConfig{Name: "bad/name"}); !errors.Is(err, ErrInvalidCollection) {
+				t.Errorf("CreateCollection with a bad name returned %v, want ErrInvalidCollection", err)
+			}
+
+			infos, err := store.ListCollections(ctx)
+			if err != nil {
+				t.Fatalf("ListCollections failed: %v", err)
+			}
+			var names []string
+			for _, info := range infos {
+				names = append(names, info.Name)
+			}
+			if want := []string{"alpha", "beta", DefaultCollection}; !slices.Equal(names, want) {
+				t.Errorf("ListCollections returned %v, want %v", names, want)
+			}
+
+			// The same ID may live in several collections
+			items := []*Item{
+				{ID: "x", DocumentID: "x", Collection: "alpha", Vector: []float32{1, 0}},
+				{ID: "y", DocumentID: "y", Collection: "alpha", Vector: []float32{0.6, 0.8}},
+				{ID: "x", DocumentID: "x", Collection: "beta", Vector: []float32{0.8, 0.6}},
+				{ID: "z", DocumentID: "z", Collection: "beta", Vector: []float32{0, 1}},
+				{ID: "w", DocumentID: "w", Vector: []float32{1, 0}},
+			}
+			for _, item := range items {
+				if err := store.Store(ctx, item); err != nil {
+					t.Fatalf("Store failed: %v", err)
+				}
+			}
+
+			// A search over several collections merges their results by score
+			results, err := store.Search(ctx, &SearchParams{Collections: []string{"alpha", "beta"}, Vector: []float32{1, 0}, Limit: 3})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			var got []string
+			for _, result := range results {
+				got = append(got, result.Collection+"/"+result.ID)
+			}
+			if want := []string{"alpha/x", "beta/x", "alpha/y"}; !slices.Equal(got, want) {
+				t.Errorf("search over alpha and beta returned %v, want %v", got, want)
+			}
+
+			results, err = store.Search(ctx, &SearchParams{Vector: []float32{1, 0}, Limit: 10})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if ids := resultIDs(results); !slices.Equal(ids, []string{"w"}) {
+				t.Errorf("search of the default collection returned %v, want [w]", ids)
+			}
+
+			// Unknown collections are reported, not skipped
+			if _, err := store.Search(ctx, &SearchParams{Collections: []string{"alpha", "missing"}, Vector: []float32{1, 0}, Limit: 3}); !errors.Is(err, ErrCollectionNotFound) {
+				t.Errorf("Search of a missing collection returned %v, want ErrCollectionNotFound", err)
+			}
+			if err := store.Store(ctx, &Item{ID: "v", Collection: "missing", Vector: []float32{1, 0}}); !errors.Is(err, ErrCollectionNotFound) {
+				t.Errorf("Store into a missing collection returned %v, want ErrCollectionNotFound", err)
+			}
+			if _, err := store.Get(ctx, "missing", "x"); !errors.Is(err, ErrCollectionNotFound) {
+				t.Errorf("Get from a missing collection returned %v, want ErrCollectionNotFound", err)
+			}
+
+			if err := store.DropCollection(ctx, "alpha"); err != nil {
+				t.Fatalf("DropCollection failed: %v", err)
+			}
+			if err := store.DropCollection(ctx, "alpha"); !errors.Is(err, ErrCollectionNotFound) {
+				t.Errorf("dropping alpha twice returned %v, want ErrCollectionNotFound", err)
+			}
+			if err := store.DropCollection(ctx, DefaultCollection); !errors.Is(err, ErrInvalidCollection) {
+				t.Errorf("dropping the default collection returned %v, want ErrInvalidCollection", err)
+			}
+			if item, err := store.Get(ctx, "beta", "x"); err != nil || item.Collection != "beta" {
+				t.Errorf("Get of beta/x after dropping alpha returned %+v, %v", item, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package vectorstore
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Distance is the similarity metric of a collection. Every metric is
+// exposed as a score where higher means more similar.
+type Distance string
+
+const (
+	// DistanceCosine scores by the cosine of the angle between vectors.
+	// Collections using it store unit-length vectors.
+	DistanceCosine Distance = "cosine"
+	// DistanceDot scores by the dot product
+	DistanceDot Distance = "dot"
+	// DistanceEuclid scores by the negated Euclidean (L2) distance
+	DistanceEuclid Distance = "euclid"
+)
+
+// normTolerance is how far from 1 the squared norm of a unit vector may be
+const normTolerance = 1e-6
+
+// ParseDistance resolves a metric name. It accepts the canonical names as
+// well as "l2" and "euclidean"; an empty name selects cosine.
+func ParseDistance(name string) (Distance, error) {
+	switch strings.ToLower(name) {
+	case "", string(DistanceCosine):
+		return DistanceCosine, nil
+	case string(DistanceDot):
+		return DistanceDot, nil
+	case string(DistanceEuclid), "euclidean", "l2":
+		return DistanceEuclid, nil
+	default:
+		return "", fmt.Errorf("unknown distance %q", name)
+	}
+}
+
+// similarity scores two vectors under the metric; higher is more similar
+func (d Distance) similarity(a, b []float32) float64 {
+	switch d {
+	case DistanceDot:
+		return dotProduct(a, b)
+	case DistanceEuclid:
+		return -euclideanDistance(a, b)
+	default:
+		return cosineSimilarity(a, b)
+	}
+}
+
+// query prepares a query vector for score
+func (d Distance) query(vector []float32) []float32 {
+	if d == DistanceCosine {
+		return normalize(vector)
+	}
+	return vector
+}
+
+// score compares a query prepared by query with a stored vector. Stored
+// vectors of cosine collections are unit length, so cosine reduces to a
+// dot product, clamped against float32 rounding.
+func (d Distance) score(query, stored []float32) float64 {
+	if d == DistanceCosine {
+		return math.Max(-1, math.Min(1, dotProduct(query, stored)))
+	}
+	return d.similarity(query, stored)
+}
+
+// cosineSimilarity calculates cosine similarity between two vectors
+func cosineSimilarity(a, b []float32) float64 {
+	// Ensure vectors have the same length
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	// Handle zero vectors
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// dotProduct calculates the dot product of two vectors
+func dotProduct(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// euclideanDistance calculates the Euclidean distance between two vectors
+func euclideanDistance(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return math.Inf(1)
+	}
+
+	var sum float64
+	for i := range a {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// squaredNorm returns the squared length of a vector
+func squaredNorm(vector []float32) float64 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	return sum
+}
+
+// isNormalized reports whether a vector has unit length or is zero
+func isNormalized(vector []float32) bool {
+	norm := squaredNorm(vector)
+	return norm == 0 || math.Abs(norm-1) <= normTolerance
+}
+
+// normalize returns a unit-length copy of a vector. Zero vectors are
+// returned unchanged.
+func normalize(vector []float32) []float32 {
+	norm := math.Sqrt(squaredNorm(vector))
+	if norm == 0 {
+		return vector
+	}
+
+	normalized := make([]float32, len(vector))
+	for i, v := range vector {
+		normalized[i] = float32(float64(v) / norm)
+	}
+	return normalized
+}
@@ -0,0 +1,104 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestParseDistance(t *testing.T) {
+	tests := map[string]Distance{
+		"":          DistanceCosine,
+		"Cosine":    DistanceCosine,
+		"dot":       DistanceDot,
+		"euclid":    DistanceEuclid,
+		"euclidean": DistanceEuclid,
+		"L2":        DistanceEuclid,
+	}
+	for name, want := range tests {
+		if got, err := ParseDistance(name); err != nil || got != want {
+			t.Errorf("ParseDistance(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseDistance("manhattan"); err == nil {
+		t.Errorf("ParseDistance of an unknown metric succeeded")
+	}
+}
+
+func TestDistanceRanking(t *testing.T) {
+	// Each metric ranks the same vectors differently for the query [2, 0]
+	items := []*Item{
+		{ID: "same", Vector: []float32{1, 0}},
+		{ID: "long", Vector: []float32{3, 3}},
+		{ID: "short", Vector: []float32{0.5, 0.1}},
+		{ID: "opposite", Vector: []float32{-1, 0}},
+	}
+	tests := []struct {
+		distance Distance
+		want     []string
+		best     float64
+	}{
+		{DistanceCosine, []string{"same", "short", "long", "opposite"}, 1},
+		{DistanceDot, []string{"long", "same", "short", "opposite"}, 6},
+		{DistanceEuclid, []string{"same", "short", "opposite", "long"}, -1},
+	}
+
+	stores := map[string]func(t *testing.T) VectorStore{
+		"hnsw": func(t *testing.T) VectorStore {
+			return newTestStore(t, &Config{VectorSize: 2, Index: IndexConfig{Type: IndexHNSW}})
+		},
+	}
+	for name, open := range testStores {
+		stores[name] = open
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := open(t)
+
+			for _, tt := range tests {
+				collection := string(tt.distance)
+				if err := store.CreateCollection(ctx, &CollectionConfig{Name: collection, VectorSize: 2, Distance: tt.distance}); err != nil {
+					t.Fatalf("CreateCollection failed: %v", err)
+				}
+				for _, item := range items {
+					item := *item
+					item.DocumentID = item.ID
+					item.Collection = collection
+					if err := store.Store(ctx, &item); err != nil {
+						t.Fatalf("Store failed: %v", err)
+					}
+				}
+
+				results, err := store.Search(ctx, &SearchParams{Collections: []string{collection}, Vector: []float32{2, 0}, Limit: 10})
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				if ids := resultIDs(results); !slices.Equal(ids, tt.want) {
+					t.Errorf("%s ranked %v, want %v", tt.distance, ids, tt.want)
+					continue
+				}
+				if math.Abs(results[0].Score-tt.best) > 1e-5 {
+					t.Errorf("%s scored the best match %v, want %v", tt.distance, results[0].Score, tt.best)
+				}
+			}
+
+			infos, err := store.ListCollections(ctx)
+			if err != nil {
+				t.Fatalf("ListCollections failed: %v", err)
+			}
+			for _, info := range infos {
+				if info.Name != DefaultCollection && info.Distance != Distance(info.Name) {
+					t.Errorf("collection %s reports distance %q", info.Name, info.Distance)
+				}
+			}
+		})
+	}
+
+	if err := (&CollectionConfig{Name: "bad", Distance: "manhattan"}).validate(); !errors.Is(err, ErrInvalidCollection) {
+		t.Errorf("validating an unknown distance returned %v, want ErrInvalidCollection", err)
+	}
+}
@@ -0,0 +1,150 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// importBatchSize is the number of records stored at once by Import
+const importBatchSize = 256
+
+// Error definitions
+var (
+	ErrInvalidRecord = errors.New("invalid export record")
+)
+
+// ExportRecord is one line of a JSON Lines export
+type ExportRecord struct {
+	ID          string            `json:"id"`
+	DocumentID  string            `json:"document_id"`
+	Vector      []float32         `json:"vector"`
+	Content     string            `json:"content,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Permissions []string          `json:"permissions,omitempty"`
+	Retention   *Retention        `json:"retention,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	Model       *EmbeddingModel   `json:"model,omitempty"`
+}
+
+// Export writes every live item of a collection to w as JSON Lines and
+// returns the number of items written
+func Export(ctx context.Context, store VectorStore, collection string, w io.Writer) (int, error) {
+	encoder := json.NewEncoder(w)
+	scroller := NewScroller(store, ScrollParams{Collection: collection})
+
+	count := 0
+	for scroller.Next(ctx) {
+		if err := encoder.Encode(recordFromItem(scroller.Item())); err != nil {
+			return count, fmt.Errorf("failed to write export: %w", err)
+		}
+		count++
+	}
+	if err := scroller.Err(); err != nil {
+		return count, err
+	}
+
+	return count, nil
+}
+
+// Import stores the items of a JSON Lines export in a collection and
+// returns the number of items stored. Items are written in batches, so a
+// failure leaves the batches before it in place.
+func Import(ctx context.Context, store VectorStore, collection string, r io.Reader) (int, error) {
+	decoder := json.NewDecoder(r)
+
+	count := 0
+	batch := make([]*Item, 0, importBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := store.StoreBatch(ctx, collection, batch); err != nil {
+			return fmt.Errorf("failed to import records %d-%d: %w", count+1, count+len(batch), err)
+		}
+		count += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		var record ExportRecord
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("%w %d: %v", ErrInvalidRecord, count+len(batch)+1, err)
+		}
+		if record.ID == "" {
+			return count, fmt.Errorf("%w %d: missing id", ErrInvalidRecord, count+len(batch)+1)
+		}
+
+		batch = append(batch, itemFromRecord(collection, &record))
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return count, err
+	}
+
+	return count, nil
+}
+
+// recordFromItem converts an item into its export record
+func recordFromItem(item *Item) *ExportRecord {
+	record := &ExportRecord{
+		ID:          item.ID,
+		DocumentID:  item.DocumentID,
+		Vector:      item.Vector,
+		Content:     item.Content,
+		Title:       item.Title,
+		Metadata:    item.Metadata,
+		Permissions: item.Permissions,
+	}
+	if item.Retention != (Retention{}) {
+		retention := item.Retention
+		record.Retention = &retention
+	}
+	if !item.ExpiresAt.IsZero() {
+		expiresAt := item.ExpiresAt
+		record.ExpiresAt = &expiresAt
+	}
+	if !item.Model.IsZero() {
+		model := item.Model
+		record.Model = &model
+	}
+	return record
+}
+
+// itemFromRecord converts an export record into an item of collection
+func itemFromRecord(collection string, record *ExportRecord) *Item {
+	item := &Item{
+		ID:          record.ID,
+		Collection:  collection,
+		Vector:      record.Vector,
+		DocumentID:  record.DocumentID,
+		Content:     record.Content,
+		Title:       record.Title,
+		Metadata:    record.Metadata,
+		Permissions: record.Permissions,
+	}
+	if record.Retention != nil {
+		item.Retention = *record.Retention
+	}
+	if record.ExpiresAt != nil {
+		item.ExpiresAt = *record.ExpiresAt
+	}
+	if record.Model != nil {
+		item.Model = *record.Model
+	}
+	return item
+}
@@ -0,0 +1,156 @@
+package vectorstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	source, err := NewMemoryStore(&Config{VectorSize: 2, TTL: time.Hour, DataDir: dir})
+	if err != nil {
+		t.Fatalf("NewMemoryStore failed: %v", err)
+	}
+	defer source.Close()
+
+	// Half of the items are covered by a snapshot, the rest only by the log
+	expiresAt := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	for i := 0; i < 6; i++ {
+		if i == 3 {
+			if err := source.Snapshot(); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+		}
+		item := &Item{
+			ID:          fmt.Sprintf("item-%d", i),
+			DocumentID:  fmt.Sprintf("doc-%d", i/2),
+			Vector:      []float32{float32(i % 2), float32(1 - i%2)},
+			Content:     fmt.Sprintf("content %d", i),
+			Title:       "title",
+			Metadata:    map[string]string{"index": fmt.Sprint(i)},
+			Permissions: []string{"alice"},
+			Model:       EmbeddingModel{ID: "hashing", Version: 2},
+		}
+		switch i {
+		case 1:
+			item.Retention = Retention{Policy: RetentionForever}
+		case 4:
+			item.Retention = Retention{Policy: RetentionTTL, Pinned: true}
+			item.ExpiresAt = expiresAt
+		}
+		if err := source.Store(ctx, item); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+	}
+	if err := source.Delete(ctx, "", "item-5"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// Export what the store recovers from its snapshot and log
+	recovered, err := NewMemoryStore(&Config{VectorSize: 2, TTL: time.Hour, DataDir: copyDir(t, dir)})
+	if err != nil {
+		t.Fatalf("recovery failed: %v", err)
+	}
+	defer recovered.Close()
+
+	var export bytes.Buffer
+	count, err := Export(ctx, recovered, "", &export)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	want := exportRecords(t, export.String())
+	if count != 5 || len(want) != 5 {
+		t.Fatalf("Export wrote %d items in %d records, want 5", count, len(want))
+	}
+	if record := want[4]; record.ExpiresAt == nil || !record.ExpiresAt.Equal(expiresAt) || !record.Retention.Pinned {
+		t.Errorf("item-4 exported as %+v, want pinned and expiring at %s", record, expiresAt)
+	}
+
+	for name, open := range testStores {
+		t.Run(name, func(t *testing.T) {
+			target := open(t)
+			if err := target.CreateCollection(ctx, &CollectionConfig{Name: "imported", VectorSize: 2}); err != nil {
+				t.Fatalf("CreateCollection failed: %v", err)
+			}
+			count, err := Import(ctx, target, "imported", strings.NewReader(export.String()))
+			if err != nil || count != 5 {
+				t.Fatalf("Import stored %d items: %v", count, err)
+			}
+
+			var again bytes.Buffer
+			if _, err := Export(ctx, target, "imported", &again); err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+			got, want := exportRecords(t, again.String()), exportRecords(t, export.String())
+			if name == "qdrant" {
+				// Qdrant keeps expiry times to the second
+				for _, records := range [][]ExportRecord{got, want} {
+					for _, record := range records {
+						if record.ExpiresAt != nil {
+							*record.ExpiresAt = record.ExpiresAt.Truncate(time.Second)
+						}
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("the round trip changed the items:\n got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+
+	// Imports are persisted like any other write
+	importDir := t.TempDir()
+	target, err := NewMemoryStore(&Config{VectorSize: 2, DataDir: importDir})
+	if err != nil {
+		t.Fatalf("NewMemoryStore failed: %v", err)
+	}
+	if _, err := Import(ctx, target, "", strings.NewReader(export.String())); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	target.Close()
+	reopened, err := NewMemoryStore(&Config{VectorSize: 2, DataDir: importDir})
+	if err != nil {
+		t.Fatalf("reopening the imported store failed: %v", err)
+	}
+	defer reopened.Close()
+	var reexport bytes.Buffer
+	if _, err := Export(ctx, reopened, "", &reexport); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if got := exportRecords(t, reexport.String()); !reflect.DeepEqual(got, want) {
+		t.Errorf("the reopened import holds %+v, want %+v", got, want)
+	}
+
+	for _, input := range []string{`{"id": "x", "vector": [1, 0]}` + "\n{bad json", `{"vector": [1, 0]}`} {
+		if _, err := Import(ctx, reopened, "", strings.NewReader(input)); !errors.Is(err, ErrInvalidRecord) {
+			t.Errorf("Import of %q returned %v, want ErrInvalidRecord", input, err)
+		}
+	}
+}
+
+// exportRecords decodes a JSON Lines export, sorted by item ID
+func exportRecords(t *testing.T, export string) []ExportRecord {
+	t.Helper()
+
+	var records []ExportRecord
+	decoder := json.NewDecoder(strings.NewReader(export))
+	for decoder.More() {
+		var record ExportRecord
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("failed to decode export: %v", err)
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+
+	return records
+}
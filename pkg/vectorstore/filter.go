@@ -0,0 +1,291 @@
+package vectorstore
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Filter is a boolean expression over item metadata. Each filter node is
+// exactly one of:
+//
+//	{"and": [...]}                        all sub-filters match
+//	{"or": [...]}                         at least one sub-filter matches
+//	{"not": {...}}                        the sub-filter does not match
+//	{"field": "source", "eq": "confluence"}
+//	{"field": "contentType", "in": ["application/pdf", "text/plain"]}
+//	{"field": "size", "range": {"gte": 1024, "lt": 1048576}}
+//
+// Range bounds compare numerically when both the bound and the metadata
+// value are numbers, and chronologically when both are dates (RFC 3339 or
+// YYYY-MM-DD). A field missing from the metadata never matches.
+type Filter struct {
+	And    []*Filter `json:"and,omitempty"`
+	Or     []*Filter `json:"or,omitempty"`
+	Not    *Filter   `json:"not,omitempty"`
+	Field  string    `json:"field,omitempty"`
+	Equals *string   `json:"eq,omitempty"`
+	In     []string  `json:"in,omitempty"`
+	Range  *Range    `json:"range,omitempty"`
+}
+
+// Range restricts a field to an interval; unset bounds are open
+type Range struct {
+	GT  *Bound `json:"gt,omitempty"`
+	GTE *Bound `json:"gte,omitempty"`
+	LT  *Bound `json:"lt,omitempty"`
+	LTE *Bound `json:"lte,omitempty"`
+}
+
+// Bound is a range limit given as a JSON number or string
+type Bound string
+
+// Error definitions
+var (
+	ErrInvalidFilter = errors.New("invalid filter")
+)
+
+// dateLayouts are the accepted formats for date values
+var dateLayouts = []string{time.RFC3339, "2006-01-02"}
+
+// UnmarshalJSON accepts both numbers and strings
+func (b *Bound) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*b = Bound(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("range bound must be a number or string: %w", err)
+	}
+	*b = Bound(n)
+
+	return nil
+}
+
+// Validate checks that every node of the filter is well formed
+func (f *Filter) Validate() error {
+	if f == nil {
+		return fmt.Errorf("%w: empty filter", ErrInvalidFilter)
+	}
+
+	kinds := 0
+	if f.And != nil {
+		kinds++
+	}
+	if f.Or != nil {
+		kinds++
+	}
+	if f.Not != nil {
+		kinds++
+	}
+	if f.Equals != nil {
+		kinds++
+	}
+	if f.In != nil {
+		kinds++
+	}
+	if f.Range != nil {
+		kinds++
+	}
+	if kinds != 1 {
+		return fmt.Errorf("%w: each filter needs exactly one of and, or, not, eq, in, range", ErrInvalidFilter)
+	}
+	if (f.And != nil && len(f.And) == 0) || (f.Or != nil && len(f.Or) == 0) {
+		return fmt.Errorf("%w: and/or need at least one sub-filter", ErrInvalidFilter)
+	}
+
+	isCondition := f.Equals != nil || f.In != nil || f.Range != nil
+	if isCondition && f.Field == "" {
+		return fmt.Errorf("%w: condition without field", ErrInvalidFilter)
+	}
+	if !isCondition && f.Field != "" {
+		return fmt.Errorf("%w: field %q set on a logical filter", ErrInvalidFilter, f.Field)
+	}
+
+	for _, sub := range f.And {
+		if err := sub.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, sub := range f.Or {
+		if err := sub.Validate(); err != nil {
+			return err
+		}
+	}
+	if f.Not != nil {
+		if err := f.Not.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if f.Range != nil {
+		return f.Range.validate(f.Field)
+	}
+
+	return nil
+}
+
+// Matches evaluates the filter against item metadata.
+// A nil filter matches everything.
+func (f *Filter) Matches(metadata map[string]string) bool {
+	if f == nil {
+		return true
+	}
+
+	switch {
+	case f.And != nil:
+		for _, sub := range f.And {
+			if !sub.Matches(metadata) {
+				return false
+			}
+		}
+		return true
+
+	case f.Or != nil:
+		for _, sub := range f.Or {
+			if sub.Matches(metadata) {
+				return true
+			}
+		}
+		return false
+
+	case f.Not != nil:
+		return !f.Not.Matches(metadata)
+	}
+
+	value, exists := metadata[f.Field]
+	if !exists {
+		return false
+	}
+
+	switch {
+	case f.Equals != nil:
+		return value == *f.Equals
+
+	case f.In != nil:
+		for _, candidate := range f.In {
+			if value == candidate {
+				return true
+			}
+		}
+		return false
+
+	case f.Range != nil:
+		return f.Range.contains(value)
+	}
+
+	return false
+}
+
+// validate checks that the bounds are set and of a single comparable kind
+func (r *Range) validate(field string) error {
+	bounds := r.bounds()
+	if len(bounds) == 0 {
+		return fmt.Errorf("%w: range on %q has no bounds", ErrInvalidFilter, field)
+	}
+
+	numeric := 0
+	for _, b := range bounds {
+		if _, ok := b.number(); ok {
+			numeric++
+		} else if _, ok := b.date(); !ok {
+			return fmt.Errorf("%w: range bound %q on %q is neither a number nor a date", ErrInvalidFilter, string(*b), field)
+		}
+	}
+	if numeric != 0 && numeric != len(bounds) {
+		return fmt.Errorf("%w: range on %q mixes numbers and dates", ErrInvalidFilter, field)
+	}
+
+	return nil
+}
+
+// contains reports whether a metadata value lies within the range
+func (r *Range) contains(value string) bool {
+	if r.isNumeric() {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+		return r.check(func(b *Bound) int {
+			limit, _ := b.number()
+			return cmp.Compare(n, limit)
+		})
+	}
+
+	t, ok := parseDate(value)
+	if !ok {
+		return false
+	}
+	return r.check(func(b *Bound) int {
+		limit, _ := b.date()
+		return t.Compare(limit)
+	})
+}
+
+// check applies every bound using compare, which orders the value against a bound
+func (r *Range) check(compare func(*Bound) int) bool {
+	if r.GT != nil && compare(r.GT) <= 0 {
+		return false
+	}
+	if r.GTE != nil && compare(r.GTE) < 0 {
+		return false
+	}
+	if r.LT != nil && compare(r.LT) >= 0 {
+		return false
+	}
+	if r.LTE != nil && compare(r.LTE) > 0 {
+		return false
+	}
+	return true
+}
+
+// isNumeric reports whether the bounds are numbers rather than dates
+func (r *Range) isNumeric() bool {
+	for _, b := range r.bounds() {
+		if _, ok := b.number(); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// bounds returns the bounds that are set
+func (r *Range) bounds() []*Bound {
+	var bounds []*Bound
+	for _, b := range []*Bound{r.GT, r.GTE, r.LT, r.LTE} {
+		if b != nil {
+			bounds = append(bounds, b)
+		}
+	}
+	return bounds
+}
+
+// number parses the bound as a number
+func (b *Bound) number() (float64, bool) {
+	n, err := strconv.ParseFloat(string(*b), 64)
+	return n, err == nil
+}
+
+// date parses the bound as a date
+func (b *Bound) date() (time.Time, bool) {
+	return parseDate(string(*b))
+}
+
+// parseDate parses a value in one of the accepted date layouts
+func parseDate(value string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
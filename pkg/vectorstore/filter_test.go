@@ -0,0 +1,153 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// filterItems are the documents the filter tests search
+var filterItems = []*Item{
+	{ID: "d1", DocumentID: "d1", Vector: []float32{1, 0}, Metadata: map[string]string{
+		"source": "confluence", "contentType": "application/pdf", "size": "512", "created": "2024-01-15",
+	}},
+	{ID: "d2", DocumentID: "d2", Vector: []float32{1, 0}, Metadata: map[string]string{
+		"source": "upload", "contentType": "text/plain", "size": "2048", "created": "2024-03-01T10:00:00Z",
+	}},
+	{ID: "d3", DocumentID: "d3", Vector: []float32{1, 0}, Metadata: map[string]string{
+		"source": "confluence", "contentType": "text/plain", "size": "1048576", "created": "2023-12-31",
+	}},
+	{ID: "d4", DocumentID: "d4", Vector: []float32{1, 0}, Metadata: map[string]string{
+		"source": "upload",
+	}},
+}
+
+// filterTests pair filters with the documents they select
+var filterTests = []struct {
+	name   string
+	filter string
+	want   []string
+}{
+	{"equals", `{"field": "source", "eq": "confluence"}`, []string{"d1", "d3"}},
+	{"in", `{"field": "contentType", "in": ["text/plain", "text/html"]}`, []string{"d2", "d3"}},
+	{"numeric range", `{"field": "size", "range": {"gte": 1024, "lt": 1048576}}`, []string{"d2"}},
+	{"date range", `{"field": "created", "range": {"gte": "2024-01-01"}}`, []string{"d1", "d2"}},
+	{"and", `{"and": [{"field": "source", "eq": "upload"}, {"field": "size", "range": {"gt": 1000}}]}`, []string{"d2"}},
+	{"or", `{"or": [{"field": "contentType", "eq": "application/pdf"}, {"field": "size", "range": {"gte": "1048576"}}]}`, []string{"d1", "d3"}},
+	{"not", `{"not": {"field": "size", "range": {"gte": 1024}}}`, []string{"d1", "d4"}},
+	{"missing field", `{"field": "owner", "eq": "alice"}`, nil},
+}
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		valid  bool
+	}{
+		{"equals", `{"field": "source", "eq": "upload"}`, true},
+		{"nested", `{"and": [{"not": {"field": "a", "in": ["x"]}}, {"or": [{"field": "b", "range": {"lt": 3}}]}]}`, true},
+		{"date range", `{"field": "created", "range": {"gte": "2024-01-01", "lt": "2024-02-01T00:00:00Z"}}`, true},
+		{"empty", `{}`, false},
+		{"two kinds", `{"field": "source", "eq": "upload", "in": ["upload"]}`, false},
+		{"empty and", `{"and": []}`, false},
+		{"condition without field", `{"eq": "upload"}`, false},
+		{"field on logical filter", `{"field": "source", "not": {"field": "source", "eq": "upload"}}`, false},
+		{"range without bounds", `{"field": "size", "range": {}}`, false},
+		{"mixed bounds", `{"field": "size", "range": {"gte": 1, "lt": "2024-01-01"}}`, false},
+		{"bad bound", `{"field": "size", "range": {"gte": "large"}}`, false},
+		{"invalid sub-filter", `{"or": [{"field": "source", "eq": "upload"}, {"eq": "upload"}]}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := parseFilter(t, tt.filter)
+			err := filter.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Validate failed: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("Validate returned %v, want ErrInvalidFilter", err)
+			}
+		})
+	}
+
+	var filter *Filter
+	if err := filter.Validate(); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("Validate of a nil filter returned %v, want ErrInvalidFilter", err)
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	for _, tt := range filterTests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := parseFilter(t, tt.filter)
+			var got []string
+			for _, item := range filterItems {
+				if filter.Matches(item.Metadata) {
+					got = append(got, item.ID)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("filter matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var filter *Filter
+	if !filter.Matches(nil) {
+		t.Errorf("a nil filter must match everything")
+	}
+	if parseFilter(t, `{"field": "size", "range": {"gt": 1}}`).Matches(map[string]string{"size": "large"}) {
+		t.Errorf("a numeric range matched a value that is not a number")
+	}
+}
+
+func TestFilteredSearch(t *testing.T) {
+	stores := map[string]func(t *testing.T) VectorStore{
+		"memory": func(t *testing.T) VectorStore { return newTestStore(t, &Config{VectorSize: 2}) },
+		"bolt":   func(t *testing.T) VectorStore { return newTestStore(t, &Config{Backend: BackendBolt, VectorSize: 2}) },
+		"qdrant": func(t *testing.T) VectorStore {
+			_, store := newFakeQdrant(t, &Config{VectorSize: 2})
+			return store
+		},
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := open(t)
+			for _, item := range filterItems {
+				if err := store.Store(ctx, item); err != nil {
+					t.Fatalf("Store failed: %v", err)
+				}
+			}
+
+			for _, tt := range filterTests {
+				results, err := store.Search(ctx, &SearchParams{
+					Vector: []float32{1, 0},
+					Limit:  10,
+					Filter: parseFilter(t, tt.filter),
+				})
+				if err != nil {
+					t.Fatalf("Search with the %s filter failed: %v", tt.name, err)
+				}
+				if got := slices.Sorted(slices.Values(resultIDs(results))); !slices.Equal(got, tt.want) {
+					t.Errorf("search with the %s filter found %v, want %v", tt.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// parseFilter decodes a filter from JSON
+func parseFilter(t *testing.T, data string) *Filter {
+	t.Helper()
+
+	var filter Filter
+	if err := json.Unmarshal([]byte(data), &filter); err != nil {
+		t.Fatalf("failed to decode filter %s: %v", data, err)
+	}
+	return &filter
+}
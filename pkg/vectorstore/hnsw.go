@@ -0,0 +1,447 @@
+package vectorstore
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// IndexType selects how the in-memory store finds nearest neighbours
+type IndexType string
+
+const (
+	// IndexHNSW uses an approximate HNSW graph index
+	IndexHNSW IndexType = "hnsw"
+	// IndexFlat scores every stored vector (exact brute force)
+	IndexFlat IndexType = "flat"
+)
+
+// Default HNSW parameters
+const (
+	defaultHNSWM              = 16
+	defaultHNSWEfConstruction = 200
+	defaultHNSWEfSearch       = 64
+)
+
+// IndexConfig configures the search index of the in-memory store
+type IndexConfig struct {
+	Type IndexType
+	// M is the number of links each node keeps per layer (2*M on layer 0)
+	M int
+	// EfConstruction is the candidate list size used while inserting
+	EfConstruction int
+	// EfSearch is the candidate list size used while searching
+	EfSearch int
+}
+
+// hnswIndex is a Hierarchical Navigable Small World graph
+// (Malkov & Yashunin, 2016) over the items of a collection.
+// Searches hold every shard's read lock and writers at least one shard's
+// write lock, so a write never overlaps a search or another write.
+type hnswIndex struct {
+	m              int
+	maxM0          int
+	efConstruction int
+	efSearch       int
+	levelMult      float64
+	nodes          []*hnswNode
+	ids            map[string]uint32
+	entryPoint     int64
+	maxLevel       int
+	deleted        int
+	metric         Distance
+	rng            *rand.Rand
+}
+
+// hnswNode is a single vector in the graph
+type hnswNode struct {
+	item      *Item
+	level     int
+	neighbors [][]uint32
+	deleted   bool
+}
+
+// candidate is a node together with its distance to the query
+type candidate struct {
+	node uint32
+	dist float64
+}
+
+// newHNSWIndex creates an empty index over vectors compared with metric,
+// filling in defaults for unset parameters
+func newHNSWIndex(config IndexConfig, metric Distance) *hnswIndex {
+	m := config.M
+	if m <= 1 {
+		m = defaultHNSWM
+	}
+	efConstruction := config.EfConstruction
+	if efConstruction <= 0 {
+		efConstruction = defaultHNSWEfConstruction
+	}
+	efSearch := config.EfSearch
+	if efSearch <= 0 {
+		efSearch = defaultHNSWEfSearch
+	}
+
+	return &hnswIndex{
+		m:              m,
+		maxM0:          2 * m,
+		efConstruction: efConstruction,
+		efSearch:       efSearch,
+		levelMult:      1 / math.Log(float64(m)),
+		ids:            make(map[string]uint32),
+		entryPoint:     -1,
+		metric:         metric,
+		rng:            rand.New(rand.NewSource(1)),
+	}
+}
+
+// insert adds an item to the graph, replacing any node with the same ID
+func (h *hnswIndex) insert(item *Item) {
+	h.remove(item.ID)
+
+	level := h.randomLevel()
+	id := uint32(len(h.nodes))
+	node := &hnswNode{
+		item:      item,
+		level:     level,
+		neighbors: make([][]uint32, level+1),
+	}
+	h.nodes = append(h.nodes, node)
+	h.ids[item.ID] = id
+
+	if h.entryPoint < 0 {
+		h.entryPoint = int64(id)
+		h.maxLevel = level
+		return
+	}
+
+	// Descend greedily through the layers above the new node
+	entry := h.candidate(item.Vector, uint32(h.entryPoint))
+	for l := h.maxLevel; l > level; l-- {
+		entry = h.closest(h.searchLayer(item.Vector, []candidate{entry}, 1, l))
+	}
+
+	// Link the node on every layer it belongs to
+	entries := []candidate{entry}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		found := h.searchLayer(item.Vector, entries, h.efConstruction, l)
+		neighbors := h.selectNeighbors(found, h.maxNeighbors(l))
+
+		node.neighbors[l] = make([]uint32, 0, len(neighbors))
+		for _, n := range neighbors {
+			node.neighbors[l] = append(node.neighbors[l], n.node)
+			h.link(n.node, id, l)
+		}
+
+		entries = found
+	}
+
+	if level > h.maxLevel {
+		h.maxLevel = level
+		h.entryPoint = int64(id)
+	}
+}
+
+// remove marks the node for an item as deleted.
+// Deleted nodes are still traversed but never returned; the graph is
+// rebuilt once more than half of its nodes are deleted.
+func (h *hnswIndex) remove(id string) {
+	nodeID, exists := h.ids[id]
+	if !exists {
+		return
+	}
+
+	h.nodes[nodeID].deleted = true
+	delete(h.ids, id)
+	h.deleted++
+
+	if len(h.ids) == 0 {
+		h.reset()
+		return
+	}
+
+	if h.deleted > len(h.nodes)/2 {
+		h.rebuild()
+		return
+	}
+
+	if int64(nodeID) == h.entryPoint {
+		h.chooseEntryPoint()
+	}
+}
+
+// update swaps the item of a node for a copy with the same vector
+func (h *hnswIndex) update(item *Item) {
+	if nodeID, exists := h.ids[item.ID]; exists {
+		h.nodes[nodeID].item = item
+	}
+}
+
+// search returns up to k live items accepted by the filter and inside the
+// window, best first. The query must be prepared for the metric.
+func (h *hnswIndex) search(query []float32, k int, accept func(*Item) bool, w window) []scoredItem {
+	if h.entryPoint < 0 || k <= 0 {
+		return nil
+	}
+
+	entry := h.candidate(query, uint32(h.entryPoint))
+	for l := h.maxLevel; l > 0; l-- {
+		entry = h.closest(h.searchLayer(query, []candidate{entry}, 1, l))
+	}
+
+	found := h.searchLayer(query, []candidate{entry}, max(h.efSearch, k), 0)
+
+	scored := make([]scoredItem, 0, len(found))
+	for _, c := range found {
+		node := h.nodes[c.node]
+		if node.deleted || !accept(node.item) {
+			continue
+		}
+		s := scoredItem{item: node.item, score: -c.dist}
+		if w.admits(s) {
+			scored = append(scored, s)
+		}
+	}
+
+	sortScored(scored)
+	if len(scored) > k {
+		scored = scored[:k]
+	}
+
+	return scored
+}
+
+// len returns the number of live nodes
+func (h *hnswIndex) len() int {
+	return len(h.ids)
+}
+
+// searchLayer finds the ef nearest nodes to the query on one layer
+func (h *hnswIndex) searchLayer(query []float32, entries []candidate, ef int, level int) []candidate {
+	visited := make(map[uint32]struct{}, ef*4)
+	candidates := &minQueue{}
+	results := &maxQueue{}
+
+	for _, e := range entries {
+		visited[e.node] = struct{}{}
+		heap.Push(candidates, e)
+		heap.Push(results, e)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		current := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && current.dist > (*results)[0].dist {
+			break
+		}
+
+		node := h.nodes[current.node]
+		if level >= len(node.neighbors) {
+			continue
+		}
+
+		for _, n := range node.neighbors[level] {
+			if _, seen := visited[n]; seen {
+				continue
+			}
+			visited[n] = struct{}{}
+
+			c := h.candidate(query, n)
+			if results.Len() < ef || c.dist < (*results)[0].dist {
+				heap.Push(candidates, c)
+				heap.Push(results, c)
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	return []candidate(*results)
+}
+
+// selectNeighbors picks at most limit live candidates using the heuristic
+// from the HNSW paper: a candidate is kept only if it is closer to the
+// base vector than to any neighbour already selected, which spreads links
+// across clusters. Remaining slots are filled with the closest rejects.
+func (h *hnswIndex) selectNeighbors(candidates []candidate, limit int) []candidate {
+	live := make([]candidate, 0, len(candidates))
+	for _, c := range candidates {
+		if !h.nodes[c.node].deleted {
+			live = append(live, c)
+		}
+	}
+
+	sort.Slice(live, func(i, j int) bool {
+		return live[i].dist < live[j].dist
+	})
+	if len(live) <= limit {
+		return live
+	}
+
+	selected := make([]candidate, 0, limit)
+	rejected := make([]candidate, 0, len(live))
+	for _, c := range live {
+		if len(selected) >= limit {
+			break
+		}
+
+		keep := true
+		for _, s := range selected {
+			if h.distance(h.nodes[c.node].item.Vector, h.nodes[s.node].item.Vector) < c.dist {
+				keep = false
+				break
+			}
+		}
+
+		if keep {
+			selected = append(selected, c)
+		} else {
+			rejected = append(rejected, c)
+		}
+	}
+
+	for _, c := range rejected {
+		if len(selected) >= limit {
+			break
+		}
+		selected = append(selected, c)
+	}
+
+	return selected
+}
+
+// link adds a connection from one node to another, pruning if needed
+func (h *hnswIndex) link(from, to uint32, level int) {
+	node := h.nodes[from]
+	node.neighbors[level] = append(node.neighbors[level], to)
+
+	limit := h.maxNeighbors(level)
+	if len(node.neighbors[level]) <= limit {
+		return
+	}
+
+	// Keep only the closest neighbours. Running the full selection
+	// heuristic here would cost limit² distance computations per link.
+	candidates := make([]candidate, 0, len(node.neighbors[level]))
+	for _, n := range node.neighbors[level] {
+		if !h.nodes[n].deleted {
+			candidates = append(candidates, candidate{node: n, dist: h.distance(node.item.Vector, h.nodes[n].item.Vector)})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].dist < candidates[j].dist
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	node.neighbors[level] = node.neighbors[level][:0]
+	for _, c := range candidates {
+		node.neighbors[level] = append(node.neighbors[level], c.node)
+	}
+}
+
+// chooseEntryPoint picks the live node with the highest level
+func (h *hnswIndex) chooseEntryPoint() {
+	h.entryPoint = -1
+	h.maxLevel = 0
+
+	for _, id := range h.ids {
+		if h.entryPoint < 0 || h.nodes[id].level > h.maxLevel {
+			h.entryPoint = int64(id)
+			h.maxLevel = h.nodes[id].level
+		}
+	}
+}
+
+// rebuild recreates the graph from live nodes only
+func (h *hnswIndex) rebuild() {
+	live := make([]*Item, 0, len(h.ids))
+	for _, node := range h.nodes {
+		if !node.deleted {
+			live = append(live, node.item)
+		}
+	}
+
+	h.reset()
+	for _, item := range live {
+		h.insert(item)
+	}
+}
+
+// reset drops every node
+func (h *hnswIndex) reset() {
+	h.nodes = nil
+	h.ids = make(map[string]uint32)
+	h.entryPoint = -1
+	h.maxLevel = 0
+	h.deleted = 0
+}
+
+// randomLevel draws the top layer for a new node
+func (h *hnswIndex) randomLevel() int {
+	return int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMult))
+}
+
+// maxNeighbors returns the link limit for a layer
+func (h *hnswIndex) maxNeighbors(level int) int {
+	if level == 0 {
+		return h.maxM0
+	}
+	return h.m
+}
+
+// candidate computes the distance between the query and a node
+func (h *hnswIndex) candidate(query []float32, node uint32) candidate {
+	return candidate{node: node, dist: h.distance(query, h.nodes[node].item.Vector)}
+}
+
+// closest returns the nearest of a set of candidates
+func (h *hnswIndex) closest(candidates []candidate) candidate {
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.dist < best.dist {
+			best = c
+		}
+	}
+	return best
+}
+
+// distance negates similarity so that lower is closer. Negation keeps
+// scores bit-identical to those of an exact scan.
+func (h *hnswIndex) distance(a, b []float32) float64 {
+	return -h.metric.score(a, b)
+}
+
+// minQueue is a priority queue that pops the closest candidate first
+type minQueue []candidate
+
+func (q minQueue) Len() int            { return len(q) }
+func (q minQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q minQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *minQueue) Push(x interface{}) { *q = append(*q, x.(candidate)) }
+func (q *minQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// maxQueue is a priority queue that pops the furthest candidate first
+type maxQueue []candidate
+
+func (q maxQueue) Len() int            { return len(q) }
+func (q maxQueue) Less(i, j int) bool  { return q[i].dist > q[j].dist }
+func (q maxQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *maxQueue) Push(x interface{}) { *q = append(*q, x.(candidate)) }
+func (q *maxQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,113 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestHNSWRecall(t *testing.T) {
+	ctx := context.Background()
+	const dimensions, count, queries, k = 32, 2000, 50, 10
+	exact := newTestStore(t, &Config{Backend: BackendMemory, VectorSize: dimensions})
+	graph := newTestStore(t, &Config{Backend: BackendMemory, VectorSize: dimensions, Index: IndexConfig{Type: IndexHNSW}})
+
+	vectors := randomVectors(1, count, dimensions)
+	for i, vector := range vectors {
+		item := &Item{ID: fmt.Sprintf("item-%d", i), DocumentID: fmt.Sprintf("doc-%d", i), Vector: vector}
+		for _, store := range []VectorStore{exact, graph} {
+			if err := store.Store(ctx, item); err != nil {
+				t.Fatalf("Store failed: %v", err)
+			}
+		}
+	}
+
+	// Removed items leave the graph
+	for i := 0; i < count; i += 10 {
+		for _, store := range []VectorStore{exact, graph} {
+			if err := store.Delete(ctx, "", fmt.Sprintf("item-%d", i)); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+		}
+	}
+
+	hits := 0
+	for _, query := range randomVectors(2, queries, dimensions) {
+		want, err := exact.Search(ctx, &SearchParams{Vector: query, Limit: k})
+		if err != nil {
+			t.Fatalf("exact Search failed: %v", err)
+		}
+		got, err := graph.Search(ctx, &SearchParams{Vector: query, Limit: k})
+		if err != nil {
+			t.Fatalf("HNSW Search failed: %v", err)
+		}
+		if len(got) != k {
+			t.Fatalf("HNSW search returned %d results, want %d", len(got), k)
+		}
+
+		found := make(map[string]bool)
+		for _, result := range want {
+			found[result.ID] = true
+		}
+		for _, result := range got {
+			var i int
+			fmt.Sscanf(result.ID, "item-%d", &i)
+			if i%10 == 0 {
+				t.Errorf("HNSW search returned deleted item %s", result.ID)
+			}
+			if found[result.ID] {
+				hits++
+			}
+		}
+	}
+
+	if recall := float64(hits) / (queries * k); recall < 0.95 {
+		t.Errorf("HNSW recall@%d is %.3f, want at least 0.95", k, recall)
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	ctx := context.Background()
+	const dimensions, count = 128, 10000
+	vectors := randomVectors(1, count, dimensions)
+	queries := randomVectors(2, 100, dimensions)
+
+	for _, index := range []IndexType{IndexFlat, IndexHNSW} {
+		b.Run(string(index), func(b *testing.B) {
+			store, err := New(&Config{Backend: BackendMemory, VectorSize: dimensions, Index: IndexConfig{Type: index}})
+			if err != nil {
+				b.Fatalf("New failed: %v", err)
+			}
+			defer store.Close()
+
+			for i, vector := range vectors {
+				item := &Item{ID: fmt.Sprintf("item-%d", i), DocumentID: fmt.Sprintf("doc-%d", i), Vector: vector}
+				if err := store.Store(ctx, item); err != nil {
+					b.Fatalf("Store failed: %v", err)
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.Search(ctx, &SearchParams{Vector: queries[i%len(queries)], Limit: 10}); err != nil {
+					b.Fatalf("Search failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// randomVectors makes count reproducible vectors with normally distributed
+// components
+func randomVectors(seed int64, count, dimensions int) [][]float32 {
+	random := rand.New(rand.NewSource(seed))
+	vectors := make([][]float32, count)
+	for i := range vectors {
+		vectors[i] = make([]float32, dimensions)
+		for j := range vectors[i] {
+			vectors[i][j] = float32(random.NormFloat64())
+		}
+	}
+	return vectors
+}
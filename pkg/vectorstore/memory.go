@@ -0,0 +1,1593 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// scoredItem represents an item with its similarity score
+type scoredItem struct {
+	item  *Item
+	score float64
+}
+
+// MemoryStore keeps vectors in process memory.
+// When Config.DataDir is set, every write is appended to a write-ahead log
+// and the items are periodically snapshotted, so they survive a restart.
+//
+// The store lock guards the set of collections; item writes and searches
+// share it. Each collection orders its writers with its own lock and splits
+// its items into shards, which exact search scans in parallel.
+//
+// With Config.MemoryBudget set, writes that take the store over its budget
+// evict the least recently hit documents.
+//
+// With Config.Reduction set, collections keep reduced vectors. A fitted
+// PCA projection is persisted in the snapshots; if the store stops before
+// the next one, the projection is fitted again from the logged vectors.
+//
+// Writers publish their changes while holding the locks that order them;
+// items recovered from disk are not published.
+type MemoryStore struct {
+	config       *Config
+	collections  map[string]*memoryCollection
+	defaultName  string
+	persister    *persister  // nil when not persistent
+	vectors      *vectorFile // float vectors of quantized items; nil without quantization
+	usage        *memoryUsage
+	changes      *changeFeed // nil when disabled
+	lock         sync.RWMutex
+	snapshotLock sync.Mutex
+	evictLock    sync.Mutex
+	closeChan    chan struct{}
+	closed       bool
+}
+
+// memoryCollection holds the items and indexes of one collection.
+// Writers hold lock for the whole write, so they reach the log and the
+// shards in the same order, and write-lock only the shards they change.
+// Holding lock is enough to read items, as only writers change them.
+// Searches read-lock every shard and never wait for the log.
+type memoryCollection struct {
+	config    CollectionConfig
+	lock      sync.Mutex
+	shards    []*memoryShard
+	size      int                            // number of items in all shards
+	bytes     int64                          // estimated memory taken by the items
+	documents map[string]map[string]struct{} // document ID -> chunk IDs
+	index     *hnswIndex                     // nil when using exact search
+	quantizer *scalarQuantizer               // nil when vectors are kept as float32
+	vectors   *vectorFile                    // shared with the store
+	reducer   *vectorReducer                 // nil when vectors are kept at full size
+	usage     *memoryUsage                   // shared with the store
+	changes   *changeFeed                    // shared with the store
+}
+
+// NewMemoryStore creates a new in-memory store, recovering its items from
+// Config.DataDir if persistence is enabled
+func NewMemoryStore(config *Config) (*MemoryStore, error) {
+	// Quantized vectors are scanned rather than indexed, as the graph
+	// needs the float vectors that quantization moves to disk
+	if config.Quantization.Enabled && config.Index.Type == IndexHNSW {
+		return nil, fmt.Errorf("quantization requires the %s index", IndexFlat)
+	}
+	if err := config.Reduction.validate(); err != nil {
+		return nil, err
+	}
+
+	defaults := defaultCollectionConfig(config)
+	if err := defaults.validate(); err != nil {
+		return nil, err
+	}
+
+	store := &MemoryStore{
+		config:      config,
+		collections: make(map[string]*memoryCollection),
+		defaultName: defaults.Name,
+		usage:       newMemoryUsage(config.MemoryBudget),
+		changes:     newChangeFeed(config),
+		closeChan:   make(chan struct{}),
+	}
+	if config.Quantization.Enabled {
+		vectors, err := openVectorFile(config.DataDir)
+		if err != nil {
+			return nil, err
+		}
+		store.vectors = vectors
+	}
+	store.collections[defaults.Name] = store.newCollection(*defaults)
+
+	if config.DataDir != "" {
+		persister, state, err := openPersister(config.DataDir, config.SyncWrites)
+		if err != nil {
+			if store.vectors != nil {
+				store.vectors.close()
+			}
+			return nil, fmt.Errorf("failed to recover vector store: %w", err)
+		}
+		store.persister = persister
+
+		store.restore(state)
+		store.evictOverBudget()
+
+		go store.snapshotRoutine()
+	}
+
+	// Start cleanup goroutine for expired items
+	go store.cleanupRoutine()
+
+	return store, nil
+}
+
+// CreateCollection adds a new collection
+func (s *MemoryStore) CreateCollection(ctx context.Context, config *CollectionConfig) error {
+	collectionConfig := *config
+	if err := collectionConfig.validate(); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	if _, exists := s.collections[collectionConfig.Name]; exists {
+		return fmt.Errorf("collection %s: %w", collectionConfig.Name, ErrCollectionExists)
+	}
+
+	if s.persister != nil {
+		record := &walRecord{Op: walOpCreateCollection, Collection: collectionConfig.Name, Config: &collectionConfig}
+		if err := s.persister.append(record); err != nil {
+			return err
+		}
+	}
+
+	s.collections[collectionConfig.Name] = s.newCollection(collectionConfig)
+	s.changes.publish(Change{Type: ChangeCreateCollection, Collection: collectionConfig.Name})
+
+	return nil
+}
+
+// ListCollections describes every collection, sorted by name
+func (s *MemoryStore) ListCollections(ctx context.Context) ([]*CollectionInfo, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+
+	infos := make([]*CollectionInfo, 0, len(s.collections))
+	for _, c := range s.collections {
+		infos = append(infos, &CollectionInfo{
+			CollectionConfig: c.config,
+			CollectionStats:  *c.stats(),
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	return infos, nil
+}
+
+// DropCollection removes a collection and everything stored in it.
+// The default collection cannot be dropped.
+func (s *MemoryStore) DropCollection(ctx context.Context, name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	if name == s.defaultName {
+		return fmt.Errorf("%w: cannot drop the default collection %s", ErrInvalidCollection, name)
+	}
+	if _, exists := s.collections[name]; !exists {
+		return fmt.Errorf("collection %s: %w", name, ErrCollectionNotFound)
+	}
+
+	if s.persister != nil {
+		if err := s.persister.append(&walRecord{Op: walOpDropCollection, Collection: name}); err != nil {
+			return err
+		}
+	}
+
+	s.usage.forgetCollection(name)
+	s.usage.bytes.Add(-s.collections[name].bytes)
+	s.collections[name].forgetVectors()
+	delete(s.collections, name)
+	s.changes.publish(Change{Type: ChangeDropCollection, Collection: name})
+
+	return nil
+}
+
+// Store adds or updates a vector in the item's collection
+func (s *MemoryStore) Store(ctx context.Context, item *Item) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	c, err := s.collection(item.Collection)
+	if err != nil {
+		return err
+	}
+	if err := c.config.check(item); err != nil {
+		return err
+	}
+	item = c.config.prepare(item, time.Now())
+
+	defer s.evictOverBudget()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if s.persister != nil {
+		if err := s.persister.append(&walRecord{Op: walOpPut, Collection: c.config.Name, Item: item}); err != nil {
+			return err
+		}
+	}
+
+	// Add the item
+	shard := c.shard(item.ID)
+	shard.lock.Lock()
+	c.put(item)
+	shard.lock.Unlock()
+
+	c.calibrateIfReady()
+	s.changes.publish(documentChanges(ChangeStore, c.config.Name, []*Item{item})...)
+
+	return nil
+}
+
+// StoreBatch adds or updates several vectors in one collection. Every item
+// is checked before any is stored, and the batch is logged as one record.
+func (s *MemoryStore) StoreBatch(ctx context.Context, collection string, items []*Item) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	prepared := make([]*Item, len(items))
+	for i, item := range items {
+		if err := c.config.check(item); err != nil {
+			return fmt.Errorf("item %s: %w", item.ID, err)
+		}
+		prepared[i] = c.config.prepare(item, now)
+	}
+
+	if len(prepared) == 0 {
+		return nil
+	}
+
+	defer s.evictOverBudget()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if s.persister != nil {
+		record := &walRecord{Op: walOpPutBatch, Collection: c.config.Name, Items: prepared}
+		if err := s.persister.append(record); err != nil {
+			return err
+		}
+	}
+
+	// Searches see the whole batch or none of it
+	c.lockShards()
+	for _, item := range prepared {
+		c.put(item)
+	}
+	c.unlockShards()
+
+	c.calibrateIfReady()
+	s.changes.publish(documentChanges(ChangeStore, c.config.Name, prepared)...)
+
+	return nil
+}
+
+// Get retrieves a vector by ID
+func (s *MemoryStore) Get(ctx context.Context, collection, id string) (*Item, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	shard := c.shard(id)
+	shard.lock.RLock()
+	item, exists := shard.items[id]
+	if exists {
+		item = c.decode(shard, item)
+	}
+	shard.lock.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("item with ID %s: %w", id, ErrNotFound)
+	}
+
+	// Check expiration
+	if item.isExpired(now) {
+		return nil, fmt.Errorf("item with ID %s has expired", id)
+	}
+
+	s.usage.hit(c.config.Name, item.DocumentID)
+	if item.needsRefresh(now) {
+		c.touch([]string{item.DocumentID}, now)
+		item = item.withRetention(item.Retention, item.Retention.expiry(now))
+	}
+
+	return item, nil
+}
+
+// peek returns a stored item, live or expired, without recording a hit or
+// refreshing it. It returns nil if the item is not stored.
+func (s *MemoryStore) peek(collection, id string) *Item {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return nil
+	}
+
+	shard := c.shard(id)
+	shard.lock.RLock()
+	defer shard.lock.RUnlock()
+
+	item, exists := shard.items[id]
+	if !exists {
+		return nil
+	}
+	return c.decode(shard, item)
+}
+
+// projection returns the PCA projection fitted on a collection, or nil if
+// there is none
+func (s *MemoryStore) projection(collection string) *Projection {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.reducer.fitted()
+}
+
+// adoptProjection gives an empty collection the projection fitted on it
+// before, if it still fits the store's reduction settings
+func (s *MemoryStore) adoptProjection(collection string, projection *Projection) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.lockShards()
+	defer c.unlockShards()
+
+	if c.size == 0 {
+		c.reducer.adopt(projection)
+	}
+}
+
+// Delete removes a vector from the store
+func (s *MemoryStore) Delete(ctx context.Context, collection, id string) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	shard := c.shard(id)
+	item, exists := shard.items[id]
+	if !exists {
+		return nil
+	}
+
+	if s.persister != nil {
+		if err := s.persister.append(&walRecord{Op: walOpDelete, Collection: c.config.Name, ID: id}); err != nil {
+			return err
+		}
+	}
+
+	shard.lock.Lock()
+	c.remove(id)
+	shard.lock.Unlock()
+
+	s.changes.publish(Change{Type: ChangeDelete, Collection: c.config.Name, DocumentID: item.DocumentID, IDs: []string{id}})
+
+	return nil
+}
+
+// GetDocument returns the live chunks stored for a document
+func (s *MemoryStore) GetDocument(ctx context.Context, collection, documentID string) ([]*Item, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	items := make([]*Item, 0, len(c.documents[documentID]))
+	for id := range c.documents[documentID] {
+		shard := c.shard(id)
+		if item := c.refresh(id, now); !item.isExpired(now) {
+			items = append(items, c.decode(shard, item))
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+
+	if len(items) > 0 {
+		s.usage.hit(c.config.Name, documentID)
+	}
+
+	return items, nil
+}
+
+// DeleteDocument removes every chunk of a document
+func (s *MemoryStore) DeleteDocument(ctx context.Context, collection, documentID string) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.documents[documentID]) == 0 {
+		return nil
+	}
+
+	if s.persister != nil {
+		record := &walRecord{Op: walOpDeleteDocument, Collection: c.config.Name, ID: documentID}
+		if err := s.persister.append(record); err != nil {
+			return err
+		}
+	}
+
+	ids := c.documentItems(documentID)
+	c.lockShards()
+	c.removeDocument(documentID)
+	c.unlockShards()
+
+	s.changes.publish(Change{Type: ChangeDelete, Collection: c.config.Name, DocumentID: documentID, IDs: ids})
+
+	return nil
+}
+
+// ReplaceDocument atomically replaces all chunks of a document with items.
+// Searches see either the old or the new chunks, never a mix.
+func (s *MemoryStore) ReplaceDocument(ctx context.Context, collection, documentID string, items []*Item) error {
+	if err := checkDocumentItems(documentID, items); err != nil {
+		return err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	prepared := make([]*Item, len(items))
+	for i, item := range items {
+		if err := c.config.check(item); err != nil {
+			return err
+		}
+		prepared[i] = c.config.prepare(item, now)
+	}
+
+	defer s.evictOverBudget()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if s.persister != nil {
+		record := &walRecord{Op: walOpReplaceDocument, Collection: c.config.Name, ID: documentID, Items: prepared}
+		if err := s.persister.append(record); err != nil {
+			return err
+		}
+	}
+
+	c.lockShards()
+	c.removeDocument(documentID)
+	for _, item := range prepared {
+		c.put(item)
+	}
+	c.unlockShards()
+
+	c.calibrateIfReady()
+
+	change := Change{Type: ChangeReplace, Collection: c.config.Name, DocumentID: documentID}
+	for _, item := range prepared {
+		change.IDs = append(change.IDs, item.ID)
+	}
+	s.changes.publish(change)
+
+	return nil
+}
+
+// SetDocumentRetention changes the retention and expiry of every chunk of
+// a document, including chunks that have expired but not yet been removed
+func (s *MemoryStore) SetDocumentRetention(ctx context.Context, collection, documentID string, retention Retention, expiresAt time.Time) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+
+	retention, expiresAt, err = c.config.documentExpiry(retention, expiresAt, time.Now())
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.documents[documentID]) == 0 {
+		return fmt.Errorf("document %s: %w", documentID, ErrNotFound)
+	}
+
+	if s.persister != nil {
+		record := &walRecord{Op: walOpSetRetention, Collection: c.config.Name, ID: documentID, Retention: &retention, ExpiresAt: expiresAt}
+		if err := s.persister.append(record); err != nil {
+			return err
+		}
+	}
+
+	c.lockShards()
+	for id := range c.documents[documentID] {
+		shard := c.shard(id)
+		c.replace(shard, shard.items[id].withRetention(retention, expiresAt))
+	}
+	c.unlockShards()
+
+	s.changes.publish(Change{Type: ChangeRetention, Collection: c.config.Name, DocumentID: documentID, IDs: c.documentItems(documentID)})
+
+	return nil
+}
+
+// Scroll returns a page of live items ordered by ID. The offset is the ID
+// of the last item of the previous page. Unlike Get, it neither records
+// hits nor refreshes sliding expiries.
+func (s *MemoryStore) Scroll(ctx context.Context, params *ScrollParams) (*ScrollPage, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+
+	c, err := s.collection(params.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	c.rLockShards()
+	defer c.rUnlockShards()
+
+	now := time.Now()
+	var ids []string
+	matches := func(id string, item *Item) bool {
+		return id > params.Offset && !item.isExpired(now) && params.Filter.Matches(item.Metadata)
+	}
+	if params.DocumentID != "" {
+		for id := range c.documents[params.DocumentID] {
+			if matches(id, c.shard(id).items[id]) {
+				ids = append(ids, id)
+			}
+		}
+	} else {
+		for _, shard := range c.shards {
+			for id, item := range shard.items {
+				if matches(id, item) {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	sort.Strings(ids)
+
+	page := &ScrollPage{}
+	if limit := params.scrollLimit(); len(ids) > limit {
+		ids = ids[:limit]
+		page.NextOffset = ids[limit-1]
+	}
+
+	page.Items = make([]*Item, len(ids))
+	for i, id := range ids {
+		shard := c.shard(id)
+		page.Items[i] = c.decode(shard, shard.items[id])
+	}
+
+	return page, nil
+}
+
+// Search performs vector similarity search over one or more collections.
+// With an HNSW index the search is approximate; it falls back to an exact
+// scan when the index cannot produce enough results that pass the filters.
+// Pages are cut from one total order, so a cursor continues exactly where
+// the previous page ended.
+func (s *MemoryStore) Search(ctx context.Context, params *SearchParams) ([]*SearchResult, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+
+	collections, err := s.searchCollections(params.Collections)
+	if err != nil {
+		return nil, err
+	}
+
+	m := newMatcher(params, time.Now())
+	w := newWindow(params)
+
+	// Every collection must supply enough results to fill the page after the offset
+	limit := params.searchLimit()
+	want := limit + max(params.Offset, 0)
+
+	var scored []scoredItem
+	for _, c := range collections {
+		scored = append(scored, c.search(params.Vector, want, m, w)...)
+	}
+
+	// Merge the results of every collection and cut out the page
+	sortScored(scored)
+	scored = scored[min(max(params.Offset, 0), len(scored)):]
+	if len(scored) > limit {
+		scored = scored[:limit]
+	}
+
+	for _, r := range scored {
+		s.usage.hit(r.item.Collection, r.item.DocumentID)
+	}
+	s.touchResults(scored, m.now)
+
+	// Convert to search results
+	results := make([]*SearchResult, len(scored))
+	for i, s := range scored {
+		results[i] = &SearchResult{
+			ID:         s.item.ID,
+			Collection: s.item.Collection,
+			DocumentID: s.item.DocumentID,
+			Content:    s.item.Content,
+			Title:      s.item.Title,
+			Metadata:   s.item.Metadata,
+			Score:      s.score,
+		}
+	}
+	if params.WithVectors {
+		for i, r := range scored {
+			results[i].Vector = s.collections[r.item.Collection].vector(r.item)
+		}
+	}
+
+	return results, nil
+}
+
+// EvaluateRecall measures the recall@k of a collection's HNSW index against
+// exact search for the given queries. It returns 1 when exact search is in use.
+func (s *MemoryStore) EvaluateRecall(ctx context.Context, collection string, queries [][]float32, k int) (float64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return 0, ErrStoreClosed
+	}
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return 0, err
+	}
+
+	if c.index == nil || len(queries) == 0 || k <= 0 {
+		return 1, nil
+	}
+
+	// A zero matcher accepts every item, expired or not
+	all := &matcher{}
+
+	c.rLockShards()
+	defer c.rUnlockShards()
+
+	var total float64
+	for _, query := range queries {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		query = c.query(query)
+
+		exact := c.exactSearch(query, k, all, window{})
+		if len(exact) == 0 {
+			total++
+			continue
+		}
+
+		expected := make(map[string]struct{}, len(exact))
+		for _, e := range exact {
+			expected[e.item.ID] = struct{}{}
+		}
+
+		hits := 0
+		for _, a := range c.index.search(query, k, all.matches, window{}) {
+			if _, ok := expected[a.item.ID]; ok {
+				hits++
+			}
+		}
+		total += float64(hits) / float64(len(exact))
+	}
+
+	return total / float64(len(queries)), nil
+}
+
+// Stats reports the number of items and the memory taken by their vectors
+func (s *MemoryStore) Stats(ctx context.Context) (*Stats, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+
+	stats := &Stats{
+		Backend:     BackendMemory,
+		Collections: make(map[string]*CollectionStats, len(s.collections)),
+	}
+	for name, c := range s.collections {
+		collectionStats := c.stats()
+		stats.Collections[name] = collectionStats
+		stats.Items += collectionStats.Items
+		stats.Documents += collectionStats.Documents
+		stats.VectorBytes += collectionStats.VectorBytes
+	}
+	stats.Memory = s.usage.stats(s.config.MemoryBudget)
+
+	return stats, nil
+}
+
+// Subscribe streams the changes numbered above after; an after of zero
+// streams only new changes
+func (s *MemoryStore) Subscribe(ctx context.Context, after uint64) (*ChangeStream, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+
+	return s.changes.subscribe(after)
+}
+
+// Close closes the store and cleans up resources.
+// A persistent store writes a final snapshot before closing.
+func (s *MemoryStore) Close() error {
+	s.snapshotLock.Lock()
+	defer s.snapshotLock.Unlock()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+	close(s.closeChan)
+	s.changes.close()
+
+	var err error
+	if s.persister != nil {
+		var sequence uint64
+		sequence, err = s.persister.rotate()
+		if err == nil {
+			configs, items := s.contents()
+			err = s.persister.writeSnapshot(configs, items, sequence)
+		}
+		if closeErr := s.persister.close(); err == nil {
+			err = closeErr
+		}
+	}
+	if s.vectors != nil {
+		if closeErr := s.vectors.close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	// Clear collections
+	s.collections = nil
+
+	return err
+}
+
+// Snapshot writes all live items to a new snapshot and discards the
+// write-ahead log segments it covers. It is a no-op for non-persistent stores.
+func (s *MemoryStore) Snapshot() error {
+	if s.persister == nil {
+		return nil
+	}
+
+	s.snapshotLock.Lock()
+	defer s.snapshotLock.Unlock()
+
+	// Writers are blocked only while the items are copied and the log rotated
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return ErrStoreClosed
+	}
+	configs, items := s.contents()
+	sequence, err := s.persister.rotate()
+	s.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return s.persister.writeSnapshot(configs, items, sequence)
+}
+
+// snapshotRoutine periodically snapshots a persistent store
+func (s *MemoryStore) snapshotRoutine() {
+	interval := s.config.SnapshotInterval
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Failures are retried on the next tick; the log keeps the data safe
+			_ = s.Snapshot()
+		case <-s.closeChan:
+			return
+		}
+	}
+}
+
+// cleanupRoutine periodically removes expired items
+func (s *MemoryStore) cleanupRoutine() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.cleanupExpiredItems()
+		case <-s.closeChan:
+			return
+		}
+	}
+}
+
+// cleanupExpiredItems removes all expired items
+func (s *MemoryStore) cleanupExpiredItems() {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	now := time.Now()
+
+	for _, c := range s.collections {
+		c.removeExpired(now)
+	}
+}
+
+// touchResults refreshes the documents of the sliding items among the
+// results of a search. The caller must hold the lock.
+func (s *MemoryStore) touchResults(scored []scoredItem, now time.Time) {
+	var due map[string][]string
+	for _, r := range scored {
+		if r.item.needsRefresh(now) {
+			if due == nil {
+				due = make(map[string][]string)
+			}
+			due[r.item.Collection] = append(due[r.item.Collection], r.item.DocumentID)
+		}
+	}
+
+	for name, documentIDs := range due {
+		s.collections[name].touch(documentIDs, now)
+	}
+}
+
+// collection resolves a collection name; an empty name selects the default.
+// The caller must hold the lock.
+func (s *MemoryStore) collection(name string) (*memoryCollection, error) {
+	if name == "" {
+		name = s.defaultName
+	}
+
+	c, exists := s.collections[name]
+	if !exists {
+		return nil, fmt.Errorf("collection %s: %w", name, ErrCollectionNotFound)
+	}
+
+	return c, nil
+}
+
+// searchCollections resolves the collections named in a search, dropping duplicates.
+// The caller must hold the lock.
+func (s *MemoryStore) searchCollections(names []string) ([]*memoryCollection, error) {
+	if len(names) == 0 {
+		names = []string{s.defaultName}
+	}
+
+	seen := make(map[string]struct{}, len(names))
+	collections := make([]*memoryCollection, 0, len(names))
+	for _, name := range names {
+		c, err := s.collection(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := seen[c.config.Name]; dup {
+			continue
+		}
+		seen[c.config.Name] = struct{}{}
+		collections = append(collections, c)
+	}
+
+	return collections, nil
+}
+
+// newCollection creates an empty collection using the store's index,
+// quantization and reduction settings. The collection takes over the
+// projection of the configuration if it still fits those settings.
+func (s *MemoryStore) newCollection(config CollectionConfig) *memoryCollection {
+	c := &memoryCollection{
+		config:    config,
+		shards:    make([]*memoryShard, shardCount(s.config)),
+		documents: make(map[string]map[string]struct{}),
+		reducer:   newVectorReducer(s.config.Reduction, config),
+		vectors:   s.vectors,
+		usage:     s.usage,
+		changes:   s.changes,
+	}
+	for i := range c.shards {
+		c.shards[i] = newMemoryShard()
+	}
+	c.reducer.adopt(config.Projection)
+	c.config.Projection = nil
+
+	if s.config.Quantization.Enabled {
+		c.quantizer = newScalarQuantizer(s.config.Quantization)
+	} else if s.config.Index.Type != IndexFlat {
+		c.index = newHNSWIndex(s.config.Index, config.Distance)
+	}
+
+	return c
+}
+
+// restore loads the collections and items recovered from disk. The default
+// collection keeps its configured settings but for its projection; items
+// recorded without a collection belong to it. No other goroutine can reach
+// the store yet, so no locks are taken.
+func (s *MemoryStore) restore(state *storeState) {
+	for name, config := range state.collections {
+		if name != s.defaultName {
+			s.collections[name] = s.newCollection(*config)
+		} else {
+			s.collections[name].reducer.adopt(config.Projection)
+		}
+	}
+
+	for name, items := range state.items {
+		if name == "" {
+			name = s.defaultName
+		}
+
+		c, exists := s.collections[name]
+		if !exists {
+			config := *defaultCollectionConfig(s.config)
+			config.Name = name
+			c = s.newCollection(config)
+			s.collections[name] = c
+		}
+
+		for _, item := range items {
+			c.put(item)
+		}
+		c.calibrateIfReady()
+	}
+}
+
+// contents returns the collection configurations, with their projections,
+// and the live items of every collection at full size. Items are restored
+// from full vectors, so that they survive a change of reduction settings.
+// The caller must hold the lock.
+func (s *MemoryStore) contents() ([]*CollectionConfig, []*Item) {
+	now := time.Now()
+
+	var configs []*CollectionConfig
+	var items []*Item
+	for _, c := range s.collections {
+		config := c.config
+		config.Projection = c.reducer.fitted()
+		configs = append(configs, &config)
+		items = append(items, c.liveItems(now)...)
+	}
+
+	return configs, items
+}
+
+// put adds an item to its shard, the document index and the search index.
+// The caller must hold the shard's write lock.
+func (c *memoryCollection) put(item *Item) {
+	if item.Collection != c.config.Name {
+		tagged := *item
+		tagged.Collection = c.config.Name
+		item = &tagged
+	}
+	item = c.prepareVector(item)
+
+	shard := c.shard(item.ID)
+	old, exists := shard.items[item.ID]
+	if !exists {
+		c.size++
+	} else {
+		c.account(-c.itemBytes(shard, old))
+		if old.DocumentID != item.DocumentID {
+			c.unlinkChunk(old)
+		}
+	}
+
+	stored := c.encode(shard, item)
+	shard.items[item.ID] = stored
+	shard.permissions.add(stored)
+	c.account(c.itemBytes(shard, stored))
+
+	chunks, exists := c.documents[item.DocumentID]
+	if !exists {
+		chunks = make(map[string]struct{})
+		c.documents[item.DocumentID] = chunks
+	}
+	chunks[item.ID] = struct{}{}
+	c.usage.hit(c.config.Name, item.DocumentID)
+
+	// Searches hold every shard's read lock while they use the index
+	if c.index != nil {
+		c.index.insert(item)
+	}
+}
+
+// prepareVector returns the item with its vector as the collection keeps
+// it: reduced if it can be, and normalized for cosine distance
+func (c *memoryCollection) prepareVector(item *Item) *Item {
+	if c.reducer.reducible(item.Vector) {
+		reduced := *item
+		reduced.Vector = c.reducer.reduce(item.Vector)
+		item = &reduced
+	}
+	if c.config.Distance == DistanceCosine && !isNormalized(item.Vector) {
+		normalized := *item
+		normalized.Vector = normalize(item.Vector)
+		item = &normalized
+	}
+	return item
+}
+
+// remove deletes an item from its shard, the document index and the search index.
+// The caller must hold the shard's write lock.
+func (c *memoryCollection) remove(id string) {
+	shard := c.shard(id)
+	item, exists := shard.items[id]
+	if !exists {
+		return
+	}
+
+	c.account(-c.itemBytes(shard, item))
+	delete(shard.items, id)
+	c.forget(shard, id)
+	shard.permissions.remove(id)
+	c.size--
+	c.unlinkChunk(item)
+	if c.index != nil {
+		c.index.remove(id)
+	}
+}
+
+// replace swaps the stored copy of an item for one that differs only in
+// its retention or expiry.
+// The caller must hold the shard's write lock.
+func (c *memoryCollection) replace(shard *memoryShard, item *Item) {
+	shard.items[item.ID] = item
+	shard.permissions.update(item)
+	if c.index != nil {
+		c.index.update(item)
+	}
+}
+
+// touch refreshes the expiry of the sliding chunks of documents after one
+// of their chunks was read, so that a document expires as a whole
+func (c *memoryCollection) touch(documentIDs []string, now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, documentID := range documentIDs {
+		for id := range c.documents[documentID] {
+			c.refresh(id, now)
+		}
+	}
+}
+
+// refresh extends the expiry of a sliding item that is due for it and
+// returns the item as stored, or nil if there is none. Refreshes are not
+// logged; the next snapshot persists them.
+// The caller must hold the collection lock.
+func (c *memoryCollection) refresh(id string, now time.Time) *Item {
+	shard := c.shard(id)
+	item, exists := shard.items[id]
+	if !exists || !item.needsRefresh(now) {
+		return item
+	}
+
+	item = item.withRetention(item.Retention, item.Retention.expiry(now))
+	shard.lock.Lock()
+	c.replace(shard, item)
+	shard.lock.Unlock()
+
+	return item
+}
+
+// removeDocument deletes every chunk of a document.
+// The caller must hold the collection lock and every shard's write lock.
+func (c *memoryCollection) removeDocument(documentID string) {
+	for id := range c.documents[documentID] {
+		c.remove(id)
+	}
+}
+
+// documentItems returns the IDs of a document's chunks, sorted.
+// The caller must hold the collection lock.
+func (c *memoryCollection) documentItems(documentID string) []string {
+	ids := make([]string, 0, len(c.documents[documentID]))
+	for id := range c.documents[documentID] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// removeExpired deletes the items whose expiry has passed
+func (c *memoryCollection) removeExpired(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var expired []*Item
+	for _, shard := range c.shards {
+		for _, item := range shard.items {
+			if item.isExpired(now) {
+				expired = append(expired, item)
+			}
+		}
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	c.lockShards()
+	for _, item := range expired {
+		c.remove(item.ID)
+	}
+	c.unlockShards()
+
+	c.changes.publish(documentChanges(ChangeExpire, c.config.Name, expired)...)
+}
+
+// unlinkChunk drops an item from the document index
+func (c *memoryCollection) unlinkChunk(item *Item) {
+	chunks := c.documents[item.DocumentID]
+	delete(chunks, item.ID)
+	if len(chunks) == 0 {
+		delete(c.documents, item.DocumentID)
+		c.usage.forget(c.config.Name, item.DocumentID)
+	}
+}
+
+// liveItems returns the items that have not expired, with their vectors.
+// The caller must hold the store lock exclusively.
+func (c *memoryCollection) liveItems(now time.Time) []*Item {
+	items := make([]*Item, 0, c.size)
+	for _, shard := range c.shards {
+		for _, item := range shard.items {
+			if !item.isExpired(now) {
+				items = append(items, c.decode(shard, item))
+			}
+		}
+	}
+
+	return items
+}
+
+// vector returns the stored vector of an item, reading it back from the
+// vector file if it is quantized
+func (c *memoryCollection) vector(item *Item) []float32 {
+	shard := c.shard(item.ID)
+	shard.lock.RLock()
+	defer shard.lock.RUnlock()
+
+	return c.decode(shard, item).Vector
+}
+
+// encode returns the copy of an item to keep in its shard, replacing its
+// vector with int8 codes once the quantizer is calibrated and moving the
+// float vector to the vector file. An item whose vector cannot be written
+// keeps it in memory.
+// The caller must hold the shard's write lock.
+func (c *memoryCollection) encode(shard *memoryShard, item *Item) *Item {
+	if c.quantizer == nil || !c.quantizer.calibrated {
+		return item
+	}
+
+	c.forget(shard, item.ID)
+	code := c.quantizer.quantize(item.Vector)
+	offset, err := c.vectors.write(item.Vector)
+	if err != nil {
+		shard.codes[item.ID] = code
+		return item
+	}
+	code.offset = offset
+	shard.codes[item.ID] = code
+
+	stored := *item
+	stored.Vector = nil
+	return &stored
+}
+
+// decode returns an item with its float vector read back and restored to
+// its full size. Restored vectors are an approximation of reduced ones.
+// The caller must hold the shard's lock.
+func (c *memoryCollection) decode(shard *memoryShard, item *Item) *Item {
+	code, quantized := shard.codes[item.ID]
+	if !quantized && !c.reducer.reduced(item.Vector) {
+		return item
+	}
+
+	decoded := *item
+	if quantized {
+		decoded.Vector = c.floatVector(item, code)
+	}
+	decoded.Vector = c.reducer.restore(decoded.Vector)
+	return &decoded
+}
+
+// floatVector returns the float vector of a quantized item. It is read
+// from the vector file unless the item kept it in memory; if the file
+// cannot be read, the codes are dequantized instead.
+func (c *memoryCollection) floatVector(item *Item, code quantizedVector) []float32 {
+	if code.offset < 0 {
+		return item.Vector
+	}
+
+	vector, err := c.vectors.read(code.offset, len(code.codes))
+	if err != nil {
+		return c.quantizer.dequantize(code)
+	}
+	return vector
+}
+
+// forget drops the codes of an item and frees its float vector.
+// The caller must hold the shard's write lock.
+func (c *memoryCollection) forget(shard *memoryShard, id string) {
+	code, exists := shard.codes[id]
+	if !exists {
+		return
+	}
+
+	if code.offset >= 0 {
+		c.vectors.release(code.offset, len(code.codes))
+	}
+	delete(shard.codes, id)
+}
+
+// forgetVectors frees the float vectors of a collection that is dropped.
+// The caller must hold the store's write lock.
+func (c *memoryCollection) forgetVectors() {
+	for _, shard := range c.shards {
+		for id := range shard.codes {
+			c.forget(shard, id)
+		}
+	}
+}
+
+// calibrateIfReady fits the collection's projection, then quantizes the
+// collection, once enough full vectors have been stored. Quantization
+// waits for the projection, as it moves the vectors the projection is
+// fitted on out of memory. The caller must hold the collection lock.
+func (c *memoryCollection) calibrateIfReady() {
+	if c.reducer.fitting() {
+		if c.size < c.reducer.sampleSize {
+			return
+		}
+
+		c.lockShards()
+		c.project()
+		c.unlockShards()
+	}
+
+	if c.quantizer == nil || c.quantizer.calibrated || c.size < c.quantizer.calibrationSize {
+		return
+	}
+
+	c.lockShards()
+	c.calibrate()
+	c.unlockShards()
+}
+
+// project fits the PCA projection on a sample of the vectors stored so far
+// and reduces all of them, rebuilding the index from the reduced vectors.
+// The caller must hold the collection lock and every shard's write lock.
+func (c *memoryCollection) project() {
+	sample := make([][]float32, 0, c.reducer.sampleSize)
+	for _, shard := range c.shards {
+		for _, item := range shard.items {
+			if len(sample) < c.reducer.sampleSize {
+				sample = append(sample, item.Vector)
+			}
+		}
+	}
+	c.reducer.fit(sample)
+
+	if c.index != nil {
+		c.index.reset()
+	}
+	for _, shard := range c.shards {
+		for id, item := range shard.items {
+			before := c.itemBytes(shard, item)
+			stored := c.prepareVector(item)
+			shard.items[id] = stored
+			shard.permissions.update(stored)
+			c.account(c.itemBytes(shard, stored) - before)
+			if c.index != nil {
+				c.index.insert(stored)
+			}
+		}
+	}
+}
+
+// calibrate fixes the quantization range from the float vectors stored so
+// far and quantizes all of them.
+// The caller must hold the collection lock and every shard's write lock.
+func (c *memoryCollection) calibrate() {
+	sample := make([][]float32, 0, c.size)
+	for _, shard := range c.shards {
+		for _, item := range shard.items {
+			sample = append(sample, item.Vector)
+		}
+	}
+	c.quantizer.calibrate(c.config.Distance, sample)
+
+	for _, shard := range c.shards {
+		for id, item := range shard.items {
+			before := c.itemBytes(shard, item)
+			stored := c.encode(shard, item)
+			shard.items[id] = stored
+			shard.permissions.update(stored)
+			c.account(c.itemBytes(shard, stored) - before)
+		}
+	}
+}
+
+// stats reports the size of the collection
+func (c *memoryCollection) stats() *CollectionStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stats := &CollectionStats{
+		Items:       c.size,
+		Documents:   len(c.documents),
+		MemoryBytes: c.bytes,
+	}
+
+	var floatBytes, rescoreBytes int64
+	quantizedItems, reducedItems := 0, 0
+	for _, shard := range c.shards {
+		quantizedItems += len(shard.codes)
+		for id, item := range shard.items {
+			dimensions := len(item.Vector)
+			stats.VectorBytes += 4 * int64(dimensions)
+			if code, exists := shard.codes[id]; exists {
+				dimensions = len(code.codes)
+				stats.VectorBytes += int64(dimensions)
+				if code.offset >= 0 {
+					rescoreBytes += 4 * int64(dimensions)
+				}
+			}
+			floatBytes += 4 * int64(dimensions)
+			if c.reducer != nil && dimensions == c.reducer.dimensions {
+				reducedItems++
+			}
+		}
+	}
+	stats.Reduction = c.reducer.stats(reducedItems)
+
+	if c.quantizer != nil {
+		stats.Quantization = &QuantizationStats{
+			Calibrated:       c.quantizer.calibrated,
+			QuantizedItems:   quantizedItems,
+			Min:              c.quantizer.min,
+			Max:              c.quantizer.max,
+			Scale:            c.quantizer.scale,
+			CompressionRatio: 1,
+			RescoreBytes:     rescoreBytes,
+			Recall:           c.quantizer.recall,
+		}
+		if stats.VectorBytes > 0 {
+			stats.Quantization.CompressionRatio = float64(floatBytes) / float64(stats.VectorBytes)
+		}
+	}
+
+	return stats
+}
+
+// search returns the best limit matching items inside the window, using
+// the index when there is one
+func (c *memoryCollection) search(vector []float32, limit int, m *matcher, w window) []scoredItem {
+	c.rLockShards()
+	defer c.rUnlockShards()
+
+	vector = c.query(vector)
+
+	var scored []scoredItem
+	if c.index != nil && limit > 0 {
+		scored = c.index.search(vector, limit, m.matches, w)
+	}
+	if len(scored) < limit || limit <= 0 {
+		scored = c.exactSearch(vector, limit, m, w)
+	}
+
+	return scored
+}
+
+// query prepares a query vector for the metric and reduces it like the
+// stored vectors. The caller must hold a shard's lock, as the projection
+// is fitted under all of them.
+func (c *memoryCollection) query(vector []float32) []float32 {
+	return c.config.Distance.query(c.reducer.reduce(vector))
+}
+
+// exactSearch scores every matching item and returns the best limit of
+// those inside the window. The shards are scanned in parallel, each
+// keeping only its own best limit items. The vector must be prepared for
+// the metric, and the caller must hold every shard's read lock.
+func (c *memoryCollection) exactSearch(vector []float32, limit int, m *matcher, w window) []scoredItem {
+	if c.quantizer != nil && c.quantizer.calibrated {
+		query := c.quantizer.quantize(vector)
+		return c.scanShards(limit, func(shard *memoryShard) []scoredItem {
+			return c.quantizedScan(shard, vector, query, limit, m, w)
+		})
+	}
+
+	return c.scanShards(limit, func(shard *memoryShard) []scoredItem {
+		best := newTopK(limit)
+		m.forEach(shard, func(item *Item) {
+			s := scoredItem{item: item, score: c.config.Distance.score(vector, item.Vector)}
+			if w.admits(s) {
+				best.push(s)
+			}
+		})
+		return best.sorted()
+	})
+}
+
+// quantizedScan ranks the matching items of a shard with int8 dot
+// products, then rescores the best candidates with their float vectors.
+// The window applies to float scores, so a bounded window rescores every
+// candidate.
+func (c *memoryCollection) quantizedScan(shard *memoryShard, vector []float32, query quantizedVector, limit int, m *matcher, w window) []scoredItem {
+	metric := c.config.Distance
+
+	candidates := newTopK(0)
+	if w.isOpen() && limit > 0 {
+		candidates = newTopK(c.quantizer.candidates(limit))
+	}
+	m.forEach(shard, func(item *Item) {
+		score := c.quantizer.similarity(metric, query, shard.codes[item.ID])
+		candidates.push(scoredItem{item: item, score: score})
+	})
+
+	// Rescore the candidates with the float vectors
+	best := newTopK(limit)
+	for _, s := range candidates.items {
+		s.score = metric.similarity(vector, c.floatVector(s.item, shard.codes[s.item.ID]))
+		if w.admits(s) {
+			best.push(s)
+		}
+	}
+
+	return best.sorted()
+}
+
+// sortScored sorts scored items best first
+func sortScored(items []scoredItem) {
+	sort.Slice(items, func(i, j int) bool {
+		return better(items[i], items[j])
+	})
+}
+
+// better reports whether a ranks before b: by descending score, with ties
+// broken by collection and item ID so that pages are stable
+func better(a, b scoredItem) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	if a.item.Collection != b.item.Collection {
+		return a.item.Collection < b.item.Collection
+	}
+	return a.item.ID < b.item.ID
+}
@@ -0,0 +1,104 @@
+package vectorstore
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// DefaultMMRLambda weighs relevance and diversity equally
+const DefaultMMRLambda = 0.5
+
+// Error definitions
+var (
+	ErrInvalidMMR = errors.New("invalid MMR options")
+)
+
+// MMR configures Maximal Marginal Relevance re-ranking, which trades the
+// relevance of each result against its similarity to the results ranked
+// above it, so that near-duplicate chunks do not crowd out the rest
+type MMR struct {
+	// Lambda weighs relevance against diversity, from 0 (only diversity)
+	// to 1 (only relevance)
+	Lambda float64 `json:"lambda"`
+	// MaxPerDocument caps the results taken from one document; zero leaves
+	// it uncapped
+	MaxPerDocument int `json:"max_per_document"`
+}
+
+// Validate checks the lambda and document cap
+func (m *MMR) Validate() error {
+	if math.IsNaN(m.Lambda) || m.Lambda < 0 || m.Lambda > 1 {
+		return fmt.Errorf("%w: lambda %v is outside [0, 1]", ErrInvalidMMR, m.Lambda)
+	}
+	if m.MaxPerDocument < 0 {
+		return fmt.Errorf("%w: negative document cap %d", ErrInvalidMMR, m.MaxPerDocument)
+	}
+	return nil
+}
+
+// Rerank selects up to limit of the candidates in MMR order. The
+// candidates must carry their vectors; relevance and redundancy are both
+// measured by cosine similarity, whatever the collection's distance, so
+// that the two are on the same scale. Ties keep the candidates' order.
+func (m *MMR) Rerank(query []float32, candidates []*SearchResult, limit int) []*SearchResult {
+	if limit <= 0 || limit > len(candidates) {
+		limit = len(candidates)
+	}
+
+	relevance := make([]float64, len(candidates))
+	for i, candidate := range candidates {
+		relevance[i] = cosineSimilarity(query, candidate.Vector)
+	}
+
+	// redundancy holds each candidate's highest similarity to a selected result
+	redundancy := make([]float64, len(candidates))
+	for i := range redundancy {
+		redundancy[i] = math.Inf(-1)
+	}
+	selected := make([]bool, len(candidates))
+	perDocument := make(map[documentKey]int)
+
+	ranked := make([]*SearchResult, 0, limit)
+	for len(ranked) < limit {
+		best, bestScore := -1, math.Inf(-1)
+		for i, candidate := range candidates {
+			if selected[i] {
+				continue
+			}
+			if m.MaxPerDocument > 0 && perDocument[resultDocument(candidate)] >= m.MaxPerDocument {
+				continue
+			}
+
+			score := m.Lambda * relevance[i]
+			if len(ranked) > 0 {
+				score -= (1 - m.Lambda) * redundancy[i]
+			}
+			if best < 0 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			// The document cap excludes every remaining candidate
+			break
+		}
+
+		pick := candidates[best]
+		selected[best] = true
+		perDocument[resultDocument(pick)]++
+		ranked = append(ranked, pick)
+
+		for i, candidate := range candidates {
+			if !selected[i] {
+				redundancy[i] = max(redundancy[i], cosineSimilarity(candidate.Vector, pick.Vector))
+			}
+		}
+	}
+
+	return ranked
+}
+
+// resultDocument names the document of a result
+func resultDocument(result *SearchResult) documentKey {
+	return documentKey{collection: result.Collection, documentID: result.DocumentID}
+}
@@ -0,0 +1,57 @@
+package vectorstore
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestMMRRerank(t *testing.T) {
+	// Three near-duplicate chunks of one document outrank a distinct chunk
+	query := []float32{1, 0, 0}
+	candidates := []*SearchResult{
+		{ID: "dup-1", DocumentID: "a", Vector: []float32{1, 0.05, 0}},
+		{ID: "dup-2", DocumentID: "a", Vector: []float32{1, 0.06, 0}},
+		{ID: "dup-3", DocumentID: "a", Vector: []float32{1, 0.07, 0}},
+		{ID: "distinct", DocumentID: "b", Vector: []float32{0.6, 0, 0.8}},
+	}
+
+	tests := []struct {
+		name string
+		mmr  MMR
+		want []string
+	}{
+		{"only relevance", MMR{Lambda: 1}, []string{"dup-1", "dup-2", "dup-3"}},
+		{"mostly relevance", MMR{Lambda: 0.9}, []string{"dup-1", "dup-2", "dup-3"}},
+		{"mostly diversity", MMR{Lambda: 0.3}, []string{"dup-1", "distinct", "dup-2"}},
+		{"only diversity", MMR{Lambda: 0}, []string{"dup-1", "distinct", "dup-3"}},
+		{"one chunk per document", MMR{Lambda: 1, MaxPerDocument: 1}, []string{"dup-1", "distinct"}},
+	}
+
+	for _, tt := range tests {
+		var ids []string
+		for _, result := range tt.mmr.Rerank(query, candidates, 3) {
+			ids = append(ids, result.ID)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("%s ranked %v, want %v", tt.name, ids, tt.want)
+		}
+	}
+
+	// Without a limit every candidate is ranked
+	if ranked := (&MMR{Lambda: 0.5}).Rerank(query, candidates, 0); len(ranked) != len(candidates) {
+		t.Errorf("Rerank without a limit returned %d of %d candidates", len(ranked), len(candidates))
+	}
+}
+
+func TestMMRValidate(t *testing.T) {
+	for _, mmr := range []MMR{{Lambda: -0.1}, {Lambda: 1.5}, {Lambda: math.NaN()}, {Lambda: 0.5, MaxPerDocument: -1}} {
+		if err := mmr.Validate(); !errors.Is(err, ErrInvalidMMR) {
+			t.Errorf("Validate of %+v returned %v, want ErrInvalidMMR", mmr, err)
+		}
+	}
+	if err := (&MMR{Lambda: DefaultMMRLambda, MaxPerDocument: 2}).Validate(); err != nil {
+		t.Errorf("Validate of the default lambda failed: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package vectorstore
+
+import (
+	"fmt"
+)
+
+// EmbeddingModel identifies the model, and the version of it, that made a
+// vector. Vectors of different models are not comparable, even when they
+// have the same size.
+type EmbeddingModel struct {
+	ID string `json:"id"`
+	// Version distinguishes models that changed under the same ID
+	Version int `json:"version,omitempty"`
+}
+
+// IsZero reports whether the model is unknown, as it is for items stored
+// before items were tagged with their model
+func (m EmbeddingModel) IsZero() bool {
+	return m == EmbeddingModel{}
+}
+
+// String formats the model as its ID, followed by @v and the version if
+// it has one
+func (m EmbeddingModel) String() string {
+	if m.Version == 0 {
+		return m.ID
+	}
+	return fmt.Sprintf("%s@v%d", m.ID, m.Version)
+}
+
+// accepts reports whether a search for vectors of model m may return an
+// item. A nil model accepts every item. Untagged items are accepted by no
+// model, since nothing tells which one made them; a migration to another
+// model, or to another version of the same one, re-embeds them.
+func (m *EmbeddingModel) accepts(item *Item) bool {
+	return m == nil || item.Model == *m
+}
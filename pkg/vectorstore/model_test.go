@@ -0,0 +1,38 @@
+package vectorstore
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSearchByModel(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, &Config{VectorSize: 2})
+
+	current := EmbeddingModel{ID: "current", Version: 2}
+	items := []*Item{
+		{ID: "current", DocumentID: "current", Vector: []float32{1, 0}, Model: current},
+		{ID: "earlier", DocumentID: "earlier", Vector: []float32{1, 0}, Model: EmbeddingModel{ID: "current", Version: 1}},
+		{ID: "other", DocumentID: "other", Vector: []float32{1, 0}, Model: EmbeddingModel{ID: "other"}},
+		{ID: "untagged", DocumentID: "untagged", Vector: []float32{1, 0}},
+	}
+	if err := store.StoreBatch(ctx, "", items); err != nil {
+		t.Fatalf("StoreBatch failed: %v", err)
+	}
+
+	results, err := store.Search(ctx, &SearchParams{Vector: []float32{1, 0}, Limit: 10, Model: &current})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "current" {
+		t.Errorf("search of model %s returned %d results, want only the item of that model", current, len(results))
+	}
+
+	results, err = store.Search(ctx, &SearchParams{Vector: []float32{1, 0}, Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != len(items) {
+		t.Errorf("search without a model returned %d results, want %d", len(results), len(items))
+	}
+}
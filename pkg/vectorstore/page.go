@@ -0,0 +1,119 @@
+package vectorstore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// defaultSearchLimit is the number of results of a search without a limit
+const defaultSearchLimit = 10
+
+// Error definitions
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Cursor marks the last result of a page. Search results are ordered by
+// descending score, then by collection and item ID, so a cursor identifies
+// a unique position that stays valid while the store changes.
+type Cursor struct {
+	Score      float64 `json:"s"`
+	Collection string  `json:"c"`
+	ID         string  `json:"i"`
+}
+
+// searchLimit returns the number of results of a search
+func (p *SearchParams) searchLimit() int {
+	if p.Limit <= 0 {
+		return defaultSearchLimit
+	}
+	return p.Limit
+}
+
+// CursorAfter returns the cursor that continues after a result
+func CursorAfter(result *SearchResult) *Cursor {
+	return &Cursor{Score: result.Score, Collection: result.Collection, ID: result.ID}
+}
+
+// Encode returns the cursor as an opaque URL-safe token
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token produced by Cursor.Encode
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	return &cursor, nil
+}
+
+// follows reports whether a result sorts after the cursor
+func (c *Cursor) follows(score float64, collection, id string) bool {
+	if score != c.Score {
+		return score < c.Score
+	}
+	if collection != c.Collection {
+		return collection > c.Collection
+	}
+	return id > c.ID
+}
+
+// window restricts scored results to those after a cursor and at or above
+// a minimum score
+type window struct {
+	after    *Cursor
+	minScore *float64
+}
+
+// newWindow builds the window of a search
+func newWindow(params *SearchParams) window {
+	return window{after: params.After, minScore: params.MinScore}
+}
+
+// isOpen reports whether the window admits every result
+func (w window) isOpen() bool {
+	return w.after == nil && w.minScore == nil
+}
+
+// admits reports whether a scored item falls inside the window
+func (w window) admits(s scoredItem) bool {
+	if w.minScore != nil && s.score < *w.minScore {
+		return false
+	}
+	if w.after != nil && !w.after.follows(s.score, s.item.Collection, s.item.ID) {
+		return false
+	}
+	return true
+}
+
+// admitsResult reports whether a search result falls inside the window
+func (w window) admitsResult(r *SearchResult) bool {
+	return w.admits(scoredItem{item: &Item{ID: r.ID, Collection: r.Collection}, score: r.Score})
+}
+
+// sortResults orders search results by descending score, breaking ties by
+// collection and item ID, the same order as the in-memory backend
+func sortResults(results []*SearchResult) {
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Collection != b.Collection {
+			return a.Collection < b.Collection
+		}
+		return a.ID < b.ID
+	})
+}
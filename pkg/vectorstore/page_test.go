@@ -0,0 +1,122 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCursorEncoding(t *testing.T) {
+	cursor := &Cursor{Score: 0.75, Collection: "docs", ID: "a-1"}
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor failed: %v", err)
+	}
+	if *decoded != *cursor {
+		t.Errorf("cursor decoded as %+v, want %+v", decoded, cursor)
+	}
+
+	for _, token := range []string{"not base64!", "bm90IGpzb24"} {
+		if _, err := DecodeCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q) returned %v, want ErrInvalidCursor", token, err)
+		}
+	}
+}
+
+func TestSearchPagination(t *testing.T) {
+	stores := map[string]func(t *testing.T) VectorStore{
+		"memory": func(t *testing.T) VectorStore { return newTestStore(t, &Config{VectorSize: 2}) },
+		"bolt":   func(t *testing.T) VectorStore { return newTestStore(t, &Config{Backend: BackendBolt, VectorSize: 2}) },
+		"qdrant": func(t *testing.T) VectorStore {
+			_, store := newFakeQdrant(t, &Config{VectorSize: 2})
+			return store
+		},
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := open(t)
+			if err := store.CreateCollection(ctx, &CollectionConfig{Name: "other"}); err != nil {
+				t.Fatalf("CreateCollection failed: %v", err)
+			}
+
+			// Equal vectors tie on score within and across collections
+			vectors := [][]float32{{1, 0}, {1, 0}, {1, 0.5}, {1, 1}, {0.5, 1}, {1, 0}, {1, 1}}
+			for i, vector := range vectors {
+				collection := DefaultCollection
+				if i >= 5 {
+					collection = "other"
+				}
+				item := &Item{ID: fmt.Sprintf("item-%d", i), DocumentID: fmt.Sprintf("doc-%d", i), Collection: collection, Vector: vector}
+				if err := store.Store(ctx, item); err != nil {
+					t.Fatalf("Store failed: %v", err)
+				}
+			}
+
+			params := SearchParams{Collections: []string{DefaultCollection, "other"}, Vector: []float32{1, 0}, Limit: 100}
+			all, err := store.Search(ctx, &params)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(all) != len(vectors) {
+				t.Fatalf("Search returned %d results, want %d", len(all), len(vectors))
+			}
+			for i := 1; i < len(all); i++ {
+				if !CursorAfter(all[i-1]).follows(all[i].Score, all[i].Collection, all[i].ID) {
+					t.Errorf("result %s ranks after %s out of order", all[i].ID, all[i-1].ID)
+				}
+			}
+
+			// Following cursors visits every result once, in order
+			var paged []string
+			page := params
+			page.Limit = 2
+			for {
+				results, err := store.Search(ctx, &page)
+				if err != nil {
+					t.Fatalf("Search of page %d failed: %v", len(paged)/2, err)
+				}
+				paged = append(paged, resultIDs(results)...)
+				if len(results) < page.Limit {
+					break
+				}
+				page.After = CursorAfter(results[len(results)-1])
+			}
+			if fmt.Sprint(paged) != fmt.Sprint(resultIDs(all)) {
+				t.Errorf("paging by cursor visited %v, want %v", paged, resultIDs(all))
+			}
+
+			// The offset counts from the cursor
+			page = params
+			page.After = CursorAfter(all[0])
+			page.Offset = 2
+			page.Limit = 3
+			results, err := store.Search(ctx, &page)
+			if err != nil {
+				t.Fatalf("Search with an offset failed: %v", err)
+			}
+			if fmt.Sprint(resultIDs(results)) != fmt.Sprint(resultIDs(all[3:6])) {
+				t.Errorf("offset 2 after the first result returned %v, want %v", resultIDs(results), resultIDs(all[3:6]))
+			}
+
+			// Results scoring below the minimum are dropped
+			minScore := all[3].Score
+			page = params
+			page.MinScore = &minScore
+			results, err = store.Search(ctx, &page)
+			if err != nil {
+				t.Fatalf("Search with a minimum score failed: %v", err)
+			}
+			for _, result := range results {
+				if result.Score < minScore {
+					t.Errorf("result %s scores %f, below the minimum %f", result.ID, result.Score, minScore)
+				}
+			}
+			if len(results) < 4 || len(results) == len(all) {
+				t.Errorf("minimum score kept %d of %d results", len(results), len(all))
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package vectorstore
+
+import (
+	"math/bits"
+	"time"
+)
+
+// bitmap is a set of slots, one bit per slot
+type bitmap []uint64
+
+// set adds a slot
+func (b *bitmap) set(slot uint32) {
+	word := int(slot / 64)
+	if word >= len(*b) {
+		grown := make(bitmap, word+1)
+		copy(grown, *b)
+		*b = grown
+	}
+	(*b)[word] |= 1 << (slot % 64)
+}
+
+// clear removes a slot
+func (b bitmap) clear(slot uint32) {
+	if word := int(slot / 64); word < len(b) {
+		b[word] &^= 1 << (slot % 64)
+	}
+}
+
+// isEmpty reports whether no slot is set
+func (b bitmap) isEmpty() bool {
+	for _, word := range b {
+		if word != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// or adds every slot of other
+func (b *bitmap) or(other bitmap) {
+	if len(other) > len(*b) {
+		grown := make(bitmap, len(other))
+		copy(grown, *b)
+		*b = grown
+	}
+	for i, word := range other {
+		(*b)[i] |= word
+	}
+}
+
+// forEach calls fn for every set slot in ascending order
+func (b bitmap) forEach(fn func(slot uint32)) {
+	for i, word := range b {
+		for word != 0 {
+			bit := bits.TrailingZeros64(word)
+			fn(uint32(i*64 + bit))
+			word &= word - 1
+		}
+	}
+}
+
+// permissionIndex is an inverted index from each permission to a bitmap of
+// the items granting it. Items are numbered by slot and freed slots are
+// reused, so the bitmaps stay as dense as the shard.
+type permissionIndex struct {
+	items       []*Item           // items by slot; nil for free slots
+	slots       map[string]uint32 // slot of each item ID
+	free        []uint32
+	permissions map[string]bitmap
+}
+
+// newPermissionIndex creates an empty index
+func newPermissionIndex() *permissionIndex {
+	return &permissionIndex{
+		slots:       make(map[string]uint32),
+		permissions: make(map[string]bitmap),
+	}
+}
+
+// add indexes an item under each of its permissions, replacing an earlier
+// item with the same ID
+func (p *permissionIndex) add(item *Item) {
+	slot, exists := p.slots[item.ID]
+	if exists {
+		p.unlink(slot)
+	} else if n := len(p.free); n > 0 {
+		slot, p.free = p.free[n-1], p.free[:n-1]
+	} else {
+		slot = uint32(len(p.items))
+		p.items = append(p.items, nil)
+	}
+
+	p.slots[item.ID] = slot
+	p.items[slot] = item
+	for _, permission := range item.Permissions {
+		b := p.permissions[permission]
+		b.set(slot)
+		p.permissions[permission] = b
+	}
+}
+
+// update swaps the stored copy of an indexed item without changing its
+// permissions
+func (p *permissionIndex) update(item *Item) {
+	if slot, exists := p.slots[item.ID]; exists {
+		p.items[slot] = item
+	}
+}
+
+// remove drops an item from the index and frees its slot
+func (p *permissionIndex) remove(id string) {
+	slot, exists := p.slots[id]
+	if !exists {
+		return
+	}
+
+	p.unlink(slot)
+	p.items[slot] = nil
+	delete(p.slots, id)
+	p.free = append(p.free, slot)
+}
+
+// unlink clears a slot from the bitmaps of its item's permissions
+func (p *permissionIndex) unlink(slot uint32) {
+	for _, permission := range p.items[slot].Permissions {
+		b := p.permissions[permission]
+		b.clear(slot)
+		if b.isEmpty() {
+			delete(p.permissions, permission)
+		}
+	}
+}
+
+// granted returns the slots of the items granting any of the permissions
+func (p *permissionIndex) granted(permissions []string) bitmap {
+	var union bitmap
+	for _, permission := range permissions {
+		union.or(p.permissions[permission])
+	}
+	return union
+}
+
+// matcher applies the expiry, model, metadata and permission checks of a search
+type matcher struct {
+	now    time.Time
+	filter *Filter
+	model  *EmbeddingModel
+	// permissions lists the permissions that grant access; nil skips the check
+	permissions []string
+	granted     map[string]struct{}
+}
+
+// newMatcher builds the matcher of a search
+func newMatcher(params *SearchParams, now time.Time) *matcher {
+	m := &matcher{now: now, filter: params.Filter, model: params.Model}
+	if len(params.PermissionFilter) > 0 {
+		m.permissions = params.PermissionFilter
+		m.granted = make(map[string]struct{}, len(params.PermissionFilter))
+		for _, permission := range params.PermissionFilter {
+			m.granted[permission] = struct{}{}
+		}
+	}
+	return m
+}
+
+// matches reports whether an item passes every check
+func (m *matcher) matches(item *Item) bool {
+	return m.matchesAttributes(item) && m.permits(item)
+}
+
+// matchesAttributes applies the expiry, model and metadata checks, for
+// items already known to be permitted
+func (m *matcher) matchesAttributes(item *Item) bool {
+	return !item.isExpired(m.now) && m.model.accepts(item) && m.filter.Matches(item.Metadata)
+}
+
+// permits reports whether an item grants one of the permissions
+func (m *matcher) permits(item *Item) bool {
+	if m.permissions == nil {
+		return true
+	}
+	for _, permission := range item.Permissions {
+		if _, ok := m.granted[permission]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// forEach calls fn for every item of a shard that passes the matcher. With
+// a permission filter only the items in the shard's permission bitmaps are
+// visited. The caller must hold the shard's read lock.
+func (m *matcher) forEach(shard *memoryShard, fn func(*Item)) {
+	if m.permissions == nil {
+		for _, item := range shard.items {
+			if m.matchesAttributes(item) {
+				fn(item)
+			}
+		}
+		return
+	}
+
+	shard.permissions.granted(m.permissions).forEach(func(slot uint32) {
+		if item := shard.permissions.items[slot]; m.matchesAttributes(item) {
+			fn(item)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package vectorstore
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBitmap(t *testing.T) {
+	var b bitmap
+	if !b.isEmpty() {
+		t.Errorf("a new bitmap is not empty")
+	}
+	for _, slot := range []uint32{3, 64, 130} {
+		b.set(slot)
+	}
+	b.clear(64)
+	b.clear(1000)
+
+	var other bitmap
+	other.set(5)
+	other.set(200)
+	b.or(other)
+
+	var slots []uint32
+	b.forEach(func(slot uint32) { slots = append(slots, slot) })
+	if want := []uint32{3, 5, 130, 200}; !slices.Equal(slots, want) {
+		t.Errorf("bitmap holds %v, want %v", slots, want)
+	}
+}
+
+func TestPermissionIndex(t *testing.T) {
+	index := newPermissionIndex()
+	index.add(&Item{ID: "a", Permissions: []string{"alice", "team"}})
+	index.add(&Item{ID: "b", Permissions: []string{"bob", "team"}})
+
+	granted := func(permissions ...string) []string {
+		var ids []string
+		index.granted(permissions).forEach(func(slot uint32) { ids = append(ids, index.items[slot].ID) })
+		slices.Sort(ids)
+		return ids
+	}
+	if ids := granted("team"); !slices.Equal(ids, []string{"a", "b"}) {
+		t.Errorf("team is granted %v, want [a b]", ids)
+	}
+
+	// Replacing an item moves it between the bitmaps of its old and new permissions
+	index.add(&Item{ID: "a", Permissions: []string{"carol"}})
+	if ids := granted("alice", "team"); !slices.Equal(ids, []string{"b"}) {
+		t.Errorf("alice and team are granted %v after the update, want [b]", ids)
+	}
+	if ids := granted("carol"); !slices.Equal(ids, []string{"a"}) {
+		t.Errorf("carol is granted %v after the update, want [a]", ids)
+	}
+	if _, exists := index.permissions["alice"]; exists {
+		t.Errorf("the bitmap of a permission no item grants was kept")
+	}
+
+	// Freed slots are reused
+	slot := index.slots["b"]
+	index.remove("b")
+	index.add(&Item{ID: "c", Permissions: []string{"team"}})
+	if index.slots["c"] != slot || len(index.items) != 2 {
+		t.Errorf("c took slot %d of %d, want the freed slot %d", index.slots["c"], len(index.items), slot)
+	}
+	if ids := granted("bob", "team"); !slices.Equal(ids, []string{"c"}) {
+		t.Errorf("bob and team are granted %v, want [c]", ids)
+	}
+}
+
+func TestPermissionFilteredSearch(t *testing.T) {
+	stores := map[string]func(t *testing.T) VectorStore{
+		"sharded": func(t *testing.T) VectorStore { return newTestStore(t, &Config{VectorSize: 2, Shards: 4}) },
+		"hnsw": func(t *testing.T) VectorStore {
+			return newTestStore(t, &Config{VectorSize: 2, Index: IndexConfig{Type: IndexHNSW}})
+		},
+	}
+	for name, open := range testStores {
+		stores[name] = open
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := open(t)
+			items := []*Item{
+				{ID: "a", DocumentID: "a", Vector: []float32{1, 0}, Permissions: []string{"alice"}},
+				{ID: "b", DocumentID: "b", Vector: []float32{0.9, 0.1}, Permissions: []string{"bob"}},
+				{ID: "t", DocumentID: "t", Vector: []float32{0.8, 0.2}, Permissions: []string{"alice", "bob"}},
+				{ID: "n", DocumentID: "n", Vector: []float32{0.7, 0.3}},
+			}
+			if err := store.StoreBatch(ctx, "", items); err != nil {
+				t.Fatalf("StoreBatch failed: %v", err)
+			}
+
+			search := func(permissions ...string) []string {
+				t.Helper()
+				results, err := store.Search(ctx, &SearchParams{Vector: []float32{1, 0}, Limit: 10, PermissionFilter: permissions})
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				return resultIDs(results)
+			}
+			tests := []struct {
+				permissions []string
+				want        []string
+			}{
+				{[]string{"alice"}, []string{"a", "t"}},
+				{[]string{"bob"}, []string{"b", "t"}},
+				{[]string{"alice", "bob"}, []string{"a", "b", "t"}},
+				{[]string{"mallory"}, nil},
+				{nil, []string{"a", "b", "t", "n"}},
+			}
+			for _, tt := range tests {
+				if got := search(tt.permissions...); !slices.Equal(got, tt.want) {
+					t.Errorf("search with permissions %v found %v, want %v", tt.permissions, got, tt.want)
+				}
+			}
+
+			// Updates that take a permission away or grant one apply at once
+			updates := []*Item{
+				{ID: "a", DocumentID: "a", Vector: []float32{1, 0}, Permissions: []string{"bob"}},
+				{ID: "t", DocumentID: "t", Vector: []float32{0.8, 0.2}, Permissions: []string{"bob"}},
+				{ID: "n", DocumentID: "n", Vector: []float32{0.7, 0.3}, Permissions: []string{"alice"}},
+			}
+			for _, item := range updates {
+				if err := store.Store(ctx, item); err != nil {
+					t.Fatalf("Store failed: %v", err)
+				}
+			}
+			if got := search("alice"); !slices.Equal(got, []string{"n"}) {
+				t.Errorf("search with alice's permissions after the update found %v, want [n]", got)
+			}
+			if got := search("bob"); !slices.Equal(got, []string{"a", "b", "t"}) {
+				t.Errorf("search with bob's permissions after the update found %v, want [a b t]", got)
+			}
+
+			// Changing the retention of a document keeps its permissions
+			if err := store.SetDocumentRetention(ctx, "", "n", Retention{Policy: RetentionForever}, time.Time{}); err != nil {
+				t.Fatalf("SetDocumentRetention failed: %v", err)
+			}
+			if err := store.DeleteDocument(ctx, "", "b"); err != nil {
+				t.Fatalf("DeleteDocument failed: %v", err)
+			}
+			if got := search("alice", "bob"); !slices.Equal(got, []string{"a", "t", "n"}) {
+				t.Errorf("search with both permissions found %v, want [a t n]", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,552 @@
+package vectorstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// On-disk layout of a persistent in-memory store:
+//
+//	snapshot.dat            full copy of the collections and items at some point in time
+//	wal-<sequence>.log      mutations made after that snapshot, in order
+//
+// Both files are sequences of framed records. Each frame is a 4-byte
+// little-endian payload length, a 4-byte CRC-32C of the payload and the
+// gob-encoded payload itself. The snapshot starts with a magic string and
+// a header record naming the first WAL segment it does not cover and
+// listing the collections.
+
+const (
+	snapshotFileName = "snapshot.dat"
+	snapshotTempName = "snapshot.dat.tmp"
+	snapshotMagic    = "VSSNAP01"
+	snapshotVersion  = 2
+	walFilePrefix    = "wal-"
+	walFileSuffix    = ".log"
+	frameHeaderSize  = 8
+	maxFrameSize     = 64 << 20
+
+	defaultSnapshotInterval = 5 * time.Minute
+)
+
+// WAL operations
+const (
+	walOpPut              byte = 1
+	walOpDelete           byte = 2
+	walOpDeleteDocument   byte = 3
+	walOpReplaceDocument  byte = 4
+	walOpCreateCollection byte = 5
+	walOpDropCollection   byte = 6
+	walOpPutBatch         byte = 7
+	walOpSetRetention     byte = 8
+)
+
+// Error definitions
+var (
+	ErrCorruptRecord  = errors.New("corrupt record")
+	ErrRecordTooLarge = errors.New("record too large")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// walRecord is a single mutation in the write-ahead log.
+// ID is an item ID for deletes and a document ID for document operations.
+// Records written before collections existed have an empty Collection.
+type walRecord struct {
+	Op         byte
+	Collection string
+	Item       *Item
+	ID         string
+	Items      []*Item
+	Config     *CollectionConfig
+	Retention  *Retention
+	ExpiresAt  time.Time
+}
+
+// snapshotHeader is the first record of a snapshot file
+type snapshotHeader struct {
+	Version     int
+	WALSequence uint64
+	Count       int
+	Collections []*CollectionConfig
+}
+
+// storeState is the content recovered from disk. Items are grouped by
+// collection name; the empty name holds items written before collections.
+type storeState struct {
+	collections map[string]*CollectionConfig
+	items       map[string]map[string]*Item
+}
+
+// newStoreState creates an empty state
+func newStoreState() *storeState {
+	return &storeState{
+		collections: make(map[string]*CollectionConfig),
+		items:       make(map[string]map[string]*Item),
+	}
+}
+
+// collectionItems returns the items of a collection, creating the map if needed
+func (st *storeState) collectionItems(name string) map[string]*Item {
+	items, exists := st.items[name]
+	if !exists {
+		items = make(map[string]*Item)
+		st.items[name] = items
+	}
+	return items
+}
+
+// persister owns the WAL and snapshot files of a store
+type persister struct {
+	dir      string
+	sync     bool
+	lock     sync.Mutex // guards wal and sequence against concurrent writers
+	wal      *os.File
+	sequence uint64
+}
+
+// openPersister recovers the collections and items stored in dir and opens
+// a fresh WAL segment for new writes
+func openPersister(dir string, syncWrites bool) (*persister, *storeState, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	p := &persister{dir: dir, sync: syncWrites}
+
+	state, startSequence, err := p.readSnapshot()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	segments, err := p.segments()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	next := startSequence
+	for i, sequence := range segments {
+		if sequence < startSequence {
+			// Already covered by the snapshot
+			_ = os.Remove(p.segmentPath(sequence))
+			continue
+		}
+
+		// Only the newest segment may end in a torn write
+		last := i == len(segments)-1
+		if err := p.replaySegment(sequence, state, last); err != nil {
+			return nil, nil, err
+		}
+		next = sequence + 1
+	}
+
+	if err := p.openSegment(next); err != nil {
+		return nil, nil, err
+	}
+
+	return p, state, nil
+}
+
+// append writes a record to the current WAL segment. Records larger than
+// recovery can read back are rejected with ErrRecordTooLarge, before
+// anything is written.
+func (p *persister) append(record *walRecord) error {
+	frame, err := encodeFrame(record)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, err := p.wal.Write(frame); err != nil {
+		return fmt.Errorf("failed to append to write-ahead log: %w", err)
+	}
+
+	if p.sync {
+		if err := p.wal.Sync(); err != nil {
+			return fmt.Errorf("failed to sync write-ahead log: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// rotate closes the current WAL segment and starts the next one.
+// It returns the sequence number of the new segment.
+func (p *persister) rotate() (uint64, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := p.closeSegment(); err != nil {
+		return 0, err
+	}
+
+	if err := p.openSegment(p.sequence + 1); err != nil {
+		return 0, err
+	}
+
+	return p.sequence, nil
+}
+
+// writeSnapshot atomically replaces the snapshot with the given collections
+// and items and removes the WAL segments it makes redundant
+func (p *persister) writeSnapshot(collections []*CollectionConfig, items []*Item, walSequence uint64) error {
+	tempPath := filepath.Join(p.dir, snapshotTempName)
+
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	err = writeSnapshotTo(writer, collections, items, walSequence)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := os.Rename(tempPath, filepath.Join(p.dir, snapshotFileName)); err != nil {
+		return fmt.Errorf("failed to install snapshot: %w", err)
+	}
+	if err := syncDir(p.dir); err != nil {
+		return err
+	}
+
+	// Segments before the snapshot's sequence are no longer needed
+	segments, err := p.segments()
+	if err != nil {
+		return err
+	}
+	for _, sequence := range segments {
+		if sequence < walSequence {
+			os.Remove(p.segmentPath(sequence))
+		}
+	}
+
+	return nil
+}
+
+// close closes the current WAL segment
+func (p *persister) close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.closeSegment()
+}
+
+// readSnapshot loads the snapshot if there is one. It returns the state and
+// the first WAL sequence to replay on top of it.
+func (p *persister) readSnapshot() (*storeState, uint64, error) {
+	state := newStoreState()
+
+	file, err := os.Open(filepath.Join(p.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != snapshotMagic {
+		return nil, 0, fmt.Errorf("snapshot: %w: bad magic", ErrCorruptRecord)
+	}
+
+	var header snapshotHeader
+	if err := readFrame(reader, &header); err != nil {
+		return nil, 0, fmt.Errorf("snapshot header: %w", err)
+	}
+	// Version 1 snapshots predate collections and decode the same way
+	if header.Version < 1 || header.Version > snapshotVersion {
+		return nil, 0, fmt.Errorf("unsupported snapshot version %d", header.Version)
+	}
+
+	for _, config := range header.Collections {
+		state.collections[config.Name] = config
+	}
+
+	for i := 0; i < header.Count; i++ {
+		var item Item
+		if err := readFrame(reader, &item); err != nil {
+			return nil, 0, fmt.Errorf("snapshot item %d: %w", i, err)
+		}
+		state.collectionItems(item.Collection)[item.ID] = &item
+	}
+
+	return state, header.WALSequence, nil
+}
+
+// replaySegment applies a WAL segment to state. A damaged tail of the last
+// segment is the result of a crash mid-write; it is truncated away.
+func (p *persister) replaySegment(sequence uint64, state *storeState, last bool) error {
+	path := p.segmentPath(sequence)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+	defer file.Close()
+
+	reader := &countingReader{r: bufio.NewReader(file)}
+	var offset int64
+
+	for {
+		var record walRecord
+		err := readFrame(reader, &record)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if !last {
+				return fmt.Errorf("write-ahead log %s at offset %d: %w", filepath.Base(path), offset, err)
+			}
+			if err := os.Truncate(path, offset); err != nil {
+				return fmt.Errorf("failed to truncate damaged write-ahead log: %w", err)
+			}
+			return nil
+		}
+
+		record.apply(state)
+
+		offset = reader.n
+	}
+}
+
+// apply replays the record onto the recovered state
+func (r *walRecord) apply(state *storeState) {
+	switch r.Op {
+	case walOpCreateCollection:
+		state.collections[r.Collection] = r.Config
+	case walOpDropCollection:
+		delete(state.collections, r.Collection)
+		delete(state.items, r.Collection)
+	case walOpPut:
+		state.collectionItems(r.Collection)[r.Item.ID] = r.Item
+	case walOpPutBatch:
+		items := state.collectionItems(r.Collection)
+		for _, item := range r.Items {
+			items[item.ID] = item
+		}
+	case walOpDelete:
+		delete(state.items[r.Collection], r.ID)
+	case walOpDeleteDocument, walOpReplaceDocument:
+		items := state.collectionItems(r.Collection)
+		for id, item := range items {
+			if item.DocumentID == r.ID {
+				delete(items, id)
+			}
+		}
+		for _, item := range r.Items {
+			items[item.ID] = item
+		}
+	case walOpSetRetention:
+		items := state.items[r.Collection]
+		for id, item := range items {
+			if item.DocumentID == r.ID {
+				items[id] = item.withRetention(*r.Retention, r.ExpiresAt)
+			}
+		}
+	}
+}
+
+// segments lists the sequence numbers of the WAL segments on disk, oldest first
+func (p *persister) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data directory: %w", err)
+	}
+
+	var sequences []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, walFilePrefix) || !strings.HasSuffix(name, walFileSuffix) {
+			continue
+		}
+
+		number := strings.TrimSuffix(strings.TrimPrefix(name, walFilePrefix), walFileSuffix)
+		sequence, err := strconv.ParseUint(number, 10, 64)
+		if err != nil {
+			continue
+		}
+		sequences = append(sequences, sequence)
+	}
+
+	sort.Slice(sequences, func(i, j int) bool {
+		return sequences[i] < sequences[j]
+	})
+
+	return sequences, nil
+}
+
+// openSegment opens the WAL segment with the given sequence for appending
+func (p *persister) openSegment(sequence uint64) error {
+	file, err := os.OpenFile(p.segmentPath(sequence), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+
+	p.wal = file
+	p.sequence = sequence
+
+	return syncDir(p.dir)
+}
+
+// closeSegment syncs and closes the current WAL segment
+func (p *persister) closeSegment() error {
+	if p.wal == nil {
+		return nil
+	}
+
+	err := p.wal.Sync()
+	if closeErr := p.wal.Close(); err == nil {
+		err = closeErr
+	}
+	p.wal = nil
+
+	return err
+}
+
+// segmentPath returns the file path of a WAL segment
+func (p *persister) segmentPath(sequence uint64) string {
+	return filepath.Join(p.dir, fmt.Sprintf("%s%020d%s", walFilePrefix, sequence, walFileSuffix))
+}
+
+// writeSnapshotTo writes a complete snapshot to w
+func writeSnapshotTo(w io.Writer, collections []*CollectionConfig, items []*Item, walSequence uint64) error {
+	if _, err := io.WriteString(w, snapshotMagic); err != nil {
+		return err
+	}
+
+	header := &snapshotHeader{
+		Version:     snapshotVersion,
+		WALSequence: walSequence,
+		Count:       len(items),
+		Collections: collections,
+	}
+	if err := writeFrame(w, header); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := writeFrame(w, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFrame encodes v and writes it as one framed record
+func writeFrame(w io.Writer, v interface{}) error {
+	frame, err := encodeFrame(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(frame)
+	return err
+}
+
+// encodeFrame gob-encodes v and prefixes it with its length and checksum
+func encodeFrame(v interface{}) ([]byte, error) {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+	if payload.Len() > maxFrameSize {
+		return nil, fmt.Errorf("%w: %d bytes encoded, at most %d can be read back", ErrRecordTooLarge, payload.Len(), maxFrameSize)
+	}
+
+	frame := make([]byte, frameHeaderSize+payload.Len())
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(payload.Len()))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload.Bytes(), crcTable))
+	copy(frame[frameHeaderSize:], payload.Bytes())
+
+	return frame, nil
+}
+
+// readFrame reads one framed record, verifies its checksum and decodes it
+// into v. It returns io.EOF only at a clean record boundary.
+func readFrame(r io.Reader, v interface{}) error {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		return fmt.Errorf("%w: truncated header", ErrCorruptRecord)
+	}
+
+	length := binary.LittleEndian.Uint32(header[0:4])
+	checksum := binary.LittleEndian.Uint32(header[4:8])
+	if length > maxFrameSize {
+		return fmt.Errorf("%w: record length %d", ErrCorruptRecord, length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return fmt.Errorf("%w: truncated payload", ErrCorruptRecord)
+	}
+
+	if crc32.Checksum(payload, crcTable) != checksum {
+		return fmt.Errorf("%w: checksum mismatch", ErrCorruptRecord)
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorruptRecord, err)
+	}
+
+	return nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// syncDir flushes directory entries so that created and renamed files survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open data directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync data directory: %w", err)
+	}
+
+	return nil
+}
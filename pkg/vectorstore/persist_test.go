@@ -0,0 +1,152 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWALRecovery(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewMemoryStore(&Config{VectorSize: 2, DataDir: dir})
+	if err != nil {
+		t.Fatalf("NewMemoryStore failed: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.CreateCollection(ctx, &CollectionConfig{Name: "docs"}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	// Half of the writes are covered by a snapshot, the rest only by the log
+	for i := 0; i < 4; i++ {
+		if i == 2 {
+			if err := store.Snapshot(); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+		}
+		documentID := fmt.Sprintf("doc-%d", i)
+		items := []*Item{
+			{ID: documentID + "-0", DocumentID: documentID, Vector: []float32{1, 0}},
+			{ID: documentID + "-1", DocumentID: documentID, Vector: []float32{0, 1}},
+		}
+		if err := store.StoreBatch(ctx, "docs", items); err != nil {
+			t.Fatalf("StoreBatch failed: %v", err)
+		}
+	}
+	if err := store.Delete(ctx, "docs", "doc-1-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.DeleteDocument(ctx, "docs", "doc-2"); err != nil {
+		t.Fatalf("DeleteDocument failed: %v", err)
+	}
+	replacement := []*Item{{ID: "doc-3-new", DocumentID: "doc-3", Vector: []float32{1, 1}}}
+	if err := store.ReplaceDocument(ctx, "docs", "doc-3", replacement); err != nil {
+		t.Fatalf("ReplaceDocument failed: %v", err)
+	}
+
+	// A copy of the directory is what a crash would leave behind, with a
+	// write torn off at the end of the log
+	crashed := copyDir(t, dir)
+	segments, err := filepath.Glob(filepath.Join(crashed, walFilePrefix+"*"+walFileSuffix))
+	if err != nil || len(segments) == 0 {
+		t.Fatalf("no write-ahead log in %s: %v", crashed, err)
+	}
+	last := segments[len(segments)-1]
+	log, err := os.OpenFile(last, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open write-ahead log: %v", err)
+	}
+	log.Write([]byte{0xff, 0xff, 0, 0, 1, 2})
+	log.Close()
+
+	recovered, err := NewMemoryStore(&Config{VectorSize: 2, DataDir: crashed})
+	if err != nil {
+		t.Fatalf("recovery failed: %v", err)
+	}
+	defer recovered.Close()
+
+	want := map[string][]string{
+		"doc-0": {"doc-0-0", "doc-0-1"},
+		"doc-1": {"doc-1-0"},
+		"doc-2": nil,
+		"doc-3": {"doc-3-new"},
+	}
+	for documentID, ids := range want {
+		chunks, err := recovered.GetDocument(ctx, "docs", documentID)
+		if err != nil {
+			t.Fatalf("GetDocument failed: %v", err)
+		}
+		var got []string
+		for _, chunk := range chunks {
+			got = append(got, chunk.ID)
+		}
+		if strings.Join(got, ",") != strings.Join(ids, ",") {
+			t.Errorf("recovered %s as %v, want %v", documentID, got, ids)
+		}
+	}
+
+	// The torn write was truncated away and new writes are logged
+	if err := recovered.Store(ctx, &Item{ID: "doc-4-0", DocumentID: "doc-4", Collection: "docs", Vector: []float32{1, 0}}); err != nil {
+		t.Fatalf("Store after recovery failed: %v", err)
+	}
+}
+
+func TestWALRejectsOversizedRecords(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewMemoryStore(&Config{VectorSize: 2, DataDir: dir})
+	if err != nil {
+		t.Fatalf("NewMemoryStore failed: %v", err)
+	}
+
+	large := &Item{ID: "large", DocumentID: "large", Vector: []float32{1, 0}, Content: strings.Repeat("x", maxFrameSize)}
+	if err := store.Store(ctx, large); !errors.Is(err, ErrRecordTooLarge) {
+		t.Fatalf("Store of an oversized item returned %v, want ErrRecordTooLarge", err)
+	}
+	if item, err := store.Get(ctx, "", "large"); err == nil {
+		t.Errorf("oversized item %s was stored", item.ID)
+	}
+
+	small := &Item{ID: "small", DocumentID: "small", Vector: []float32{1, 0}}
+	if err := store.Store(ctx, small); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	// The log stays readable past the rejected record
+	recovered, err := NewMemoryStore(&Config{VectorSize: 2, DataDir: copyDir(t, dir)})
+	if err != nil {
+		t.Fatalf("recovery failed: %v", err)
+	}
+	defer recovered.Close()
+	store.Close()
+
+	if _, err := recovered.Get(ctx, "", "small"); err != nil {
+		t.Errorf("item stored after the rejected one was lost: %v", err)
+	}
+}
+
+// copyDir copies the files of a directory into a new temporary directory
+func copyDir(t *testing.T, dir string) string {
+	t.Helper()
+
+	target := t.TempDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", dir, err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", entry.Name(), err)
+		}
+		if err := os.WriteFile(filepath.Join(target, entry.Name()), data, 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", entry.Name(), err)
+		}
+	}
+	return target
+}
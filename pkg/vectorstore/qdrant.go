@@ -1,201 +1,801 @@
 package vectorstore
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
+)
+
+// Default settings for the Qdrant backend
+const (
+	defaultQdrantAddress = "http://localhost:6333"
+	defaultQdrantTimeout = 30 * time.Second
+	qdrantScrollPageSize = 256
 )
 
-// Config contains configuration for the vector store
-type Config struct {
-	InMemory   bool
-	Address    string
-	Collection string
-	VectorSize int
-	TTL        time.Duration
+// qdrantDistances maps collection metrics onto Qdrant distance names
+var qdrantDistances = map[Distance]string{
+	DistanceCosine: "Cosine",
+	DistanceDot:    "Dot",
+	DistanceEuclid: "Euclid",
 }
 
-// Item represents a stored vector item
-type Item struct {
-	ID          string
-	Vector      []float32
-	DocumentID  string
-	Content     string
-	Title       string
-	Metadata    map[string]string
-	Permissions []string
-	ExpiresAt   time.Time
+// QdrantStore stores vectors in a Qdrant server using its REST API.
+// Several service replicas can share the same collection; the change feed
+// of each replica covers only the writes it made.
+type QdrantStore struct {
+	config      *Config
+	baseURL     string
+	defaultName string
+	configs     map[string]CollectionConfig // Settings of collections created or written by this store
+	distances   map[string]Distance         // Metrics of collections seen by this store
+	httpClient  *http.Client
+	changes     *changeFeed // nil when disabled
+	lock        sync.RWMutex
+	closeChan   chan struct{}
+	closed      bool
 }
 
-// SearchParams contains parameters for search operations
-type SearchParams struct {
-	Vector           []float32
-	Limit            int
-	PermissionFilter []string
+// qdrantPoint is a point as accepted and returned by the Qdrant API
+type qdrantPoint struct {
+	ID      string        `json:"id"`
+	Vector  []float32     `json:"vector,omitempty"`
+	Payload qdrantPayload `json:"payload"`
 }
 
-// SearchResult represents a search result
-type SearchResult struct {
-	ID         string
-	DocumentID string
-	Content    string
-	Title      string
-	Metadata   map[string]string
-	Score      float64
+// qdrantPayload holds the item fields stored alongside each vector
+type qdrantPayload struct {
+	ItemID          string            `json:"item_id"`
+	DocumentID      string            `json:"document_id"`
+	Content         string            `json:"content"`
+	Title           string            `json:"title"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Permissions     []string          `json:"permissions,omitempty"`
+	ExpiresAt       int64             `json:"expires_at"`
+	RetentionPolicy RetentionPolicy   `json:"retention_policy,omitempty"`
+	RetentionTTL    int64             `json:"retention_ttl,omitempty"` // seconds
+	Pinned          bool              `json:"pinned,omitempty"`
+	Model           string            `json:"model,omitempty"`
+	ModelVersion    int               `json:"model_version"`
+	// MetadataNumeric repeats numeric metadata values so that Qdrant can
+	// apply range filters to them
+	MetadataNumeric map[string]float64 `json:"metadata_numeric,omitempty"`
 }
 
-// scoredItem represents an item with its similarity score
-type scoredItem struct {
-	item  *Item
-	score float64
+// qdrantScoredPoint is a single hit of a Qdrant search
+type qdrantScoredPoint struct {
+	ID      string        `json:"id"`
+	Score   float64       `json:"score"`
+	Payload qdrantPayload `json:"payload"`
+	Vector  []float32     `json:"vector,omitempty"`
 }
 
-// QdrantStore provides vector storage using Qdrant
-// For POC, we'll implement a simple in-memory version
-type QdrantStore struct {
-	config    *Config
-	items     map[string]*Item
-	lock      sync.RWMutex
-	closeChan chan struct{}
-	closed    bool
+// qdrantCollectionInfo is the part of a collection description we use
+type qdrantCollectionInfo struct {
+	PointsCount int `json:"points_count"`
+	Config      struct {
+		Params struct {
+			Vectors struct {
+				Size     int    `json:"size"`
+				Distance string `json:"distance"`
+			} `json:"vectors"`
+		} `json:"params"`
+	} `json:"config"`
+}
+
+// qdrantResponse is the envelope of every Qdrant API response
+type qdrantResponse struct {
+	Result json.RawMessage `json:"result"`
+	Status interface{}     `json:"status"`
+}
+
+// qdrantStatusError is returned when Qdrant answers with a non-2xx status
+type qdrantStatusError struct {
+	StatusCode int
+	Body       string
 }
 
-// NewQdrantStore creates a new Qdrant store
-func NewQdrantStore(config *Config) *QdrantStore {
+func (e *qdrantStatusError) Error() string {
+	return fmt.Sprintf("qdrant request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// NewQdrantStore connects to Qdrant and makes sure the default collection exists
+func NewQdrantStore(config *Config) (*QdrantStore, error) {
+	if config.VectorSize <= 0 {
+		return nil, fmt.Errorf("qdrant backend requires a positive vector size, got %d", config.VectorSize)
+	}
+	if config.Reduction.Method != ReductionNone {
+		return nil, fmt.Errorf("qdrant backend does not support %s reduction", config.Reduction.Method)
+	}
+
+	address := config.Address
+	if address == "" {
+		address = defaultQdrantAddress
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultQdrantTimeout
+	}
+
+	defaults := defaultCollectionConfig(config)
+	if err := defaults.validate(); err != nil {
+		return nil, err
+	}
+
 	store := &QdrantStore{
-		config:    config,
-		items:     make(map[string]*Item),
+		config:      config,
+		baseURL:     strings.TrimRight(address, "/"),
+		defaultName: defaults.Name,
+		configs:     map[string]CollectionConfig{defaults.Name: *defaults},
+		distances:   make(map[string]Distance),
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+		changes:   newChangeFeed(config),
 		closeChan: make(chan struct{}),
 	}
 
-	// Start cleanup goroutine for expired items
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := store.CreateCollection(ctx, defaults)
+	if err != nil && !errors.Is(err, ErrCollectionExists) {
+		return nil, err
+	}
+
+	// Qdrant has no native expiry, so remove expired points ourselves
 	go store.cleanupRoutine()
 
-	return store
+	return store, nil
 }
 
-// Store adds or updates a vector in the store
-func (s *QdrantStore) Store(ctx context.Context, item *Item) error {
+// CreateCollection creates a Qdrant collection. A zero vector size uses
+// Config.VectorSize, as Qdrant needs a fixed dimension.
+func (s *QdrantStore) CreateCollection(ctx context.Context, config *CollectionConfig) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	collectionConfig := *config
+	if err := collectionConfig.validate(); err != nil {
+		return err
+	}
+	if collectionConfig.VectorSize == 0 {
+		collectionConfig.VectorSize = s.config.VectorSize
+	}
+
+	err := s.request(ctx, http.MethodGet, s.collectionPath(collectionConfig.Name, ""), nil, nil)
+	if err == nil {
+		return fmt.Errorf("collection %s: %w", collectionConfig.Name, ErrCollectionExists)
+	}
+	if !isQdrantNotFound(err) {
+		return fmt.Errorf("failed to check qdrant collection %s: %w", collectionConfig.Name, err)
+	}
+
+	body := map[string]interface{}{
+		"vectors": map[string]interface{}{
+			"size":     collectionConfig.VectorSize,
+			"distance": qdrantDistances[collectionConfig.Distance],
+		},
+	}
+	if err := s.request(ctx, http.MethodPut, s.collectionPath(collectionConfig.Name, ""), body, nil); err != nil {
+		return fmt.Errorf("failed to create qdrant collection %s: %w", collectionConfig.Name, err)
+	}
+
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	s.configs[collectionConfig.Name] = collectionConfig
+	s.distances[collectionConfig.Name] = collectionConfig.Distance
+	s.lock.Unlock()
 
-	if s.closed {
-		return errors.New("store is closed")
+	s.changes.publish(Change{Type: ChangeCreateCollection, Collection: collectionConfig.Name})
+
+	return nil
+}
+
+// ListCollections describes every Qdrant collection, sorted by name
+func (s *QdrantStore) ListCollections(ctx context.Context) ([]*CollectionInfo, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	names, err := s.collectionNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*CollectionInfo, 0, len(names))
+	for _, name := range names {
+		var details qdrantCollectionInfo
+		if err := s.request(ctx, http.MethodGet, s.collectionPath(name, ""), nil, &details); err != nil {
+			return nil, fmt.Errorf("failed to get qdrant collection %s: %w", name, err)
+		}
+
+		vectors := details.Config.Params.Vectors
+		config := s.collectionConfig(name)
+		info := &CollectionInfo{
+			CollectionConfig: CollectionConfig{
+				Name:       name,
+				VectorSize: vectors.Size,
+				Distance:   distanceFromQdrant(vectors.Distance),
+				TTL:        config.TTL,
+				Sliding:    config.Sliding,
+			},
+			CollectionStats: CollectionStats{
+				Items:       details.PointsCount,
+				VectorBytes: 4 * int64(details.PointsCount) * int64(vectors.Size),
+			},
+		}
+		infos = append(infos, info)
+
+		s.lock.Lock()
+		s.distances[name] = info.Distance
+		s.lock.Unlock()
 	}
 
-	// Add the item
-	s.items[item.ID] = item
+	return infos, nil
+}
+
+// DropCollection deletes a Qdrant collection and all of its points.
+// The default collection cannot be dropped.
+func (s *QdrantStore) DropCollection(ctx context.Context, name string) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	if name == s.defaultName {
+		return fmt.Errorf("%w: cannot drop the default collection %s", ErrInvalidCollection, name)
+	}
+
+	var deleted bool
+	if err := s.request(ctx, http.MethodDelete, s.collectionPath(name, ""), nil, &deleted); err != nil {
+		return fmt.Errorf("failed to drop qdrant collection %s: %w", name, err)
+	}
+	if !deleted {
+		return fmt.Errorf("collection %s: %w", name, ErrCollectionNotFound)
+	}
+
+	s.lock.Lock()
+	delete(s.configs, name)
+	delete(s.distances, name)
+	s.lock.Unlock()
+
+	s.changes.publish(Change{Type: ChangeDropCollection, Collection: name})
 
 	return nil
 }
 
-// Get retrieves a vector by ID
-func (s *QdrantStore) Get(ctx context.Context, id string) (*Item, error) {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+// Store adds or updates a vector in the item's collection
+func (s *QdrantStore) Store(ctx context.Context, item *Item) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
 
-	if s.closed {
-		return nil, errors.New("store is closed")
+	name := s.collectionName(item.Collection)
+	config, err := s.writeConfig(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := config.check(item); err != nil {
+		return err
+	}
+	item = config.prepare(item, time.Now())
+
+	body := map[string]interface{}{
+		"points": []qdrantPoint{{
+			ID:      pointID(item.ID),
+			Vector:  item.Vector,
+			Payload: payloadFromItem(item),
+		}},
+	}
+
+	if err := s.request(ctx, http.MethodPut, s.collectionPath(name, "/points?wait=true"), body, nil); err != nil {
+		return err
+	}
+
+	s.changes.publish(documentChanges(ChangeStore, name, []*Item{item})...)
+
+	return nil
+}
+
+// StoreBatch upserts several vectors into one collection with a single
+// request, which Qdrant applies as one operation
+func (s *QdrantStore) StoreBatch(ctx context.Context, collection string, items []*Item) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	name := s.collectionName(collection)
+	config, err := s.writeConfig(ctx, name)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	points := make([]qdrantPoint, len(items))
+	prepared := make([]*Item, len(items))
+	for i, item := range items {
+		if err := config.check(item); err != nil {
+			return fmt.Errorf("item %s: %w", item.ID, err)
+		}
+		item = config.prepare(item, now)
+		prepared[i] = item
+		points[i] = qdrantPoint{
+			ID:      pointID(item.ID),
+			Vector:  item.Vector,
+			Payload: payloadFromItem(item),
+		}
+	}
+
+	body := map[string]interface{}{"points": points}
+	if err := s.request(ctx, http.MethodPut, s.collectionPath(name, "/points?wait=true"), body, nil); err != nil {
+		return err
+	}
+
+	s.changes.publish(documentChanges(ChangeStore, name, prepared)...)
+
+	return nil
+}
+
+// Get retrieves a vector by ID
+func (s *QdrantStore) Get(ctx context.Context, collection, id string) (*Item, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
 	}
 
-	item, exists := s.items[id]
-	if !exists {
-		return nil, fmt.Errorf("item with ID %s not found", id)
+	name := s.collectionName(collection)
+	point, err := s.getPoint(ctx, name, id)
+	if err != nil {
+		return nil, err
 	}
 
+	item := itemFromPayload(name, point.Payload)
+	item.Vector = point.Vector
+
 	// Check expiration
-	if !item.ExpiresAt.IsZero() && time.Now().After(item.ExpiresAt) {
+	now := time.Now()
+	if item.isExpired(now) {
 		return nil, fmt.Errorf("item with ID %s has expired", id)
 	}
 
+	if item.needsRefresh(now) {
+		s.touch(ctx, name, []*Item{item}, now)
+		item.ExpiresAt = item.Retention.expiry(now)
+	}
+
 	return item, nil
 }
 
 // Delete removes a vector from the store
-func (s *QdrantStore) Delete(ctx context.Context, id string) error {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+func (s *QdrantStore) Delete(ctx context.Context, collection, id string) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
 
-	if s.closed {
-		return errors.New("store is closed")
+	name := s.collectionName(collection)
+
+	// Look the point up first, as the change names its document
+	point, err := s.getPoint(ctx, name, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"points": []string{pointID(id)},
+	}
+	if err := s.request(ctx, http.MethodPost, s.collectionPath(name, "/points/delete?wait=true"), body, nil); err != nil {
+		return err
 	}
 
-	delete(s.items, id)
+	s.changes.publish(Change{Type: ChangeDelete, Collection: name, DocumentID: point.Payload.DocumentID, IDs: []string{id}})
 
 	return nil
 }
 
-// Search performs vector similarity search
-func (s *QdrantStore) Search(ctx context.Context, params *SearchParams) ([]*SearchResult, error) {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+// getPoint fetches the point of an item. Qdrant answers 404 both for a
+// missing point and for a missing collection, so the collection is checked
+// before reporting the item as not found.
+func (s *QdrantStore) getPoint(ctx context.Context, name, id string) (*qdrantPoint, error) {
+	var point qdrantPoint
+	err := s.request(ctx, http.MethodGet, s.collectionPath(name, "/points/"+pointID(id)), nil, &point)
+	if err == nil {
+		return &point, nil
+	}
+	if !isQdrantNotFound(err) {
+		return nil, err
+	}
 
-	if s.closed {
-		return nil, errors.New("store is closed")
+	if _, err := s.collectionDistance(ctx, name); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("item with ID %s: %w", id, ErrNotFound)
+}
+
+// GetDocument returns the live chunks stored for a document
+func (s *QdrantStore) GetDocument(ctx context.Context, collection, documentID string) ([]*Item, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	name := s.collectionName(collection)
+	now := time.Now()
+	filter := map[string]interface{}{
+		"must":     []interface{}{documentCondition(documentID)},
+		"must_not": []interface{}{expiredCondition(now)},
+	}
+
+	points, err := s.scrollPoints(ctx, name, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*Item, len(points))
+	var due []*Item
+	for i, point := range points {
+		items[i] = itemFromPayload(name, point.Payload)
+		items[i].Vector = point.Vector
+		if items[i].needsRefresh(now) {
+			due = append(due, items[i])
+		}
+	}
+	if len(due) > 0 {
+		s.touch(ctx, name, due, now)
+		for _, item := range items {
+			if item.Retention.Policy == RetentionSliding {
+				item.ExpiresAt = item.Retention.expiry(now)
+			}
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+
+	return items, nil
+}
+
+// DeleteDocument removes every chunk of a document
+func (s *QdrantStore) DeleteDocument(ctx context.Context, collection, documentID string) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"filter": map[string]interface{}{
+			"must": []interface{}{documentCondition(documentID)},
+		},
+	}
+
+	name := s.collectionName(collection)
+	if err := s.request(ctx, http.MethodPost, s.collectionPath(name, "/points/delete?wait=true"), body, nil); err != nil {
+		return err
+	}
+
+	s.changes.publish(Change{Type: ChangeDelete, Collection: name, DocumentID: documentID})
+
+	return nil
+}
+
+// ReplaceDocument replaces all chunks of a document with items.
+// The new chunks are written before the stale ones are removed, so the
+// document never disappears from search; a failure between the two steps
+// leaves stale chunks that the next replace removes.
+func (s *QdrantStore) ReplaceDocument(ctx context.Context, collection, documentID string, items []*Item) error {
+	if err := s.checkOpen(); err != nil {
+		return err
 	}
 
-	// For POC, we'll implement a simple cosine similarity search
-	var scored []scoredItem
+	if err := checkDocumentItems(documentID, items); err != nil {
+		return err
+	}
+
+	name := s.collectionName(collection)
+	config, err := s.writeConfig(ctx, name)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	points := make([]qdrantPoint, len(items))
+	keep := make([]string, len(items))
+	for i, item := range items {
+		if err := config.check(item); err != nil {
+			return fmt.Errorf("item %s: %w", item.ID, err)
+		}
+		item = config.prepare(item, now)
+		points[i] = qdrantPoint{
+			ID:      pointID(item.ID),
+			Vector:  item.Vector,
+			Payload: payloadFromItem(item),
+		}
+		keep[i] = points[i].ID
+	}
+
+	if len(points) > 0 {
+		body := map[string]interface{}{"points": points}
+		if err := s.request(ctx, http.MethodPut, s.collectionPath(name, "/points?wait=true"), body, nil); err != nil {
+			return err
+		}
+	}
+
+	filter := map[string]interface{}{
+		"must": []interface{}{documentCondition(documentID)},
+	}
+	if len(keep) > 0 {
+		filter["must_not"] = []interface{}{map[string]interface{}{"has_id": keep}}
+	}
+
+	body := map[string]interface{}{"filter": filter}
+	if err := s.request(ctx, http.MethodPost, s.collectionPath(name, "/points/delete?wait=true"), body, nil); err != nil {
+		return err
+	}
+
+	change := Change{Type: ChangeReplace, Collection: name, DocumentID: documentID}
+	for _, item := range items {
+		change.IDs = append(change.IDs, item.ID)
+	}
+	s.changes.publish(change)
+
+	return nil
+}
+
+// SetDocumentRetention changes the retention and expiry of every chunk of
+// a document with a single payload update
+func (s *QdrantStore) SetDocumentRetention(ctx context.Context, collection, documentID string, retention Retention, expiresAt time.Time) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	name := s.collectionName(collection)
+	now := time.Now()
+	retention, expiresAt, err := s.collectionConfig(name).documentExpiry(retention, expiresAt, now)
+	if err != nil {
+		return err
+	}
+
+	filter := map[string]interface{}{
+		"must":     []interface{}{documentCondition(documentID)},
+		"must_not": []interface{}{expiredCondition(now)},
+	}
+	points, _, err := s.scrollPage(ctx, name, filter, 1, nil)
+	if err != nil {
+		if isQdrantNotFound(err) {
+			return fmt.Errorf("collection %s: %w", name, ErrCollectionNotFound)
+		}
+		return err
+	}
+	if len(points) == 0 {
+		return fmt.Errorf("document %s: %w", documentID, ErrNotFound)
+	}
+
+	body := map[string]interface{}{
+		"payload": retentionPayload(retention, expiresAt),
+		"filter": map[string]interface{}{
+			"must": []interface{}{documentCondition(documentID)},
+		},
+	}
+	if err := s.request(ctx, http.MethodPost, s.collectionPath(name, "/points/payload?wait=true"), body, nil); err != nil {
+		return err
+	}
+
+	s.changes.publish(Change{Type: ChangeRetention, Collection: name, DocumentID: documentID})
+
+	return nil
+}
+
+// Scroll returns a page of live points in Qdrant's point order. The offset
+// is the ID of the point that starts the next page.
+func (s *QdrantStore) Scroll(ctx context.Context, params *ScrollParams) (*ScrollPage, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	name := s.collectionName(params.Collection)
+	filter := searchFilter(&SearchParams{Filter: params.Filter}, time.Now())
+	if params.DocumentID != "" {
+		must, _ := filter["must"].([]interface{})
+		filter["must"] = append(must, documentCondition(params.DocumentID))
+	}
+
+	var offset interface{}
+	if params.Offset != "" {
+		offset = params.Offset
+	}
+
+	points, next, err := s.scrollPage(ctx, name, filter, params.scrollLimit(), offset)
+	if err != nil {
+		if isQdrantNotFound(err) {
+			return nil, fmt.Errorf("collection %s: %w", name, ErrCollectionNotFound)
+		}
+		return nil, err
+	}
 
-	// Calculate scores for all items
-	for _, item := range s.items {
-		// Skip expired items
-		if !item.ExpiresAt.IsZero() && time.Now().After(item.ExpiresAt) {
+	page := &ScrollPage{Items: make([]*Item, len(points))}
+	for i, point := range points {
+		page.Items[i] = itemFromPayload(name, point.Payload)
+		page.Items[i].Vector = point.Vector
+	}
+	if next != nil {
+		page.NextOffset = fmt.Sprint(next)
+	}
+
+	return page, nil
+}
+
+// Search performs vector similarity search, querying each collection in
+// turn and merging the hits
+func (s *QdrantStore) Search(ctx context.Context, params *SearchParams) ([]*SearchResult, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	limit := params.searchLimit()
+	offset := max(params.Offset, 0)
+
+	names := params.Collections
+	if len(names) == 0 {
+		names = []string{s.defaultName}
+	}
+
+	var results []*SearchResult
+	sliding := make(map[*SearchResult]*Item)
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		name = s.collectionName(name)
+		if _, dup := seen[name]; dup {
 			continue
 		}
+		seen[name] = struct{}{}
 
-		// Check permissions if filter is provided
-		if len(params.PermissionFilter) > 0 {
-			hasPermission := false
-			for _, permission := range params.PermissionFilter {
-				for _, itemPerm := range item.Permissions {
-					if permission == itemPerm {
-						hasPermission = true
-						break
-					}
-				}
-				if hasPermission {
-					break
-				}
+		page, err := s.searchCollection(ctx, name, params, limit+offset, sliding)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, page...)
+	}
+
+	// Merge the results of every collection and cut out the page
+	sortResults(results)
+	results = results[min(offset, len(results)):]
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	// Refresh the sliding items that made it into the page
+	now := time.Now()
+	due := make(map[string][]*Item)
+	for _, result := range results {
+		if item, exists := sliding[result]; exists && item.needsRefresh(now) {
+			due[result.Collection] = append(due[result.Collection], item)
+		}
+	}
+	for name, items := range due {
+		s.touch(ctx, name, items, now)
+	}
+
+	return results, nil
+}
+
+// searchCollection returns up to want results of one collection that fall
+// inside the search window. Qdrant cannot seek to a cursor, so the search
+// pages through hits until enough of them pass the window. Results of
+// sliding items are recorded in sliding with the item they came from.
+func (s *QdrantStore) searchCollection(ctx context.Context, name string, params *SearchParams, want int, sliding map[*SearchResult]*Item) ([]*SearchResult, error) {
+	distance, err := s.collectionDistance(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	w := newWindow(params)
+	body := map[string]interface{}{
+		"vector":       params.Vector,
+		"with_payload": true,
+		"with_vector":  params.WithVectors,
+		"filter":       searchFilter(params, time.Now()),
+	}
+	if params.MinScore != nil {
+		// Qdrant thresholds Euclid by distance rather than by score
+		threshold := *params.MinScore
+		if distance == DistanceEuclid {
+			threshold = -threshold
+		}
+		body["score_threshold"] = threshold
+	}
+
+	var results []*SearchResult
+	for offset, batch := 0, want; ; offset, batch = offset+batch, batch*2 {
+		body["offset"] = offset
+		body["limit"] = batch
+
+		var points []qdrantScoredPoint
+		if err := s.request(ctx, http.MethodPost, s.collectionPath(name, "/points/search"), body, &points); err != nil {
+			if isQdrantNotFound(err) {
+				return nil, fmt.Errorf("collection %s: %w", name, ErrCollectionNotFound)
 			}
+			return nil, err
+		}
 
-			if !hasPermission {
-				continue
+		// Convert to search results
+		for _, point := range points {
+			result := &SearchResult{
+				ID:         point.Payload.ItemID,
+				Collection: name,
+				DocumentID: point.Payload.DocumentID,
+				Content:    point.Payload.Content,
+				Title:      point.Payload.Title,
+				Metadata:   point.Payload.Metadata,
+				Score:      point.Score,
+				Vector:     point.Vector,
+			}
+			// Qdrant reports Euclid distances; expose them as scores
+			if distance == DistanceEuclid {
+				result.Score = -point.Score
+			}
+			if w.admitsResult(result) {
+				results = append(results, result)
+				if point.Payload.RetentionPolicy == RetentionSliding {
+					sliding[result] = itemFromPayload(name, point.Payload)
+				}
 			}
 		}
 
-		// Calculate cosine similarity
-		score := cosineSimilarity(params.Vector, item.Vector)
-		scored = append(scored, scoredItem{item: item, score: score})
+		if len(results) >= want || len(points) < batch {
+			return results, nil
+		}
 	}
+}
 
-	// Sort by score (descending)
-	sortScored(scored)
+// Stats reports the number of points in each collection. VectorBytes is
+// estimated from the collections' vector sizes.
+func (s *QdrantStore) Stats(ctx context.Context) (*Stats, error) {
+	infos, err := s.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	// Limit results
-	if params.Limit > 0 && len(scored) > params.Limit {
-		scored = scored[:params.Limit]
+	stats := &Stats{
+		Backend:     BackendQdrant,
+		Collections: make(map[string]*CollectionStats, len(infos)),
+	}
+	for _, info := range infos {
+		collectionStats := info.CollectionStats
+		stats.Collections[info.Name] = &collectionStats
+		stats.Items += collectionStats.Items
+		stats.VectorBytes += collectionStats.VectorBytes
 	}
 
-	// Convert to search results
-	results := make([]*SearchResult, len(scored))
-	for i, s := range scored {
-		results[i] = &SearchResult{
-			ID:         s.item.ID,
-			DocumentID: s.item.DocumentID,
-			Content:    s.item.Content,
-			Title:      s.item.Title,
-			Metadata:   s.item.Metadata,
-			Score:      s.score,
-		}
+	return stats, nil
+}
+
+// Subscribe streams the changes made by this store numbered above after;
+// an after of zero streams only new changes
+func (s *QdrantStore) Subscribe(ctx context.Context, after uint64) (*ChangeStream, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
 	}
 
-	return results, nil
+	return s.changes.subscribe(after)
 }
 
-// Close closes the store and cleans up resources
+// Close stops the cleanup routine. Stored points remain in Qdrant.
 func (s *QdrantStore) Close() error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -206,14 +806,13 @@ func (s *QdrantStore) Close() error {
 
 	s.closed = true
 	close(s.closeChan)
-
-	// Clear items
-	s.items = nil
+	s.changes.close()
+	s.httpClient.CloseIdleConnections()
 
 	return nil
 }
 
-// cleanupRoutine periodically removes expired items
+// cleanupRoutine periodically removes expired points
 func (s *QdrantStore) cleanupRoutine() {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
@@ -228,58 +827,492 @@ func (s *QdrantStore) cleanupRoutine() {
 	}
 }
 
-// cleanupExpiredItems removes all expired items
+// cleanupExpiredItems deletes every point whose expiry has passed
 func (s *QdrantStore) cleanupExpiredItems() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.httpClient.Timeout)
+	defer cancel()
+
+	// Failures are retried on the next tick
+	names, err := s.collectionNames(ctx)
+	if err != nil {
+		return
+	}
+
+	body := map[string]interface{}{
+		"filter": map[string]interface{}{
+			"must": []interface{}{expiredCondition(time.Now())},
+		},
+	}
+	for _, name := range names {
+		// Counting first keeps idle collections out of the change feed
+		var count struct {
+			Count int `json:"count"`
+		}
+		if err := s.request(ctx, http.MethodPost, s.collectionPath(name, "/points/count"), body, &count); err != nil || count.Count == 0 {
+			continue
+		}
+		if err := s.request(ctx, http.MethodPost, s.collectionPath(name, "/points/delete"), body, nil); err == nil {
+			s.changes.publish(Change{Type: ChangeExpire, Collection: name})
+		}
+	}
+}
+
+// touch refreshes the expiry of the live sliding chunks of the documents
+// of items after they were read, so that a document expires as a whole.
+// It sends one payload update per TTL. Refreshes are best effort: a
+// failure leaves the old expiry in place and is not worth failing the
+// read for.
+func (s *QdrantStore) touch(ctx context.Context, name string, items []*Item, now time.Time) {
+	byTTL := make(map[time.Duration][]interface{})
+	seen := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		if _, dup := seen[item.DocumentID]; dup {
+			continue
+		}
+		seen[item.DocumentID] = struct{}{}
+		byTTL[item.Retention.TTL] = append(byTTL[item.Retention.TTL], documentCondition(item.DocumentID))
+	}
+
+	for ttl, documents := range byTTL {
+		body := map[string]interface{}{
+			"payload": map[string]interface{}{"expires_at": now.Add(ttl).Unix()},
+			"filter": map[string]interface{}{
+				"must": []interface{}{
+					map[string]interface{}{"should": documents},
+					map[string]interface{}{
+						"key":   "retention_policy",
+						"match": map[string]interface{}{"value": RetentionSliding},
+					},
+				},
+				"must_not": []interface{}{expiredCondition(now)},
+			},
+		}
+		_ = s.request(ctx, http.MethodPost, s.collectionPath(name, "/points/payload"), body, nil)
+	}
+}
+
+// collectionNames lists the Qdrant collections, sorted by name
+func (s *QdrantStore) collectionNames(ctx context.Context) ([]string, error) {
+	var list struct {
+		Collections []struct {
+			Name string `json:"name"`
+		} `json:"collections"`
+	}
+	if err := s.request(ctx, http.MethodGet, "/collections", nil, &list); err != nil {
+		return nil, fmt.Errorf("failed to list qdrant collections: %w", err)
+	}
+
+	names := make([]string, len(list.Collections))
+	for i, c := range list.Collections {
+		names[i] = c.Name
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// scrollPoints pages through every point of a collection matching filter, including vectors
+func (s *QdrantStore) scrollPoints(ctx context.Context, collection string, filter map[string]interface{}) ([]qdrantPoint, error) {
+	var points []qdrantPoint
+	var offset interface{}
+
+	for {
+		page, next, err := s.scrollPage(ctx, collection, filter, qdrantScrollPageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		points = append(points, page...)
+		if next == nil {
+			return points, nil
+		}
+		offset = next
+	}
+}
+
+// scrollPage fetches one page of points matching filter, including vectors.
+// It returns the offset of the next page, or nil after the last page.
+func (s *QdrantStore) scrollPage(ctx context.Context, collection string, filter map[string]interface{}, limit int, offset interface{}) ([]qdrantPoint, interface{}, error) {
+	body := map[string]interface{}{
+		"filter":       filter,
+		"limit":        limit,
+		"with_payload": true,
+		"with_vector":  true,
+	}
+	if offset != nil {
+		body["offset"] = offset
+	}
+
+	var page struct {
+		Points         []qdrantPoint `json:"points"`
+		NextPageOffset interface{}   `json:"next_page_offset"`
+	}
+	if err := s.request(ctx, http.MethodPost, s.collectionPath(collection, "/points/scroll"), body, &page); err != nil {
+		return nil, nil, err
+	}
+
+	return page.Points, page.NextPageOffset, nil
+}
+
+// checkOpen returns an error once the store has been closed
+func (s *QdrantStore) checkOpen() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	return nil
+}
+
+// collectionName resolves a collection name; an empty name selects the default
+func (s *QdrantStore) collectionName(name string) string {
+	if name == "" {
+		return s.defaultName
+	}
+	return name
+}
+
+// collectionConfig returns the settings applied to items of a collection.
+// Qdrant cannot store a TTL with a collection, so collections created by
+// another process fall back to Config.TTL.
+func (s *QdrantStore) collectionConfig(name string) *CollectionConfig {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if config, exists := s.configs[name]; exists {
+		return &config
+	}
+	return &CollectionConfig{Name: name, TTL: s.config.TTL}
+}
+
+// writeConfig returns the settings checked and applied by writes to a
+// collection. The vector size of a collection created by another process
+// is learned from Qdrant.
+func (s *QdrantStore) writeConfig(ctx context.Context, name string) (*CollectionConfig, error) {
+	s.lock.RLock()
+	config, exists := s.configs[name]
+	s.lock.RUnlock()
+	if exists {
+		return &config, nil
+	}
+
+	var details qdrantCollectionInfo
+	if err := s.request(ctx, http.MethodGet, s.collectionPath(name, ""), nil, &details); err != nil {
+		if isQdrantNotFound(err) {
+			return nil, fmt.Errorf("collection %s: %w", name, ErrCollectionNotFound)
+		}
+		return nil, fmt.Errorf("failed to get qdrant collection %s: %w", name, err)
+	}
+	config = CollectionConfig{
+		Name:       name,
+		VectorSize: details.Config.Params.Vectors.Size,
+		Distance:   distanceFromQdrant(details.Config.Params.Vectors.Distance),
+		TTL:        s.config.TTL,
+	}
+
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	s.configs[name] = config
+	s.distances[name] = config.Distance
+	s.lock.Unlock()
 
-	now := time.Now()
+	return &config, nil
+}
+
+// collectionDistance returns the metric of a collection, asking Qdrant
+// for collections this store has not seen yet
+func (s *QdrantStore) collectionDistance(ctx context.Context, name string) (Distance, error) {
+	s.lock.RLock()
+	distance, exists := s.distances[name]
+	s.lock.RUnlock()
+	if exists {
+		return distance, nil
+	}
 
-	for id, item := range s.items {
-		if !item.ExpiresAt.IsZero() && now.After(item.ExpiresAt) {
-			delete(s.items, id)
+	var details qdrantCollectionInfo
+	if err := s.request(ctx, http.MethodGet, s.collectionPath(name, ""), nil, &details); err != nil {
+		if isQdrantNotFound(err) {
+			return "", fmt.Errorf("collection %s: %w", name, ErrCollectionNotFound)
 		}
+		return "", fmt.Errorf("failed to get qdrant collection %s: %w", name, err)
 	}
+	distance = distanceFromQdrant(details.Config.Params.Vectors.Distance)
+
+	s.lock.Lock()
+	s.distances[name] = distance
+	s.lock.Unlock()
+
+	return distance, nil
+}
+
+// distanceFromQdrant maps a Qdrant distance name onto a metric.
+// Unknown names are treated as cosine.
+func distanceFromQdrant(name string) Distance {
+	for distance, qdrantName := range qdrantDistances {
+		if qdrantName == name {
+			return distance
+		}
+	}
+	return DistanceCosine
+}
+
+// collectionPath builds a path below a collection
+func (s *QdrantStore) collectionPath(collection, suffix string) string {
+	return "/collections/" + url.PathEscape(collection) + suffix
 }
 
-// cosineSimilarity calculates cosine similarity between two vectors
-func cosineSimilarity(a, b []float32) float64 {
-	// Ensure vectors have the same length
-	if len(a) != len(b) {
-		return 0
+// request performs a Qdrant API call and decodes the result field into result
+func (s *QdrantStore) request(ctx context.Context, method, path string, body, result interface{}) error {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		bodyReader = bytes.NewReader(bodyBytes)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, bodyReader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	var dotProduct float64
-	var normA float64
-	var normB float64
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if s.config.APIKey != "" {
+		req.Header.Set("api-key", s.config.APIKey)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("qdrant request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &qdrantStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
 
-	for i := 0; i < len(a); i++ {
-		dotProduct += float64(a[i] * b[i])
-		normA += float64(a[i] * a[i])
-		normB += float64(b[i] * b[i])
+	if result == nil {
+		return nil
 	}
 
-	// Handle zero vectors
-	if normA == 0 || normB == 0 {
-		return 0
+	var envelope qdrantResponse
+	if err := json.Unmarshal(respBody, &envelope); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if err := json.Unmarshal(envelope.Result, result); err != nil {
+		return fmt.Errorf("failed to decode response result: %w", err)
 	}
 
-	return dotProduct / (sqrt(normA) * sqrt(normB))
+	return nil
+}
+
+// isQdrantNotFound reports whether err is a 404 answer from Qdrant
+func isQdrantNotFound(err error) bool {
+	statusErr, ok := err.(*qdrantStatusError)
+	return ok && statusErr.StatusCode == http.StatusNotFound
 }
 
-// sqrt calculates square root (simple implementation for the POC)
-func sqrt(x float64) float64 {
-	return float64(float32(x))
+// pointID maps an item ID onto the UUID format required by Qdrant.
+// The original ID is kept in the payload.
+func pointID(id string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(id)).String()
 }
 
-// sortScored sorts scored items by score in descending order
-func sortScored(items []scoredItem) {
-	// For POC, simple bubble sort is fine
-	for i := 0; i < len(items); i++ {
-		for j := i + 1; j < len(items); j++ {
-			if items[i].score < items[j].score {
-				items[i], items[j] = items[j], items[i]
+// payloadFromItem converts an item into a Qdrant payload
+func payloadFromItem(item *Item) qdrantPayload {
+	payload := qdrantPayload{
+		ItemID:          item.ID,
+		DocumentID:      item.DocumentID,
+		Content:         item.Content,
+		Title:           item.Title,
+		Metadata:        item.Metadata,
+		Permissions:     item.Permissions,
+		RetentionPolicy: item.Retention.Policy,
+		RetentionTTL:    int64(item.Retention.TTL / time.Second),
+		Pinned:          item.Retention.Pinned,
+		Model:           item.Model.ID,
+		ModelVersion:    item.Model.Version,
+	}
+	if !item.ExpiresAt.IsZero() {
+		payload.ExpiresAt = item.ExpiresAt.Unix()
+	}
+
+	for key, value := range item.Metadata {
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			if payload.MetadataNumeric == nil {
+				payload.MetadataNumeric = make(map[string]float64)
 			}
+			payload.MetadataNumeric[key] = n
+		}
+	}
+
+	return payload
+}
+
+// itemFromPayload converts a Qdrant payload back into an item of a collection
+func itemFromPayload(collection string, payload qdrantPayload) *Item {
+	item := &Item{
+		ID:          payload.ItemID,
+		Collection:  collection,
+		DocumentID:  payload.DocumentID,
+		Content:     payload.Content,
+		Title:       payload.Title,
+		Metadata:    payload.Metadata,
+		Permissions: payload.Permissions,
+		Retention: Retention{
+			Policy: payload.RetentionPolicy,
+			TTL:    time.Duration(payload.RetentionTTL) * time.Second,
+			Pinned: payload.Pinned,
+		},
+		Model: EmbeddingModel{ID: payload.Model, Version: payload.ModelVersion},
+	}
+	if payload.ExpiresAt > 0 {
+		item.ExpiresAt = time.Unix(payload.ExpiresAt, 0)
+	}
+
+	return item
+}
+
+// searchFilter builds the Qdrant filter for permission, model, metadata and
+// expiry checks
+func searchFilter(params *SearchParams, now time.Time) map[string]interface{} {
+	filter := map[string]interface{}{
+		"must_not": []interface{}{expiredCondition(now)},
+	}
+
+	var must []interface{}
+	if len(params.PermissionFilter) > 0 {
+		must = append(must, map[string]interface{}{
+			"key":   "permissions",
+			"match": map[string]interface{}{"any": params.PermissionFilter},
+		})
+	}
+	if params.Model != nil {
+		must = append(must, modelCondition(params.Model))
+	}
+	if params.Filter != nil {
+		must = append(must, qdrantCondition(params.Filter))
+	}
+	if len(must) > 0 {
+		filter["must"] = must
+	}
+
+	return filter
+}
+
+// qdrantCondition translates a metadata filter into a Qdrant filter condition
+func qdrantCondition(f *Filter) map[string]interface{} {
+	switch {
+	case f.And != nil:
+		return map[string]interface{}{"must": qdrantConditions(f.And)}
+	case f.Or != nil:
+		return map[string]interface{}{"should": qdrantConditions(f.Or)}
+	case f.Not != nil:
+		return map[string]interface{}{"must_not": []interface{}{qdrantCondition(f.Not)}}
+	case f.Equals != nil:
+		return map[string]interface{}{
+			"key":   "metadata." + f.Field,
+			"match": map[string]interface{}{"value": *f.Equals},
+		}
+	case f.In != nil:
+		return map[string]interface{}{
+			"key":   "metadata." + f.Field,
+			"match": map[string]interface{}{"any": f.In},
 		}
 	}
+
+	// Range: numbers use the numeric copy of the metadata, dates the
+	// original string, which Qdrant parses as a datetime
+	bounds := map[string]interface{}{}
+	numeric := f.Range.isNumeric()
+	for name, b := range map[string]*Bound{"gt": f.Range.GT, "gte": f.Range.GTE, "lt": f.Range.LT, "lte": f.Range.LTE} {
+		if b == nil {
+			continue
+		}
+		if numeric {
+			bounds[name], _ = b.number()
+		} else {
+			t, _ := b.date()
+			bounds[name] = t.Format(time.RFC3339)
+		}
+	}
+
+	key := "metadata." + f.Field
+	if numeric {
+		key = "metadata_numeric." + f.Field
+	}
+
+	return map[string]interface{}{"key": key, "range": bounds}
+}
+
+// qdrantConditions translates a list of filters
+func qdrantConditions(filters []*Filter) []interface{} {
+	conditions := make([]interface{}, len(filters))
+	for i, f := range filters {
+		conditions[i] = qdrantCondition(f)
+	}
+	return conditions
+}
+
+// documentCondition matches the points of one document
+func documentCondition(documentID string) map[string]interface{} {
+	return map[string]interface{}{
+		"key":   "document_id",
+		"match": map[string]interface{}{"value": documentID},
+	}
+}
+
+// modelCondition matches the points of a model
+func modelCondition(model *EmbeddingModel) map[string]interface{} {
+	return map[string]interface{}{
+		"must": []interface{}{
+			map[string]interface{}{
+				"key":   "model",
+				"match": map[string]interface{}{"value": model.ID},
+			},
+			map[string]interface{}{
+				"key":   "model_version",
+				"match": map[string]interface{}{"value": model.Version},
+			},
+		},
+	}
+}
+
+// expiredCondition matches unpinned points with an expiry time in the past
+func expiredCondition(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"must": []interface{}{map[string]interface{}{
+			"key": "expires_at",
+			"range": map[string]interface{}{
+				"gt": 0,
+				"lt": now.Unix(),
+			},
+		}},
+		"must_not": []interface{}{map[string]interface{}{
+			"key":   "pinned",
+			"match": map[string]interface{}{"value": true},
+		}},
+	}
+}
+
+// retentionPayload holds the payload fields that SetDocumentRetention sets
+func retentionPayload(retention Retention, expiresAt time.Time) map[string]interface{} {
+	payload := map[string]interface{}{
+		"retention_policy": retention.Policy,
+		"retention_ttl":    int64(retention.TTL / time.Second),
+		"pinned":           retention.Pinned,
+		"expires_at":       int64(0),
+	}
+	if !expiresAt.IsZero() {
+		payload["expires_at"] = expiresAt.Unix()
+	}
+	return payload
 }
@@ -0,0 +1,466 @@
+package vectorstore
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeQdrant serves the part of the Qdrant REST API the store uses from
+// memory. Filters are evaluated against the JSON payload of each point.
+type fakeQdrant struct {
+	lock        sync.Mutex
+	collections map[string]*fakeCollection
+	// upserts counts the point upserts received
+	upserts int
+}
+
+// fakeCollection is a collection of the fake server
+type fakeCollection struct {
+	size     int
+	distance string
+	points   map[string]*fakePoint
+}
+
+// fakePoint is a point with its payload as decoded from JSON
+type fakePoint struct {
+	ID      string                 `json:"id"`
+	Vector  []float32              `json:"vector,omitempty"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// newFakeQdrant starts a fake Qdrant server and returns a store connected
+// to it, both closed when the test ends
+func newFakeQdrant(t *testing.T, config *Config) (*fakeQdrant, *QdrantStore) {
+	t.Helper()
+
+	fake := &fakeQdrant{collections: make(map[string]*fakeCollection)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	config.Backend = BackendQdrant
+	config.Address = server.URL
+	store, err := NewQdrantStore(config)
+	if err != nil {
+		t.Fatalf("NewQdrantStore failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return fake, store
+}
+
+// createCollection adds a collection behind the store's back, as another
+// process would
+func (f *fakeQdrant) createCollection(name string, size int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.collections[name] = &fakeCollection{size: size, distance: "Cosine", points: make(map[string]*fakePoint)}
+}
+
+func (f *fakeQdrant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var body map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if path[0] != "collections" {
+		http.NotFound(w, r)
+		return
+	}
+	if len(path) == 1 {
+		var list []map[string]string
+		for name := range f.collections {
+			list = append(list, map[string]string{"name": name})
+		}
+		fakeReply(w, map[string]interface{}{"collections": list})
+		return
+	}
+
+	name := path[1]
+	c := f.collections[name]
+	if len(path) == 2 {
+		switch r.Method {
+		case http.MethodPut:
+			vectors := body["vectors"].(map[string]interface{})
+			f.collections[name] = &fakeCollection{
+				size:     int(vectors["size"].(float64)),
+				distance: vectors["distance"].(string),
+				points:   make(map[string]*fakePoint),
+			}
+			fakeReply(w, true)
+		case http.MethodDelete:
+			delete(f.collections, name)
+			fakeReply(w, c != nil)
+		default:
+			if c == nil {
+				http.NotFound(w, r)
+				return
+			}
+			info := map[string]interface{}{"points_count": len(c.points)}
+			info["config"] = map[string]interface{}{"params": map[string]interface{}{
+				"vectors": map[string]interface{}{"size": c.size, "distance": c.distance},
+			}}
+			fakeReply(w, info)
+		}
+		return
+	}
+	if c == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch operation := path[3:]; {
+	case len(operation) == 0 && r.Method == http.MethodPut:
+		var points []*fakePoint
+		data, _ := json.Marshal(body["points"])
+		json.Unmarshal(data, &points)
+		for _, point := range points {
+			if len(point.Vector) != c.size {
+				http.Error(w, "wrong vector dimension", http.StatusBadRequest)
+				return
+			}
+		}
+		for _, point := range points {
+			c.points[point.ID] = point
+		}
+		f.upserts++
+		fakeReply(w, map[string]string{"status": "completed"})
+
+	case len(operation) == 1 && r.Method == http.MethodGet:
+		point, exists := c.points[operation[0]]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		fakeReply(w, point)
+
+	case operation[0] == "delete":
+		if ids, exists := body["points"]; exists {
+			for _, id := range ids.([]interface{}) {
+				delete(c.points, id.(string))
+			}
+		} else {
+			for id := range c.matching(body["filter"]) {
+				delete(c.points, id)
+			}
+		}
+		fakeReply(w, map[string]string{"status": "completed"})
+
+	case operation[0] == "payload":
+		for _, point := range c.matching(body["filter"]) {
+			for key, value := range body["payload"].(map[string]interface{}) {
+				point.Payload[key] = value
+			}
+		}
+		fakeReply(w, map[string]string{"status": "completed"})
+
+	case operation[0] == "count":
+		fakeReply(w, map[string]int{"count": len(c.matching(body["filter"]))})
+
+	case operation[0] == "scroll":
+		matching := c.matching(body["filter"])
+		ids := slices.Sorted(maps.Keys(matching))
+		if offset, exists := body["offset"].(string); exists {
+			start, _ := slices.BinarySearch(ids, offset)
+			ids = ids[start:]
+		}
+		limit := int(body["limit"].(float64))
+		page := map[string]interface{}{"points": []*fakePoint{}, "next_page_offset": nil}
+		if len(ids) > limit {
+			page["next_page_offset"] = ids[limit]
+			ids = ids[:limit]
+		}
+		points := make([]*fakePoint, len(ids))
+		for i, id := range ids {
+			points[i] = matching[id]
+		}
+		page["points"] = points
+		fakeReply(w, page)
+
+	case operation[0] == "search":
+		f.search(w, c, body)
+
+	default:
+		http.Error(w, "unsupported", http.StatusNotImplemented)
+	}
+}
+
+// search scores the matching points against the query vector
+func (f *fakeQdrant) search(w http.ResponseWriter, c *fakeCollection, body map[string]interface{}) {
+	var query []float32
+	data, _ := json.Marshal(body["vector"])
+	json.Unmarshal(data, &query)
+
+	type scored struct {
+		ID      string                 `json:"id"`
+		Score   float64                `json:"score"`
+		Payload map[string]interface{} `json:"payload"`
+		Vector  []float32              `json:"vector,omitempty"`
+	}
+	var hits []scored
+	for _, point := range c.matching(body["filter"]) {
+		var score float64
+		switch c.distance {
+		case "Euclid":
+			for i, v := range point.Vector {
+				d := float64(v - query[i])
+				score += d * d
+			}
+			score = math.Sqrt(score)
+		case "Dot":
+			score = dotProduct(point.Vector, query)
+		default:
+			score = dotProduct(point.Vector, query) / math.Sqrt(dotProduct(point.Vector, point.Vector)*dotProduct(query, query))
+		}
+		if threshold, exists := body["score_threshold"].(float64); exists {
+			if (c.distance == "Euclid" && score > threshold) || (c.distance != "Euclid" && score < threshold) {
+				continue
+			}
+		}
+		hit := scored{ID: point.ID, Score: score, Payload: point.Payload}
+		if body["with_vector"] == true {
+			hit.Vector = point.Vector
+		}
+		hits = append(hits, hit)
+	}
+
+	slices.SortFunc(hits, func(a, b scored) int {
+		if a.Score == b.Score {
+			return strings.Compare(a.ID, b.ID)
+		}
+		if (a.Score > b.Score) != (c.distance == "Euclid") {
+			return -1
+		}
+		return 1
+	})
+	offset := min(int(body["offset"].(float64)), len(hits))
+	hits = hits[offset:]
+	hits = hits[:min(int(body["limit"].(float64)), len(hits))]
+	if hits == nil {
+		hits = []scored{}
+	}
+	fakeReply(w, hits)
+}
+
+// matching returns the points of the collection a filter accepts
+func (c *fakeCollection) matching(filter interface{}) map[string]*fakePoint {
+	matching := make(map[string]*fakePoint)
+	for id, point := range c.points {
+		if filter == nil || fakeMatches(filter.(map[string]interface{}), point) {
+			matching[id] = point
+		}
+	}
+	return matching
+}
+
+// fakeMatches evaluates a filter or a field condition against a point
+func fakeMatches(condition map[string]interface{}, point *fakePoint) bool {
+	if ids, exists := condition["has_id"]; exists {
+		return slices.Contains(ids.([]interface{}), interface{}(point.ID))
+	}
+	if empty, exists := condition["is_empty"]; exists {
+		value := fakeField(point.Payload, empty.(map[string]interface{})["key"].(string))
+		list, isList := value.([]interface{})
+		return value == nil || (isList && len(list) == 0)
+	}
+	if key, exists := condition["key"]; exists {
+		return fakeFieldMatches(condition, fakeField(point.Payload, key.(string)))
+	}
+
+	if must, exists := condition["must"]; exists {
+		for _, c := range must.([]interface{}) {
+			if !fakeMatches(c.(map[string]interface{}), point) {
+				return false
+			}
+		}
+	}
+	if mustNot, exists := condition["must_not"]; exists {
+		for _, c := range mustNot.([]interface{}) {
+			if fakeMatches(c.(map[string]interface{}), point) {
+				return false
+			}
+		}
+	}
+	if should, exists := condition["should"]; exists && len(should.([]interface{})) > 0 {
+		for _, c := range should.([]interface{}) {
+			if fakeMatches(c.(map[string]interface{}), point) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// fakeFieldMatches applies a match or range condition to a payload value.
+// Lists match if any of their elements does.
+func fakeFieldMatches(condition map[string]interface{}, value interface{}) bool {
+	if list, isList := value.([]interface{}); isList {
+		for _, element := range list {
+			if fakeFieldMatches(condition, element) {
+				return true
+			}
+		}
+		return false
+	}
+	if value == nil {
+		return false
+	}
+
+	if match, exists := condition["match"].(map[string]interface{}); exists {
+		if want, exists := match["value"]; exists {
+			return fmt.Sprint(want) == fmt.Sprint(value)
+		}
+		for _, want := range match["any"].([]interface{}) {
+			if fmt.Sprint(want) == fmt.Sprint(value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	bounds := condition["range"].(map[string]interface{})
+	compare := func(bound interface{}) (int, bool) {
+		if number, isNumber := value.(float64); isNumber {
+			b, ok := bound.(float64)
+			return cmp.Compare(number, b), ok
+		}
+		t, err := time.Parse(time.RFC3339, fmt.Sprint(value))
+		if err != nil {
+			t, err = time.Parse(time.DateOnly, fmt.Sprint(value))
+		}
+		b, boundErr := time.Parse(time.RFC3339, fmt.Sprint(bound))
+		return t.Compare(b), err == nil && boundErr == nil
+	}
+	for name, bound := range bounds {
+		c, ok := compare(bound)
+		if !ok {
+			return false
+		}
+		switch {
+		case name == "gt" && c <= 0, name == "gte" && c < 0, name == "lt" && c >= 0, name == "lte" && c > 0:
+			return false
+		}
+	}
+	return true
+}
+
+// fakeField looks up a dotted key in a payload
+func fakeField(payload map[string]interface{}, key string) interface{} {
+	var value interface{} = payload
+	for _, part := range strings.Split(key, ".") {
+		object, isObject := value.(map[string]interface{})
+		if !isObject {
+			return nil
+		}
+		value = object[part]
+	}
+	return value
+}
+
+// fakeReply writes a result in the Qdrant response envelope
+func fakeReply(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"result": result, "status": "ok"})
+}
+
+func TestQdrantStoreChecksVectorSize(t *testing.T) {
+	ctx := context.Background()
+	fake, store := newFakeQdrant(t, &Config{VectorSize: 3})
+
+	wrong := &Item{ID: "wrong", DocumentID: "doc", Vector: []float32{1, 0}}
+	if err := store.Store(ctx, wrong); !errors.Is(err, ErrVectorSize) {
+		t.Errorf("Store returned %v, want ErrVectorSize", err)
+	}
+	if err := store.StoreBatch(ctx, "", []*Item{wrong}); !errors.Is(err, ErrVectorSize) {
+		t.Errorf("StoreBatch returned %v, want ErrVectorSize", err)
+	}
+	if err := store.ReplaceDocument(ctx, "", "doc", []*Item{wrong}); !errors.Is(err, ErrVectorSize) {
+		t.Errorf("ReplaceDocument returned %v, want ErrVectorSize", err)
+	}
+	if fake.upserts != 0 {
+		t.Errorf("%d upserts reached Qdrant, want none", fake.upserts)
+	}
+
+	// The size of a collection made by another process is learned
+	fake.createCollection("shared", 2)
+	if err := store.StoreBatch(ctx, "shared", []*Item{wrong}); err != nil {
+		t.Errorf("StoreBatch into a collection of matching size failed: %v", err)
+	}
+	right := &Item{ID: "right", DocumentID: "doc", Collection: "shared", Vector: []float32{1, 0, 0}}
+	if err := store.Store(ctx, right); !errors.Is(err, ErrVectorSize) {
+		t.Errorf("Store into another process's collection returned %v, want ErrVectorSize", err)
+	}
+
+	if err := store.Store(ctx, &Item{ID: "x", Collection: "missing", Vector: []float32{1}}); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Store into a missing collection returned %v, want ErrCollectionNotFound", err)
+	}
+}
+
+func TestQdrantStoreDocuments(t *testing.T) {
+	ctx := context.Background()
+	_, store := newFakeQdrant(t, &Config{VectorSize: 2})
+
+	items := []*Item{
+		{ID: "a-0", DocumentID: "a", Vector: []float32{1, 0}, Content: "first", Permissions: []string{"alice"}},
+		{ID: "a-1", DocumentID: "a", Vector: []float32{0.9, 0.1}, Content: "second", Permissions: []string{"alice"}},
+		{ID: "b-0", DocumentID: "b", Vector: []float32{0, 1}, Content: "other", Permissions: []string{"bob"}},
+	}
+	if err := store.StoreBatch(ctx, "", items); err != nil {
+		t.Fatalf("StoreBatch failed: %v", err)
+	}
+
+	chunks, err := store.GetDocument(ctx, "", "a")
+	if err != nil {
+		t.Fatalf("GetDocument failed: %v", err)
+	}
+	if len(chunks) != 2 || chunks[0].ID != "a-0" || chunks[1].Content != "second" {
+		t.Errorf("GetDocument returned %+v", chunks)
+	}
+
+	results, err := store.Search(ctx, &SearchParams{Vector: []float32{1, 0}, Limit: 10, PermissionFilter: []string{"bob"}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "b-0" {
+		t.Errorf("Search with bob's permissions returned %v", resultIDs(results))
+	}
+
+	replacement := []*Item{{ID: "a-2", DocumentID: "a", Vector: []float32{1, 1}, Content: "third"}}
+	if err := store.ReplaceDocument(ctx, "", "a", replacement); err != nil {
+		t.Fatalf("ReplaceDocument failed: %v", err)
+	}
+	chunks, err = store.GetDocument(ctx, "", "a")
+	if err != nil {
+		t.Fatalf("GetDocument failed: %v", err)
+	}
+	if len(chunks) != 1 || chunks[0].ID != "a-2" {
+		t.Errorf("document a holds %d chunks after the replace, want only a-2", len(chunks))
+	}
+
+	if err := store.DeleteDocument(ctx, "", "a"); err != nil {
+		t.Fatalf("DeleteDocument failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "", "a-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a deleted chunk returned %v, want ErrNotFound", err)
+	}
+	if item, err := store.Get(ctx, "", "b-0"); err != nil || item.Content != "other" {
+		t.Errorf("Get of b-0 returned %+v, %v", item, err)
+	}
+}
@@ -0,0 +1,133 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestScalarQuantizer(t *testing.T) {
+	q := newScalarQuantizer(QuantizationConfig{})
+	q.calibrate(DistanceDot, [][]float32{{-0.5, 1}, {2.54, 0}})
+
+	if !q.calibrated || q.min != -0.5 || q.max != 2.54 {
+		t.Fatalf("calibration found range [%v, %v], want [-0.5, 2.54]", q.min, q.max)
+	}
+	if math.Abs(float64(q.scale)-0.02) > 1e-6 {
+		t.Errorf("calibration chose scale %v, want 0.02", q.scale)
+	}
+
+	// Components outside the range are clamped
+	code := q.quantize([]float32{2.54, -0.5, 0, 5})
+	if want := []int8{127, -25, 0, 127}; !slices.Equal(code.codes, want) {
+		t.Errorf("quantize = %v, want %v", code.codes, want)
+	}
+	if code.offset >= 0 {
+		t.Errorf("a new code points at offset %d of the vector file", code.offset)
+	}
+
+	// Integer scores stay close to the float scores
+	a := []float32{0.3, -0.2, 1.1, 0.7}
+	b := []float32{-0.4, 0.9, 0.5, 1.3}
+	for _, metric := range []Distance{DistanceCosine, DistanceDot, DistanceEuclid} {
+		got := q.similarity(metric, q.quantize(a), q.quantize(b))
+		if want := metric.similarity(a, b); math.Abs(got-want) > 0.05 {
+			t.Errorf("%s similarity of int8 codes = %v, want about %v", metric, got, want)
+		}
+	}
+}
+
+func TestQuantizedSearch(t *testing.T) {
+	ctx := context.Background()
+	const dimensions, count = 8, 200
+	store := newTestStore(t, &Config{
+		Backend:      BackendMemory,
+		Collection:   "docs",
+		VectorSize:   dimensions,
+		Quantization: QuantizationConfig{Enabled: true, CalibrationSize: 50},
+	})
+
+	random := rand.New(rand.NewSource(1))
+	vectors := make([][]float32, count)
+	for i := range vectors {
+		vectors[i] = make([]float32, dimensions)
+		for j := range vectors[i] {
+			vectors[i][j] = float32(random.NormFloat64())
+		}
+		item := &Item{ID: fmt.Sprintf("item-%d", i), DocumentID: fmt.Sprintf("doc-%d", i), Vector: vectors[i]}
+		if err := store.Store(ctx, item); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+	}
+
+	// Rescoring uses the float vectors, so scores are exact
+	for qi := 0; qi < 20; qi++ {
+		query := vectors[qi]
+		results, err := store.Search(ctx, &SearchParams{Vector: query, Limit: 5})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 5 || results[0].ID != fmt.Sprintf("item-%d", qi) {
+			t.Fatalf("search for item-%d returned %v", qi, resultIDs(results))
+		}
+		for _, result := range results {
+			var i int
+			fmt.Sscanf(result.ID, "item-%d", &i)
+			if want := cosineSimilarity(query, vectors[i]); math.Abs(result.Score-want) > 1e-5 {
+				t.Errorf("%s scored %v, want the float score %v", result.ID, result.Score, want)
+			}
+		}
+	}
+
+	// Stored vectors come back as stored, not dequantized
+	item, err := store.Get(ctx, "docs", "item-7")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !slices.Equal(item.Vector, normalize(vectors[7])) {
+		t.Errorf("Get returned vector %v, want %v", item.Vector, normalize(vectors[7]))
+	}
+
+	stats, err := store.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	quantization := stats.Collections["docs"].Quantization
+	if quantization == nil || !quantization.Calibrated || quantization.QuantizedItems != count {
+		t.Fatalf("stats report quantization %+v, want %d calibrated items", quantization, count)
+	}
+	if quantization.CompressionRatio != 4 {
+		t.Errorf("stats report compression ratio %v, want 4", quantization.CompressionRatio)
+	}
+	if want := int64(4 * dimensions * count); quantization.RescoreBytes != want {
+		t.Errorf("stats report %d rescore bytes, want %d", quantization.RescoreBytes, want)
+	}
+	if quantization.Recall < 0.9 || quantization.Recall > 1 {
+		t.Errorf("stats report recall %v, want at least 0.9", quantization.Recall)
+	}
+
+	// Replaced and deleted items give their slots in the vector file back
+	if err := store.Store(ctx, &Item{ID: "item-7", DocumentID: "doc-7", Vector: vectors[8]}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if err := store.DeleteDocument(ctx, "docs", "doc-9"); err != nil {
+		t.Fatalf("DeleteDocument failed: %v", err)
+	}
+	if err := store.Store(ctx, &Item{ID: "item-new", DocumentID: "doc-new", Vector: vectors[9]}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	vectorFile := store.(*MemoryStore).vectors
+	if want := int64(4 * dimensions * count); vectorFile.size != want {
+		t.Errorf("vector file holds %d bytes, want %d", vectorFile.size, want)
+	}
+	item, err = store.Get(ctx, "docs", "item-new")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !slices.Equal(item.Vector, normalize(vectors[9])) {
+		t.Errorf("Get returned vector %v, want %v", item.Vector, normalize(vectors[9]))
+	}
+}
@@ -0,0 +1,321 @@
+package vectorstore
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Default reduction parameters
+const (
+	defaultReductionSampleSize = 1000
+	// pcaIterations is the number of subspace iterations fitting a
+	// projection. A few are enough to capture nearly all of the variance
+	// the principal subspace holds.
+	pcaIterations = 6
+)
+
+// ReductionMethod selects how a collection reduces the dimensions of its
+// vectors
+type ReductionMethod string
+
+const (
+	// ReductionNone keeps vectors at their full size
+	ReductionNone ReductionMethod = ""
+	// ReductionPrefix keeps the leading dimensions of each vector and
+	// renormalizes it. It suits Matryoshka-trained models, such as
+	// text-embedding-3 and nomic-embed-text-v1.5, which pack the most
+	// information into the leading dimensions.
+	ReductionPrefix ReductionMethod = "prefix"
+	// ReductionPCA projects vectors onto the principal components of a
+	// sample of the collection's vectors
+	ReductionPCA ReductionMethod = "pca"
+)
+
+// ReductionConfig shrinks the vectors kept by the in-memory and bolt
+// backends. Stored vectors and queries are reduced the same way; vectors
+// read back are mapped to their full size again, approximately.
+type ReductionConfig struct {
+	Method ReductionMethod
+	// Dimensions is the size of reduced vectors. Collections whose vectors
+	// are not larger, or have no fixed size, are not reduced.
+	Dimensions int
+	// SampleSize is the number of vectors a PCA projection is fitted on.
+	// Vectors stored earlier keep their full size until it is fitted.
+	SampleSize int
+}
+
+// ReductionStats describes the reduction of a collection's vectors
+type ReductionStats struct {
+	Method       ReductionMethod `json:"method"`
+	Dimensions   int             `json:"dimensions"`
+	Fitted       bool            `json:"fitted"`
+	ReducedItems int             `json:"reduced_items"`
+	// ExplainedVariance is the share of the sample's variance about the
+	// origin that the PCA projection keeps
+	ExplainedVariance float64 `json:"explained_variance,omitempty"`
+}
+
+// Projection maps vectors onto the principal subspace of a sample. Its
+// components are orthonormal, so a reduced vector mapped back with them
+// reduces to itself again.
+type Projection struct {
+	Components        [][]float32
+	ExplainedVariance float64
+}
+
+// validate checks the reduction settings
+func (r ReductionConfig) validate() error {
+	switch r.Method {
+	case ReductionNone:
+		return nil
+	case ReductionPrefix, ReductionPCA:
+	default:
+		return fmt.Errorf("unknown reduction method %q", r.Method)
+	}
+
+	if r.Dimensions <= 0 {
+		return fmt.Errorf("%s reduction requires a positive number of dimensions, got %d", r.Method, r.Dimensions)
+	}
+	return nil
+}
+
+// vectorReducer reduces the vectors of one collection. Its methods accept
+// a nil reducer, which keeps vectors as they are.
+type vectorReducer struct {
+	method     ReductionMethod
+	size       int // size of full vectors
+	dimensions int
+	sampleSize int
+	projection *Projection // nil until a PCA projection is fitted
+}
+
+// newVectorReducer creates the reducer of a collection, or returns nil if
+// its vectors are kept at full size
+func newVectorReducer(config ReductionConfig, collection CollectionConfig) *vectorReducer {
+	if config.Method == ReductionNone || config.Dimensions >= collection.VectorSize {
+		return nil
+	}
+
+	sampleSize := config.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = defaultReductionSampleSize
+	}
+
+	return &vectorReducer{
+		method:     config.Method,
+		size:       collection.VectorSize,
+		dimensions: config.Dimensions,
+		sampleSize: sampleSize,
+	}
+}
+
+// fitting reports whether the reducer still waits for its PCA sample
+func (r *vectorReducer) fitting() bool {
+	return r != nil && r.method == ReductionPCA && r.projection == nil
+}
+
+// fitted returns the PCA projection, or nil if there is none
+func (r *vectorReducer) fitted() *Projection {
+	if r == nil {
+		return nil
+	}
+	return r.projection
+}
+
+// adopt takes a projection fitted earlier if it matches the reducer's
+// settings, which may have changed since
+func (r *vectorReducer) adopt(projection *Projection) {
+	if !r.fitting() || projection == nil || len(projection.Components) != r.dimensions {
+		return
+	}
+	for _, component := range projection.Components {
+		if len(component) != r.size {
+			return
+		}
+	}
+	r.projection = projection
+}
+
+// reducible reports whether a vector is a full vector the reducer can
+// reduce, which PCA cannot before its projection is fitted
+func (r *vectorReducer) reducible(vector []float32) bool {
+	return r != nil && len(vector) == r.size && !r.fitting()
+}
+
+// reduce returns a full vector reduced. Vectors that are not reducible are
+// returned unchanged.
+func (r *vectorReducer) reduce(vector []float32) []float32 {
+	if !r.reducible(vector) {
+		return vector
+	}
+
+	if r.method == ReductionPrefix {
+		return normalize(vector[:r.dimensions:r.dimensions])
+	}
+
+	reduced := make([]float32, r.dimensions)
+	for i, component := range r.projection.Components {
+		reduced[i] = float32(dotProduct(component, vector))
+	}
+	return reduced
+}
+
+// reduced reports whether a vector has been reduced
+func (r *vectorReducer) reduced(vector []float32) bool {
+	return r != nil && len(vector) == r.dimensions
+}
+
+// restore maps a reduced vector back to the full size. A prefix is padded
+// with zeros; a projection is mapped back along its components. Vectors
+// that are not reduced are returned unchanged.
+func (r *vectorReducer) restore(vector []float32) []float32 {
+	if !r.reduced(vector) {
+		return vector
+	}
+
+	restored := make([]float32, r.size)
+	if r.method == ReductionPrefix {
+		copy(restored, vector)
+		return restored
+	}
+
+	for i, component := range r.projection.Components {
+		for j, c := range component {
+			restored[j] += vector[i] * c
+		}
+	}
+	return restored
+}
+
+// fit fits the PCA projection to a sample of full vectors by subspace
+// iteration. The components span the principal subspace of the sample's
+// second moments about the origin rather than its mean, which keeps the
+// inner products search scores are made of.
+func (r *vectorReducer) fit(sample [][]float32) {
+	// A fixed seed makes the same sample give the same projection
+	rng := rand.New(rand.NewSource(1))
+	basis := make([][]float64, r.dimensions)
+	for i := range basis {
+		basis[i] = randomVector(rng, r.size)
+	}
+	orthonormalize(basis, rng)
+
+	projected := make([]float64, r.dimensions)
+	for range pcaIterations {
+		next := make([][]float64, r.dimensions)
+		for i := range next {
+			next[i] = make([]float64, r.size)
+		}
+
+		// next = XᵀX · basis, one sample vector at a time
+		for _, vector := range sample {
+			for i, b := range basis {
+				projected[i] = dot64(b, vector)
+			}
+			for i, n := range next {
+				p := projected[i]
+				for j, v := range vector {
+					n[j] += p * float64(v)
+				}
+			}
+		}
+
+		orthonormalize(next, rng)
+		basis = next
+	}
+
+	var total, kept float64
+	for _, vector := range sample {
+		total += squaredNorm(vector)
+		for _, b := range basis {
+			p := dot64(b, vector)
+			kept += p * p
+		}
+	}
+
+	projection := &Projection{
+		Components:        make([][]float32, r.dimensions),
+		ExplainedVariance: 1,
+	}
+	if total > 0 {
+		projection.ExplainedVariance = kept / total
+	}
+	for i, b := range basis {
+		component := make([]float32, r.size)
+		for j, v := range b {
+			component[j] = float32(v)
+		}
+		projection.Components[i] = component
+	}
+	r.projection = projection
+}
+
+// stats describes the reducer; reducedItems is the number of reduced
+// vectors in the collection
+func (r *vectorReducer) stats(reducedItems int) *ReductionStats {
+	if r == nil {
+		return nil
+	}
+
+	stats := &ReductionStats{
+		Method:       r.method,
+		Dimensions:   r.dimensions,
+		Fitted:       !r.fitting(),
+		ReducedItems: reducedItems,
+	}
+	if r.projection != nil {
+		stats.ExplainedVariance = r.projection.ExplainedVariance
+	}
+	return stats
+}
+
+// orthonormalize turns vectors into an orthonormal basis by modified
+// Gram-Schmidt. A vector that depends on the ones before it, as happens
+// when the sample is smaller than the basis, is replaced by a random one.
+func orthonormalize(vectors [][]float64, rng *rand.Rand) {
+	for i := 0; i < len(vectors); i++ {
+		v := vectors[i]
+		for _, u := range vectors[:i] {
+			p := 0.0
+			for j := range v {
+				p += u[j] * v[j]
+			}
+			for j := range v {
+				v[j] -= p * u[j]
+			}
+		}
+
+		norm := 0.0
+		for _, x := range v {
+			norm += x * x
+		}
+		norm = math.Sqrt(norm)
+		if norm < 1e-9 {
+			vectors[i] = randomVector(rng, len(v))
+			i--
+			continue
+		}
+		for j := range v {
+			v[j] /= norm
+		}
+	}
+}
+
+// randomVector draws a vector of normally distributed components
+func randomVector(rng *rand.Rand, size int) []float64 {
+	vector := make([]float64, size)
+	for i := range vector {
+		vector[i] = rng.NormFloat64()
+	}
+	return vector
+}
+
+// dot64 returns the dot product of a float64 and a float32 vector
+func dot64(a []float64, b []float32) float64 {
+	var sum float64
+	for i, v := range b {
+		sum += a[i] * float64(v)
+	}
+	return sum
+}
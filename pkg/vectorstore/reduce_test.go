@@ -0,0 +1,177 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// subspaceVectors makes count vectors of size dimensions that lie close to
+// a random subspace of rank dimensions
+func subspaceVectors(seed int64, count, size, rank int) [][]float32 {
+	random := rand.New(rand.NewSource(seed))
+	basis := randomVectors(seed, rank, size)
+
+	vectors := make([][]float32, count)
+	for i := range vectors {
+		vectors[i] = make([]float32, size)
+		for _, b := range basis {
+			weight := float32(random.NormFloat64())
+			for j, v := range b {
+				vectors[i][j] += weight * v
+			}
+		}
+		for j := range vectors[i] {
+			vectors[i][j] += float32(random.NormFloat64()) * 1e-3
+		}
+	}
+	return vectors
+}
+
+func TestPCAReduction(t *testing.T) {
+	const size, dimensions = 16, 3
+	reducer := newVectorReducer(ReductionConfig{Method: ReductionPCA, Dimensions: dimensions}, CollectionConfig{VectorSize: size})
+	sample := subspaceVectors(1, 200, size, dimensions)
+
+	if reducer.reducible(sample[0]) {
+		t.Errorf("a PCA reducer reduces vectors before it is fitted")
+	}
+	reducer.fit(sample)
+	projection := reducer.fitted()
+	if projection == nil || len(projection.Components) != dimensions {
+		t.Fatalf("fitting gave projection %+v", projection)
+	}
+	if projection.ExplainedVariance < 0.999 || projection.ExplainedVariance > 1 {
+		t.Errorf("the projection explains %v of the variance of a rank %d sample", projection.ExplainedVariance, dimensions)
+	}
+	for i, a := range projection.Components {
+		for j, b := range projection.Components {
+			want := 0.0
+			if i == j {
+				want = 1
+			}
+			if got := dotProduct(a, b); math.Abs(got-want) > 1e-5 {
+				t.Errorf("components %d and %d have dot product %v, want %v", i, j, got, want)
+			}
+		}
+	}
+
+	// Vectors in the subspace survive the round trip, and reduced vectors
+	// reduce to themselves again
+	for _, vector := range sample[:20] {
+		reduced := reducer.reduce(vector)
+		if len(reduced) != dimensions {
+			t.Fatalf("reduce returned %d dimensions, want %d", len(reduced), dimensions)
+		}
+		restored := reducer.restore(reduced)
+		if distance := euclideanDistance(restored, vector); distance > 1e-2 {
+			t.Errorf("the round trip moved a vector by %v", distance)
+		}
+		if again := reducer.reduce(restored); euclideanDistance(again, reduced) > 1e-4 {
+			t.Errorf("reducing a restored vector gave %v, want %v", again, reduced)
+		}
+	}
+
+	if stats := reducer.stats(20); !stats.Fitted || stats.ReducedItems != 20 || stats.ExplainedVariance != projection.ExplainedVariance {
+		t.Errorf("stats report %+v", stats)
+	}
+}
+
+func TestPrefixReduction(t *testing.T) {
+	reducer := newVectorReducer(ReductionConfig{Method: ReductionPrefix, Dimensions: 2}, CollectionConfig{VectorSize: 4})
+	reduced := reducer.reduce([]float32{3, 4, 1, 1})
+	if !slices.Equal(reduced, []float32{0.6, 0.8}) {
+		t.Errorf("reduce = %v, want [0.6 0.8]", reduced)
+	}
+	if restored := reducer.restore(reduced); !slices.Equal(restored, []float32{0.6, 0.8, 0, 0}) {
+		t.Errorf("restore = %v, want [0.6 0.8 0 0]", restored)
+	}
+
+	if newVectorReducer(ReductionConfig{Method: ReductionPrefix, Dimensions: 4}, CollectionConfig{VectorSize: 4}) != nil {
+		t.Errorf("a collection no larger than the reduced size got a reducer")
+	}
+}
+
+func TestPCAPersistence(t *testing.T) {
+	for _, backend := range []Backend{BackendMemory, BackendBolt} {
+		t.Run(string(backend), func(t *testing.T) {
+			ctx := context.Background()
+			const size, dimensions = 16, 3
+			config := func(dir string) *Config {
+				return &Config{
+					Backend:    backend,
+					VectorSize: size,
+					DataDir:    dir,
+					Reduction:  ReductionConfig{Method: ReductionPCA, Dimensions: dimensions, SampleSize: 50},
+				}
+			}
+			projection := func(store VectorStore) *Projection {
+				if bolt, ok := store.(*BoltStore); ok {
+					return bolt.index.projection("")
+				}
+				return store.(*MemoryStore).projection("")
+			}
+
+			dir := t.TempDir()
+			store, err := New(config(dir))
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			vectors := subspaceVectors(1, 80, size, dimensions)
+			for i, vector := range vectors {
+				item := &Item{ID: fmt.Sprintf("item-%d", i), DocumentID: fmt.Sprintf("doc-%d", i), Vector: vector}
+				if err := store.Store(ctx, item); err != nil {
+					t.Fatalf("Store failed: %v", err)
+				}
+			}
+
+			fitted := projection(store)
+			if fitted == nil {
+				t.Fatalf("no projection was fitted on %d vectors", len(vectors))
+			}
+			stats, err := store.Stats(ctx)
+			if err != nil {
+				t.Fatalf("Stats failed: %v", err)
+			}
+			if reduction := stats.Collections[DefaultCollection].Reduction; reduction == nil || reduction.ReducedItems != len(vectors) {
+				t.Errorf("stats report reduction %+v, want %d reduced items", reduction, len(vectors))
+			}
+			before, err := store.Search(ctx, &SearchParams{Vector: vectors[0], Limit: 5})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			// The reopened store keeps the basis rather than fitting a new one
+			reopened, err := New(config(dir))
+			if err != nil {
+				t.Fatalf("reopening failed: %v", err)
+			}
+			defer reopened.Close()
+			if got := projection(reopened); !reflect.DeepEqual(got, fitted) {
+				t.Errorf("the reopened store has another projection")
+			}
+
+			item, err := reopened.Get(ctx, "", "item-0")
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if len(item.Vector) != size || cosineSimilarity(item.Vector, vectors[0]) < 0.999 {
+				t.Errorf("Get returned a vector of %d dimensions and similarity %v to the stored one", len(item.Vector), cosineSimilarity(item.Vector, vectors[0]))
+			}
+			after, err := reopened.Search(ctx, &SearchParams{Vector: vectors[0], Limit: 5})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if !slices.Equal(resultIDs(after), resultIDs(before)) {
+				t.Errorf("the reopened store found %v, want %v", resultIDs(after), resultIDs(before))
+			}
+		})
+	}
+}
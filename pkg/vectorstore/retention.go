@@ -0,0 +1,155 @@
+package vectorstore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy decides when stored items expire
+type RetentionPolicy string
+
+const (
+	// RetentionDefault applies the retention of the item's collection
+	RetentionDefault RetentionPolicy = ""
+	// RetentionForever keeps items until they are deleted
+	RetentionForever RetentionPolicy = "forever"
+	// RetentionTTL expires items a fixed time after they are stored
+	RetentionTTL RetentionPolicy = "ttl"
+	// RetentionSliding expires items a fixed time after they were last
+	// stored or accessed
+	RetentionSliding RetentionPolicy = "sliding"
+)
+
+// Error definitions
+var (
+	ErrInvalidRetention = errors.New("invalid retention")
+)
+
+// Retention describes how long an item is kept. A zero TTL with the ttl or
+// sliding policy uses the collection's TTL.
+type Retention struct {
+	Policy RetentionPolicy `json:"policy"`
+	TTL    time.Duration   `json:"ttl"`
+	// Pinned items never expire, whatever their policy says
+	Pinned bool `json:"pinned"`
+}
+
+// ParseRetention parses a retention written as "forever", "ttl:<duration>"
+// or "sliding:<duration>". The duration may be omitted to use the
+// collection's TTL.
+func ParseRetention(value string) (Retention, error) {
+	name, ttl, hasTTL := strings.Cut(strings.TrimSpace(value), ":")
+
+	retention := Retention{Policy: RetentionPolicy(strings.ToLower(name))}
+	if hasTTL {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil {
+			return Retention{}, fmt.Errorf("%w: bad TTL %q", ErrInvalidRetention, ttl)
+		}
+		retention.TTL = parsed
+	}
+
+	if err := retention.validate(); err != nil {
+		return Retention{}, err
+	}
+	return retention, nil
+}
+
+// String formats a retention the way ParseRetention reads it
+func (r Retention) String() string {
+	if r.TTL > 0 {
+		return fmt.Sprintf("%s:%s", r.Policy, r.TTL)
+	}
+	return string(r.Policy)
+}
+
+// validate checks the policy name and TTL
+func (r Retention) validate() error {
+	switch r.Policy {
+	case RetentionDefault, RetentionForever, RetentionTTL, RetentionSliding:
+	default:
+		return fmt.Errorf("%w: unknown policy %q", ErrInvalidRetention, r.Policy)
+	}
+	if r.TTL < 0 {
+		return fmt.Errorf("%w: bad TTL %s", ErrInvalidRetention, r.TTL)
+	}
+	if r.Policy == RetentionForever && r.TTL > 0 {
+		return fmt.Errorf("%w: the %s policy takes no TTL", ErrInvalidRetention, r.Policy)
+	}
+	return nil
+}
+
+// retention resolves the retention of an item stored in the collection:
+// the default policy becomes the collection's, and a missing TTL the
+// collection's TTL
+func (c *CollectionConfig) retention(r Retention) Retention {
+	if r.Policy == RetentionDefault {
+		switch {
+		case c.TTL <= 0:
+			r.Policy = RetentionForever
+		case c.Sliding:
+			r.Policy = RetentionSliding
+		default:
+			r.Policy = RetentionTTL
+		}
+	}
+
+	switch {
+	case r.Policy == RetentionForever:
+		r.TTL = 0
+	case r.TTL <= 0:
+		r.TTL = c.TTL
+	}
+
+	return r
+}
+
+// expiry returns the expiry time of an item stored at now under a resolved
+// retention; zero never expires
+func (r Retention) expiry(now time.Time) time.Time {
+	if r.Policy == RetentionForever || r.TTL <= 0 {
+		return time.Time{}
+	}
+	return now.Add(r.TTL)
+}
+
+// needsRefresh reports whether a sliding item is live and was last
+// refreshed more than a hundredth of its TTL ago. Refreshing only then
+// keeps frequently read items from being rewritten on every access.
+func (i *Item) needsRefresh(now time.Time) bool {
+	r := i.Retention
+	if r.Policy != RetentionSliding || r.TTL <= 0 || i.isExpired(now) {
+		return false
+	}
+	return i.ExpiresAt.Sub(now) < r.TTL-r.TTL/100
+}
+
+// withRetention returns a copy of an item with a new retention and expiry
+func (i *Item) withRetention(retention Retention, expiresAt time.Time) *Item {
+	changed := *i
+	changed.Retention = retention
+	changed.ExpiresAt = expiresAt
+	return &changed
+}
+
+// documentExpiry resolves the retention and expiry set on a document. A
+// zero expiresAt derives the expiry from the policy.
+func (c *CollectionConfig) documentExpiry(retention Retention, expiresAt, now time.Time) (Retention, time.Time, error) {
+	if err := retention.validate(); err != nil {
+		return Retention{}, time.Time{}, err
+	}
+
+	retention = c.retention(retention)
+	switch {
+	case retention.Policy == RetentionForever:
+		expiresAt = time.Time{}
+	case expiresAt.IsZero() && retention.TTL <= 0:
+		return Retention{}, time.Time{}, fmt.Errorf("%w: the %s policy needs a TTL or an expiry time", ErrInvalidRetention, retention.Policy)
+	case expiresAt.IsZero():
+		expiresAt = retention.expiry(now)
+	}
+
+	return retention, expiresAt, nil
+}
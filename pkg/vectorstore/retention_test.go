@@ -0,0 +1,170 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Retention
+		valid bool
+	}{
+		{"forever", Retention{Policy: RetentionForever}, true},
+		{"ttl:24h", Retention{Policy: RetentionTTL, TTL: 24 * time.Hour}, true},
+		{" Sliding:168h ", Retention{Policy: RetentionSliding, TTL: 168 * time.Hour}, true},
+		{"ttl", Retention{Policy: RetentionTTL}, true},
+		{"", Retention{}, true},
+		{"forever:1h", Retention{}, false},
+		{"ttl:soon", Retention{}, false},
+		{"ttl:-1h", Retention{}, false},
+		{"weekly", Retention{}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRetention(tt.value)
+		if !tt.valid {
+			if !errors.Is(err, ErrInvalidRetention) {
+				t.Errorf("ParseRetention(%q) returned %v, want ErrInvalidRetention", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRetention(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRetention(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+		if again, err := ParseRetention(got.String()); err != nil || again != got {
+			t.Errorf("ParseRetention(%q) does not read back %q", got.String(), tt.value)
+		}
+	}
+}
+
+func TestCollectionRetention(t *testing.T) {
+	ttl := &CollectionConfig{TTL: time.Hour}
+	sliding := &CollectionConfig{TTL: time.Hour, Sliding: true}
+	forever := &CollectionConfig{}
+
+	tests := []struct {
+		name       string
+		collection *CollectionConfig
+		retention  Retention
+		want       Retention
+	}{
+		{"default of a ttl collection", ttl, Retention{}, Retention{Policy: RetentionTTL, TTL: time.Hour}},
+		{"default of a sliding collection", sliding, Retention{}, Retention{Policy: RetentionSliding, TTL: time.Hour}},
+		{"default of a collection without TTL", forever, Retention{}, Retention{Policy: RetentionForever}},
+		{"own TTL", ttl, Retention{Policy: RetentionSliding, TTL: time.Minute}, Retention{Policy: RetentionSliding, TTL: time.Minute}},
+		{"collection TTL", sliding, Retention{Policy: RetentionTTL}, Retention{Policy: RetentionTTL, TTL: time.Hour}},
+		{"pinned", ttl, Retention{Pinned: true}, Retention{Policy: RetentionTTL, TTL: time.Hour, Pinned: true}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.collection.retention(tt.retention); got != tt.want {
+			t.Errorf("%s resolved to %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetention(t *testing.T) {
+	for _, backend := range []Backend{BackendMemory, BackendBolt} {
+		t.Run(string(backend), func(t *testing.T) {
+			ctx := context.Background()
+			store := newTestStore(t, &Config{Backend: backend, VectorSize: 2})
+			ttl := 600 * time.Millisecond
+			if err := store.CreateCollection(ctx, &CollectionConfig{Name: "short", TTL: ttl}); err != nil {
+				t.Fatalf("CreateCollection failed: %v", err)
+			}
+
+			retentions := map[string]Retention{
+				"ttl":     {},
+				"sliding": {Policy: RetentionSliding},
+				"forever": {Policy: RetentionForever},
+				"pinned":  {Pinned: true},
+				"kept":    {},
+			}
+			start := time.Now()
+			for id, retention := range retentions {
+				item := &Item{ID: id, DocumentID: id, Collection: "short", Vector: []float32{1, 0}, Retention: retention}
+				if err := store.Store(ctx, item); err != nil {
+					t.Fatalf("Store failed: %v", err)
+				}
+			}
+
+			forever, err := store.Get(ctx, "short", "forever")
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if !forever.ExpiresAt.IsZero() {
+				t.Errorf("an item kept forever expires at %s", forever.ExpiresAt)
+			}
+			expiring, err := store.Get(ctx, "short", "ttl")
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if expiring.Retention.Policy != RetentionTTL || expiring.ExpiresAt.Before(start.Add(ttl)) {
+				t.Errorf("an item of the collection's retention has retention %+v, expiring at %s", expiring.Retention, expiring.ExpiresAt)
+			}
+
+			// Documents may be kept longer after they are stored
+			if err := store.SetDocumentRetention(ctx, "short", "kept", Retention{Policy: RetentionForever}, time.Time{}); err != nil {
+				t.Fatalf("SetDocumentRetention failed: %v", err)
+			}
+			if err := store.SetDocumentRetention(ctx, "short", "kept", Retention{Policy: "weekly"}, time.Time{}); !errors.Is(err, ErrInvalidRetention) {
+				t.Errorf("SetDocumentRetention with an unknown policy returned %v, want ErrInvalidRetention", err)
+			}
+			if err := store.SetDocumentRetention(ctx, "short", "missing", Retention{}, time.Time{}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("SetDocumentRetention of a missing document returned %v, want ErrNotFound", err)
+			}
+
+			// Reading a sliding item halfway through its TTL keeps it alive
+			time.Sleep(ttl / 2)
+			if _, err := store.GetDocument(ctx, "short", "sliding"); err != nil {
+				t.Fatalf("GetDocument failed: %v", err)
+			}
+			time.Sleep(ttl/2 + ttl/4)
+
+			for id := range retentions {
+				_, err := store.Get(ctx, "short", id)
+				if id == "ttl" && err == nil {
+					t.Errorf("item %s outlived its TTL", id)
+				}
+				if id != "ttl" && err != nil {
+					t.Errorf("Get of item %s failed: %v", id, err)
+				}
+			}
+
+			// Expired items stay stored until the cleanup removes them
+			before, err := store.Stats(ctx)
+			if err != nil {
+				t.Fatalf("Stats failed: %v", err)
+			}
+			store.(interface{ cleanupExpiredItems() }).cleanupExpiredItems()
+			after, err := store.Stats(ctx)
+			if err != nil {
+				t.Fatalf("Stats failed: %v", err)
+			}
+			if after.Items != before.Items-1 {
+				t.Errorf("cleanup left %d of %d items, want one removed", after.Items, before.Items)
+			}
+
+			// An explicit expiry applies as given
+			expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+			if err := store.SetDocumentRetention(ctx, "short", "pinned", Retention{Policy: RetentionTTL}, expiresAt); err != nil {
+				t.Fatalf("SetDocumentRetention failed: %v", err)
+			}
+			pinned, err := store.Get(ctx, "short", "pinned")
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if !pinned.ExpiresAt.Equal(expiresAt) || pinned.Retention.Pinned {
+				t.Errorf("item expires at %s with retention %+v, want %s unpinned", pinned.ExpiresAt, pinned.Retention, expiresAt)
+			}
+		})
+	}
+}
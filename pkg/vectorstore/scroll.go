@@ -0,0 +1,85 @@
+package vectorstore
+
+import (
+	"context"
+)
+
+// defaultScrollLimit is the page size of a scroll without a limit
+const defaultScrollLimit = 100
+
+// ScrollParams selects a page of stored items
+type ScrollParams struct {
+	// Collection to scroll; empty selects the default collection
+	Collection string
+	// Filter restricts the items to those whose metadata matches
+	Filter *Filter
+	// DocumentID restricts the items to the chunks of one document
+	DocumentID string
+	// Limit is the page size; it defaults to 100
+	Limit int
+	// Offset continues from the NextOffset of a previous page
+	Offset string
+}
+
+// ScrollPage is one page of stored items, with their vectors
+type ScrollPage struct {
+	Items []*Item
+	// NextOffset fetches the following page; empty on the last page
+	NextOffset string
+}
+
+// Scroller iterates over every item of a collection, a page at a time
+type Scroller struct {
+	store  VectorStore
+	params ScrollParams
+	page   []*Item
+	item   *Item
+	done   bool
+	err    error
+}
+
+// NewScroller creates an iterator over the items selected by params
+func NewScroller(store VectorStore, params ScrollParams) *Scroller {
+	return &Scroller{store: store, params: params}
+}
+
+// Next advances to the next item, fetching a page when needed. It returns
+// false when the items are exhausted or an error occurs.
+func (s *Scroller) Next(ctx context.Context) bool {
+	for len(s.page) == 0 {
+		if s.done || s.err != nil {
+			return false
+		}
+
+		page, err := s.store.Scroll(ctx, &s.params)
+		if err != nil {
+			s.err = err
+			return false
+		}
+
+		s.page = page.Items
+		s.params.Offset = page.NextOffset
+		s.done = page.NextOffset == ""
+	}
+
+	s.item, s.page = s.page[0], s.page[1:]
+	return true
+}
+
+// Item returns the current item
+func (s *Scroller) Item() *Item {
+	return s.item
+}
+
+// Err returns the error that stopped the iteration, if any
+func (s *Scroller) Err() error {
+	return s.err
+}
+
+// scrollLimit returns the page size of a scroll
+func (p *ScrollParams) scrollLimit() int {
+	if p.Limit <= 0 {
+		return defaultScrollLimit
+	}
+	return p.Limit
+}
@@ -0,0 +1,72 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestScrollerDocument(t *testing.T) {
+	for _, backend := range []Backend{BackendMemory, BackendBolt} {
+		t.Run(string(backend), func(t *testing.T) {
+			ctx := context.Background()
+			store := newTestStore(t, &Config{Backend: backend, VectorSize: 2, TTL: time.Hour})
+			if err := store.CreateCollection(ctx, &CollectionConfig{Name: "sliding", TTL: time.Second, Sliding: true}); err != nil {
+				t.Fatalf("CreateCollection failed: %v", err)
+			}
+
+			var items []*Item
+			for i := 0; i < 5; i++ {
+				items = append(items, &Item{ID: fmt.Sprintf("a-%d", i), DocumentID: "a", Vector: []float32{1, 0}})
+				items = append(items, &Item{ID: fmt.Sprintf("b-%d", i), DocumentID: "b", Vector: []float32{0, 1}})
+			}
+			if err := store.StoreBatch(ctx, "sliding", items); err != nil {
+				t.Fatalf("StoreBatch failed: %v", err)
+			}
+			stored, err := store.Scroll(ctx, &ScrollParams{Collection: "sliding", DocumentID: "a"})
+			if err != nil {
+				t.Fatalf("Scroll failed: %v", err)
+			}
+			expiresAt := stored.Items[0].ExpiresAt
+
+			// Sliding items are refreshed once a hundredth of their TTL has passed
+			time.Sleep(20 * time.Millisecond)
+
+			var ids []string
+			scroller := NewScroller(store, ScrollParams{Collection: "sliding", DocumentID: "a", Limit: 2})
+			for scroller.Next(ctx) {
+				item := scroller.Item()
+				if item.DocumentID != "a" {
+					t.Errorf("item %s of document %s scrolled", item.ID, item.DocumentID)
+				}
+				ids = append(ids, item.ID)
+			}
+			if err := scroller.Err(); err != nil {
+				t.Fatalf("Scroller failed: %v", err)
+			}
+			if len(ids) != 5 || ids[0] != "a-0" || ids[4] != "a-4" {
+				t.Errorf("scrolled %v, want a-0 to a-4 in order", ids)
+			}
+
+			// Scrolling must not refresh the sliding expiry, unlike reads
+			again, err := store.Scroll(ctx, &ScrollParams{Collection: "sliding", DocumentID: "a"})
+			if err != nil {
+				t.Fatalf("Scroll failed: %v", err)
+			}
+			if !again.Items[0].ExpiresAt.Equal(expiresAt) {
+				t.Errorf("scroll moved the expiry from %s to %s", expiresAt, again.Items[0].ExpiresAt)
+			}
+			if _, err := store.GetDocument(ctx, "sliding", "a"); err != nil {
+				t.Fatalf("GetDocument failed: %v", err)
+			}
+			read, err := store.Scroll(ctx, &ScrollParams{Collection: "sliding", DocumentID: "a"})
+			if err != nil {
+				t.Fatalf("Scroll failed: %v", err)
+			}
+			if !read.Items[0].ExpiresAt.After(expiresAt) {
+				t.Errorf("GetDocument did not refresh the sliding expiry")
+			}
+		})
+	}
+}
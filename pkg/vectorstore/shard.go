@@ -0,0 +1,150 @@
+package vectorstore
+
+import (
+	"container/heap"
+	"hash/fnv"
+	"runtime"
+	"sync"
+)
+
+// memoryShard holds part of a collection's items behind its own lock, so
+// that a write locks out searches only while it touches the shard
+type memoryShard struct {
+	lock        sync.RWMutex
+	items       map[string]*Item
+	codes       map[string]quantizedVector // quantized vectors by item ID
+	permissions *permissionIndex
+}
+
+// newMemoryShard creates an empty shard
+func newMemoryShard() *memoryShard {
+	return &memoryShard{
+		items:       make(map[string]*Item),
+		codes:       make(map[string]quantizedVector),
+		permissions: newPermissionIndex(),
+	}
+}
+
+// shardCount returns the number of shards per collection. It defaults to
+// one shard per CPU.
+func shardCount(config *Config) int {
+	if config.Shards > 0 {
+		return config.Shards
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// shard returns the shard that holds an item ID
+func (c *memoryCollection) shard(id string) *memoryShard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return c.shards[h.Sum32()%uint32(len(c.shards))]
+}
+
+// lockShards write-locks every shard, in order
+func (c *memoryCollection) lockShards() {
+	for _, shard := range c.shards {
+		shard.lock.Lock()
+	}
+}
+
+// unlockShards releases the locks taken by lockShards
+func (c *memoryCollection) unlockShards() {
+	for _, shard := range c.shards {
+		shard.lock.Unlock()
+	}
+}
+
+// rLockShards read-locks every shard, in order. Holding every shard gives
+// a search a consistent view of multi-item writes.
+func (c *memoryCollection) rLockShards() {
+	for _, shard := range c.shards {
+		shard.lock.RLock()
+	}
+}
+
+// rUnlockShards releases the locks taken by rLockShards
+func (c *memoryCollection) rUnlockShards() {
+	for _, shard := range c.shards {
+		shard.lock.RUnlock()
+	}
+}
+
+// scanShards runs scan on every shard in parallel and merges the best
+// limit results. The caller must hold every shard's read lock.
+func (c *memoryCollection) scanShards(limit int, scan func(*memoryShard) []scoredItem) []scoredItem {
+	results := make([][]scoredItem, len(c.shards))
+	if len(c.shards) == 1 {
+		results[0] = scan(c.shards[0])
+	} else {
+		var wg sync.WaitGroup
+		for i, shard := range c.shards {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = scan(shard)
+			}()
+		}
+		wg.Wait()
+	}
+
+	merged := newTopK(limit)
+	for _, result := range results {
+		for _, s := range result {
+			merged.push(s)
+		}
+	}
+
+	return merged.sorted()
+}
+
+// topK keeps the best k scored items pushed into it; a k of zero or less
+// keeps every item
+type topK struct {
+	k     int
+	items scoredHeap
+}
+
+// newTopK creates an empty top-k collector
+func newTopK(k int) *topK {
+	return &topK{k: k}
+}
+
+// push offers an item, evicting the worst kept item when full
+func (t *topK) push(s scoredItem) {
+	switch {
+	case t.k <= 0:
+		t.items = append(t.items, s)
+	case len(t.items) < t.k:
+		heap.Push(&t.items, s)
+	case better(s, t.items[0]):
+		t.items[0] = s
+		heap.Fix(&t.items, 0)
+	}
+}
+
+// sorted returns the kept items, best first
+func (t *topK) sorted() []scoredItem {
+	items := []scoredItem(t.items)
+	sortScored(items)
+	return items
+}
+
+// scoredHeap is a heap of scored items with the worst item on top
+type scoredHeap []scoredItem
+
+func (h scoredHeap) Len() int            { return len(h) }
+func (h scoredHeap) Less(i, j int) bool  { return better(h[j], h[i]) }
+func (h scoredHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *scoredHeap) Push(x interface{}) { *h = append(*h, x.(scoredItem)) }
+func (h *scoredHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,120 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestShardedSearchMatchesSingleShard(t *testing.T) {
+	ctx := context.Background()
+	const dimensions, count = 16, 500
+	single := newTestStore(t, &Config{Backend: BackendMemory, VectorSize: dimensions, Shards: 1})
+	sharded := newTestStore(t, &Config{Backend: BackendMemory, VectorSize: dimensions, Shards: 8})
+
+	vectors := randomVectors(1, count, dimensions)
+	for i, vector := range vectors {
+		item := &Item{
+			ID:          fmt.Sprintf("item-%d", i),
+			DocumentID:  fmt.Sprintf("doc-%d", i/4),
+			Vector:      vector,
+			Permissions: []string{fmt.Sprintf("user-%d", i%3)},
+		}
+		for _, store := range []VectorStore{single, sharded} {
+			if err := store.Store(ctx, item); err != nil {
+				t.Fatalf("Store failed: %v", err)
+			}
+		}
+	}
+
+	queries := randomVectors(2, 20, dimensions)
+	for i, query := range queries {
+		params := []*SearchParams{
+			{Vector: query, Limit: 10},
+			{Vector: query, Limit: 5, Offset: 7},
+			{Vector: query, Limit: 10, PermissionFilter: []string{fmt.Sprintf("user-%d", i%3)}},
+		}
+		for _, p := range params {
+			want, err := single.Search(ctx, p)
+			if err != nil {
+				t.Fatalf("Search of one shard failed: %v", err)
+			}
+			got, err := sharded.Search(ctx, p)
+			if err != nil {
+				t.Fatalf("Search of eight shards failed: %v", err)
+			}
+			if !slices.Equal(resultIDs(got), resultIDs(want)) {
+				t.Fatalf("eight shards found %v, one shard %v", resultIDs(got), resultIDs(want))
+			}
+			for j := range got {
+				if got[j].Score != want[j].Score {
+					t.Errorf("eight shards scored %s %v, one shard %v", got[j].ID, got[j].Score, want[j].Score)
+				}
+			}
+		}
+	}
+}
+
+func TestConcurrentStoreAndSearch(t *testing.T) {
+	ctx := context.Background()
+	const dimensions, writers, writes = 8, 4, 100
+	configs := map[string]Config{
+		"flat":      {},
+		"hnsw":      {Index: IndexConfig{Type: IndexHNSW}},
+		"quantized": {Quantization: QuantizationConfig{Enabled: true, CalibrationSize: 50}},
+	}
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) {
+			config.Backend, config.VectorSize, config.Shards = BackendMemory, dimensions, 4
+			store := newTestStore(t, &config)
+			vectors := randomVectors(1, writers*writes, dimensions)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 2*writers)
+			for w := 0; w < writers; w++ {
+				wg.Add(2)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < writes; i++ {
+						id := fmt.Sprintf("item-%d-%d", w, i)
+						item := &Item{ID: id, DocumentID: id, Vector: vectors[w*writes+i]}
+						if err := store.Store(ctx, item); err != nil {
+							errs <- err
+							return
+						}
+						if i%5 == 0 {
+							if err := store.Delete(ctx, "", id); err != nil {
+								errs <- err
+								return
+							}
+						}
+					}
+				}(w)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < writes; i++ {
+						if _, err := store.Search(ctx, &SearchParams{Vector: vectors[w*writes+i], Limit: 5}); err != nil {
+							errs <- err
+							return
+						}
+					}
+				}(w)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Errorf("concurrent access failed: %v", err)
+			}
+
+			stats, err := store.Stats(ctx)
+			if err != nil {
+				t.Fatalf("Stats failed: %v", err)
+			}
+			if want := writers * writes * 4 / 5; stats.Items != want {
+				t.Errorf("store holds %d items, want %d", stats.Items, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Backend identifies a vector store implementation
+type Backend string
+
+const (
+	// BackendMemory keeps all vectors in process memory
+	BackendMemory Backend = "memory"
+	// BackendQdrant stores vectors in a Qdrant server over its REST API
+	BackendQdrant Backend = "qdrant"
+	// BackendBolt keeps items in an embedded database file and searches an
+	// in-memory index of their vectors
+	BackendBolt Backend = "bolt"
+)
+
+// Error definitions
+var (
+	ErrStoreClosed        = errors.New("store is closed")
+	ErrNotFound           = errors.New("item not found")
+	ErrCollectionNotFound = errors.New("collection not found")
+	ErrCollectionExists   = errors.New("collection already exists")
+	ErrInvalidCollection  = errors.New("invalid collection")
+	ErrVectorSize         = errors.New("vector size mismatch")
+)
+
+// Config contains configuration for the vector store
+type Config struct {
+	Backend Backend
+	Address string
+	APIKey  string
+	// Collection names the default collection, which is created on startup
+	// with VectorSize and TTL
+	Collection string
+	VectorSize int
+	TTL        time.Duration
+	Timeout    time.Duration
+	// Index selects the search index of the in-memory backend
+	Index IndexConfig
+	// DataDir enables write-ahead logging and snapshots for the in-memory
+	// backend and holds the database file of the bolt backend
+	DataDir          string
+	SnapshotInterval time.Duration
+	// SyncWrites fsyncs the write-ahead log after every write
+	SyncWrites bool
+	// Quantization stores int8 vectors in the in-memory backend
+	Quantization QuantizationConfig
+	// Reduction shrinks the vectors of the in-memory and bolt backends
+	Reduction ReductionConfig
+	// Shards splits each in-memory collection for parallel exact search;
+	// zero uses one shard per CPU
+	Shards int
+	// MemoryBudget caps the estimated bytes held by the in-memory backend.
+	// Over it, whole documents are evicted, least recently hit first.
+	// Zero leaves memory unbounded.
+	MemoryBudget int64
+	// OnEvict is called after each eviction. It runs on the goroutine of
+	// the write that caused the eviction and must not block.
+	OnEvict func(*Eviction)
+	// ChangeFeedSize is the number of recent changes kept so that
+	// subscribers can resume from a sequence number. Zero keeps 10000
+	// changes; a negative size disables the change feed.
+	ChangeFeedSize int
+}
+
+// Item represents a stored vector item.
+// An empty Collection selects the default collection.
+type Item struct {
+	ID          string
+	Collection  string
+	Vector      []float32
+	DocumentID  string
+	Content     string
+	Title       string
+	Metadata    map[string]string
+	Permissions []string
+	// Retention is resolved against the collection when the item is stored
+	Retention Retention
+	ExpiresAt time.Time
+	// Model made the vector; zero if unknown
+	Model EmbeddingModel
+}
+
+// SearchParams contains parameters for search operations
+type SearchParams struct {
+	// Collections to search; empty searches the default collection
+	Collections []string
+	Vector      []float32
+	// Limit is the number of results; zero or less returns 10 on every
+	// backend
+	Limit            int
+	PermissionFilter []string
+	// Filter restricts the search to items whose metadata matches
+	Filter *Filter
+	// After continues a previous search from its last result
+	After *Cursor
+	// Offset skips results, counted after the cursor
+	Offset int
+	// MinScore drops results scoring below it
+	MinScore *float64
+	// WithVectors returns the stored vector of each result
+	WithVectors bool
+	// Model restricts the search to vectors of the query's model, leaving
+	// out untagged vectors; nil searches every vector
+	Model *EmbeddingModel
+}
+
+// SearchResult represents a search result
+type SearchResult struct {
+	ID         string
+	Collection string
+	DocumentID string
+	Content    string
+	Title      string
+	Metadata   map[string]string
+	Score      float64
+	// Vector is set when the search asked for vectors
+	Vector []float32
+}
+
+// Stats reports the size of a vector store and of each collection.
+// Documents is zero for backends that do not track documents.
+type Stats struct {
+	Backend   Backend `json:"backend"`
+	Items     int     `json:"items"`
+	Documents int     `json:"documents"`
+	// VectorBytes is the memory taken by vector components
+	VectorBytes int64                       `json:"vector_bytes"`
+	Collections map[string]*CollectionStats `json:"collections"`
+	// Memory is reported by the in-memory backend only
+	Memory *MemoryStats `json:"memory,omitempty"`
+}
+
+// VectorStore is implemented by every vector storage backend
+type VectorStore interface {
+	// CreateCollection adds a new collection
+	CreateCollection(ctx context.Context, config *CollectionConfig) error
+	// ListCollections describes every collection, sorted by name
+	ListCollections(ctx context.Context) ([]*CollectionInfo, error)
+	// DropCollection removes a collection and everything stored in it
+	DropCollection(ctx context.Context, name string) error
+	// Store adds or updates a vector in the item's collection
+	Store(ctx context.Context, item *Item) error
+	// StoreBatch adds or updates several vectors in one collection. Either
+	// every item is stored or none is.
+	StoreBatch(ctx context.Context, collection string, items []*Item) error
+	// Get retrieves a vector by ID
+	Get(ctx context.Context, collection, id string) (*Item, error)
+	// Delete removes a vector from the store
+	Delete(ctx context.Context, collection, id string) error
+	// GetDocument returns the live chunks stored for a document
+	GetDocument(ctx context.Context, collection, documentID string) ([]*Item, error)
+	// DeleteDocument removes every chunk of a document
+	DeleteDocument(ctx context.Context, collection, documentID string) error
+	// ReplaceDocument replaces all chunks of a document with items
+	ReplaceDocument(ctx context.Context, collection, documentID string, items []*Item) error
+	// SetDocumentRetention changes the retention and expiry of every chunk
+	// of a document. A zero expiresAt derives the expiry from the retention.
+	SetDocumentRetention(ctx context.Context, collection, documentID string, retention Retention, expiresAt time.Time) error
+	// Scroll returns a page of live items with their vectors
+	Scroll(ctx context.Context, params *ScrollParams) (*ScrollPage, error)
+	// Search performs vector similarity search over one or more collections
+	Search(ctx context.Context, params *SearchParams) ([]*SearchResult, error)
+	// Stats reports the size of the store
+	Stats(ctx context.Context) (*Stats, error)
+	// Subscribe streams the changes numbered above after; an after of zero
+	// streams only new changes
+	Subscribe(ctx context.Context, after uint64) (*ChangeStream, error)
+	// Close releases the resources held by the store
+	Close() error
+}
+
+// New creates the vector store selected by config.Backend.
+// An empty backend selects the in-memory store.
+func New(config *Config) (VectorStore, error) {
+	switch config.Backend {
+	case "", BackendMemory:
+		return NewMemoryStore(config)
+	case BackendQdrant:
+		return NewQdrantStore(config)
+	case BackendBolt:
+		return NewBoltStore(config)
+	default:
+		return nil, fmt.Errorf("unknown vector store backend %q", config.Backend)
+	}
+}
+
+// defaultCollectionConfig describes the collection created on startup
+func defaultCollectionConfig(config *Config) *CollectionConfig {
+	name := config.Collection
+	if name == "" {
+		name = DefaultCollection
+	}
+
+	return &CollectionConfig{
+		Name:       name,
+		VectorSize: config.VectorSize,
+		Distance:   DistanceCosine,
+		TTL:        config.TTL,
+	}
+}
+
+// checkDocumentItems verifies that replacement items belong to the document
+func checkDocumentItems(documentID string, items []*Item) error {
+	for _, item := range items {
+		if item.DocumentID != documentID {
+			return fmt.Errorf("item %s belongs to document %q, not %q", item.ID, item.DocumentID, documentID)
+		}
+	}
+	return nil
+}
+
+// isExpired reports whether the item has passed its expiration time.
+// Pinned items never expire.
+func (i *Item) isExpired(now time.Time) bool {
+	return !i.Retention.Pinned && !i.ExpiresAt.IsZero() && now.After(i.ExpiresAt)
+}
@@ -0,0 +1,186 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// testStores opens an empty store of every backend with two dimensional
+// vectors; qdrant runs against a fake server
+var testStores = map[string]func(t *testing.T) VectorStore{
+	"memory": func(t *testing.T) VectorStore { return newTestStore(t, &Config{VectorSize: 2}) },
+	"bolt":   func(t *testing.T) VectorStore { return newTestStore(t, &Config{Backend: BackendBolt, VectorSize: 2}) },
+	"qdrant": func(t *testing.T) VectorStore {
+		_, store := newFakeQdrant(t, &Config{VectorSize: 2})
+		return store
+	},
+}
+
+func TestReplaceDocument(t *testing.T) {
+	for name, open := range testStores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := open(t)
+
+			stale := []*Item{
+				{ID: "a-0", DocumentID: "a", Vector: []float32{1, 0}, Content: "old first"},
+				{ID: "a-1", DocumentID: "a", Vector: []float32{0.9, 0.1}, Content: "old second"},
+				{ID: "a-2", DocumentID: "a", Vector: []float32{0.8, 0.2}, Content: "old third"},
+			}
+			if err := store.StoreBatch(ctx, "", stale); err != nil {
+				t.Fatalf("StoreBatch failed: %v", err)
+			}
+			if err := store.Store(ctx, &Item{ID: "b-0", DocumentID: "b", Vector: []float32{0, 1}}); err != nil {
+				t.Fatalf("Store failed: %v", err)
+			}
+
+			// Reindexing with fewer chunks leaves none of the old ones behind
+			fresh := []*Item{
+				{ID: "a-0", DocumentID: "a", Vector: []float32{0.7, 0.3}, Content: "new first"},
+				{ID: "a-new", DocumentID: "a", Vector: []float32{0.6, 0.4}, Content: "new second"},
+			}
+			if err := store.ReplaceDocument(ctx, "", "a", fresh); err != nil {
+				t.Fatalf("ReplaceDocument failed: %v", err)
+			}
+
+			chunks, err := store.GetDocument(ctx, "", "a")
+			if err != nil {
+				t.Fatalf("GetDocument failed: %v", err)
+			}
+			var contents []string
+			for _, chunk := range chunks {
+				contents = append(contents, chunk.Content)
+			}
+			if slices.Sort(contents); !slices.Equal(contents, []string{"new first", "new second"}) {
+				t.Errorf("document a holds %q after the reindex", contents)
+			}
+
+			results, err := store.Search(ctx, &SearchParams{Vector: []float32{1, 0}, Limit: 10})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if got := slices.Sorted(slices.Values(resultIDs(results))); !slices.Equal(got, []string{"a-0", "a-new", "b-0"}) {
+				t.Errorf("search after the reindex found %v, want a-0, a-new and b-0", got)
+			}
+			for _, result := range results {
+				if result.ID == "a-0" && result.Content != "new first" {
+					t.Errorf("search returned the stale content %q of a-0", result.Content)
+				}
+			}
+
+			// Deleting the document removes every chunk and nothing else
+			if err := store.DeleteDocument(ctx, "", "a"); err != nil {
+				t.Fatalf("DeleteDocument failed: %v", err)
+			}
+			if chunks, err := store.GetDocument(ctx, "", "a"); err != nil || len(chunks) != 0 {
+				t.Errorf("GetDocument of a deleted document returned %d chunks, %v", len(chunks), err)
+			}
+			if _, err := store.Get(ctx, "", "a-new"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get of a deleted chunk returned %v, want ErrNotFound", err)
+			}
+			if _, err := store.Get(ctx, "", "b-0"); err != nil {
+				t.Errorf("Get of another document's chunk failed: %v", err)
+			}
+		})
+	}
+}
+
+// newTestStore opens a store of the configured backend in a temporary
+// directory and closes it when the test ends
+func newTestStore(t *testing.T, config *Config) VectorStore {
+	t.Helper()
+
+	if config.Backend == BackendBolt && config.DataDir == "" {
+		config.DataDir = t.TempDir()
+	}
+	store, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to open %s store: %v", config.Backend, err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+// resultIDs lists the IDs of search results
+func resultIDs(results []*SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+	return ids
+}
+
+func TestStoreBatchIsAtomic(t *testing.T) {
+	for name, open := range testStores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := open(t)
+			if err := store.Store(ctx, &Item{ID: "a", DocumentID: "a", Vector: []float32{1, 0}, Content: "kept"}); err != nil {
+				t.Fatalf("Store failed: %v", err)
+			}
+
+			// One item of the wrong size fails the whole batch
+			batch := []*Item{
+				{ID: "a", DocumentID: "a", Vector: []float32{0, 1}, Content: "replaced"},
+				{ID: "b", DocumentID: "b", Vector: []float32{0.6, 0.8}},
+				{ID: "c", DocumentID: "c", Vector: []float32{1, 0, 0}},
+			}
+			if err := store.StoreBatch(ctx, "", batch); !errors.Is(err, ErrVectorSize) {
+				t.Fatalf("StoreBatch with a bad vector returned %v, want ErrVectorSize", err)
+			}
+
+			if item, err := store.Get(ctx, "", "a"); err != nil || item.Content != "kept" {
+				t.Errorf("Get of a after the failed batch returned %+v, %v", item, err)
+			}
+			for _, id := range []string{"b", "c"} {
+				if _, err := store.Get(ctx, "", id); !errors.Is(err, ErrNotFound) {
+					t.Errorf("Get of %s after the failed batch returned %v, want ErrNotFound", id, err)
+				}
+			}
+			results, err := store.Search(ctx, &SearchParams{Vector: []float32{0, 1}, Limit: 10})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if ids := resultIDs(results); !slices.Equal(ids, []string{"a"}) {
+				t.Errorf("search after the failed batch found %v, want [a]", ids)
+			}
+
+			if err := store.StoreBatch(ctx, "", batch[:2]); err != nil {
+				t.Fatalf("StoreBatch failed: %v", err)
+			}
+			if item, err := store.Get(ctx, "", "a"); err != nil || item.Content != "replaced" {
+				t.Errorf("Get of a after the batch returned %+v, %v", item, err)
+			}
+		})
+	}
+}
+
+func TestSearchDefaultLimit(t *testing.T) {
+	for name, open := range testStores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := open(t)
+			items := make([]*Item, 15)
+			for i := range items {
+				id := string(rune('a' + i))
+				items[i] = &Item{ID: id, DocumentID: id, Vector: []float32{1, float32(i)}}
+			}
+			if err := store.StoreBatch(ctx, "", items); err != nil {
+				t.Fatalf("StoreBatch failed: %v", err)
+			}
+
+			for _, limit := range []int{0, -1} {
+				results, err := store.Search(ctx, &SearchParams{Vector: []float32{1, 0}, Limit: limit})
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				if len(results) != defaultSearchLimit || results[0].ID != "a" {
+					t.Errorf("search with limit %d found %v, want the best %d", limit, resultIDs(results), defaultSearchLimit)
+				}
+			}
+		})
+	}
+}